@@ -0,0 +1,184 @@
+package gocs
+
+import (
+	"context"
+	"net/url"
+)
+
+// Host actions
+const (
+	ActionContain         = "contain"
+	ActionLiftContainment = "lift_containment"
+	ActionHideHost        = "hide_host"
+	ActionUnhideHost      = "unhide_host"
+)
+
+// maxHostsPerAction is the number of IDs the host action API accepts in a single request
+const maxHostsPerAction = 100
+
+// ErrConfirmationRequired is returned when a host action is attempted without the confirmation token
+var ErrConfirmationRequired = &Error{Code: "confirmation_required", Message: "Host actions require the confirmation token or a dry run"}
+
+// SetActionConfirmation sets the token that host actions such as ContainHosts must carry in
+// HostActionOptions.Confirm unless they are a dry run. Without a token, only dry runs are allowed.
+func SetActionConfirmation(token string) OptionFunc {
+	return func(c *client) error {
+		if token == "" {
+			c.errorf("%v\n", ErrMissingParams)
+			return ErrMissingParams
+		}
+		c.confirm = token
+		return nil
+	}
+}
+
+// SetRequireActionConfirmation controls whether host actions must carry the confirmation token or be a dry run.
+// It is required by default to guard against accidental containment.
+func SetRequireActionConfirmation(require bool) OptionFunc {
+	return func(c *client) error {
+		c.noConfirm = !require
+		return nil
+	}
+}
+
+// HostActionOptions control the sending of a host action
+type HostActionOptions struct {
+	DryRun  bool   // Report what would be done without sending the action
+	Confirm string // Must match the token set with SetActionConfirmation
+}
+
+// HostActionResult is the outcome of a host action for a single device
+type HostActionResult struct {
+	ID   string
+	Sent bool  // Was the action accepted for the device
+	Err  error // The error for the device, if any
+}
+
+// HostActionResponse is returned from the host actions
+type HostActionResponse struct {
+	Meta struct {
+		QueryTime float64 `json:"query_time"`
+		TraceID   string  `json:"trace_id"`
+	} `json:"meta"`
+	Resources []struct {
+		ID   string `json:"id"`
+		Path string `json:"path"`
+	} `json:"resources"`
+	Errors []Error `json:"errors"`
+	DryRun bool    `json:"-"` // The action was not sent
+	items  []string
+}
+
+// Err returns a *PartialError if the response carries per-item errors, nil otherwise
+func (r *HostActionResponse) Err() error {
+	return newPartialError(r.items, r.Errors)
+}
+
+// Results returns the outcome of the action for each of the requested devices
+func (r *HostActionResponse) Results() []HostActionResult {
+	res := make([]HostActionResult, len(r.items))
+	index := make(map[string]int, len(r.items))
+	for i, id := range r.items {
+		res[i].ID = id
+		index[id] = i
+	}
+	if r.DryRun {
+		return res
+	}
+	for _, resource := range r.Resources {
+		if i, ok := index[resource.ID]; ok {
+			res[i].Sent = true
+		}
+	}
+	if pe, ok := r.Err().(*PartialError); ok {
+		for j := range pe.Errors {
+			if i, ok := index[pe.Errors[j].Item]; ok {
+				res[i].Sent, res[i].Err = false, &pe.Errors[j]
+			}
+		}
+	}
+	return res
+}
+
+// HostAction performs the action on the devices with the given IDs. See the Action constants.
+// Unless it is a dry run, the action must be confirmed in opts - see SetActionConfirmation.
+// Large ID lists are split into several requests and the results are combined.
+func (h *Host) HostAction(action string, ids []string, opts *HostActionOptions) (resp *HostActionResponse, err error) {
+	return h.HostActionContext(context.Background(), action, ids, opts)
+}
+
+// HostActionContext is like HostAction with a context for cancellation and deadlines
+func (h *Host) HostActionContext(ctx context.Context, action string, ids []string, opts *HostActionOptions) (resp *HostActionResponse, err error) {
+	if action == "" || len(ids) == 0 {
+		return nil, ErrMissingParams
+	}
+	if opts == nil {
+		opts = &HostActionOptions{}
+	}
+	if !h.noConfirm && !opts.DryRun && (h.confirm == "" || opts.Confirm != h.confirm) {
+		h.errorf("%v\n", ErrConfirmationRequired)
+		return nil, ErrConfirmationRequired
+	}
+	resp = &HostActionResponse{items: ids, DryRun: opts.DryRun}
+	if opts.DryRun {
+		h.tracef("Dry run of %s on %v\n", action, ids)
+		return
+	}
+	params := url.Values{"action_name": {action}}
+	for _, chunk := range chunks(ids, maxHostsPerAction) {
+		b, err := idsBody(chunk)
+		if err != nil {
+			return resp, err
+		}
+		r := &HostActionResponse{}
+		if err = h.do(ctx, "POST", "devices/entities/devices-actions/v2", params, b, r, h.authFunc()); err != nil {
+			return resp, err
+		}
+		resp.Meta.QueryTime += r.Meta.QueryTime
+		resp.Meta.TraceID = r.Meta.TraceID
+		resp.Resources = append(resp.Resources, r.Resources...)
+		resp.Errors = append(resp.Errors, r.Errors...)
+	}
+	err = h.partial(resp.Err())
+	return
+}
+
+// ContainHosts network contains the devices so they can only communicate with the Falcon cloud
+func (h *Host) ContainHosts(ids []string, opts *HostActionOptions) (resp *HostActionResponse, err error) {
+	return h.HostActionContext(context.Background(), ActionContain, ids, opts)
+}
+
+// ContainHostsContext is like ContainHosts with a context for cancellation and deadlines
+func (h *Host) ContainHostsContext(ctx context.Context, ids []string, opts *HostActionOptions) (resp *HostActionResponse, err error) {
+	return h.HostActionContext(ctx, ActionContain, ids, opts)
+}
+
+// LiftContainment restores the network connectivity of contained devices
+func (h *Host) LiftContainment(ids []string, opts *HostActionOptions) (resp *HostActionResponse, err error) {
+	return h.HostActionContext(context.Background(), ActionLiftContainment, ids, opts)
+}
+
+// LiftContainmentContext is like LiftContainment with a context for cancellation and deadlines
+func (h *Host) LiftContainmentContext(ctx context.Context, ids []string, opts *HostActionOptions) (resp *HostActionResponse, err error) {
+	return h.HostActionContext(ctx, ActionLiftContainment, ids, opts)
+}
+
+// HideHosts hides the devices from the console
+func (h *Host) HideHosts(ids []string, opts *HostActionOptions) (resp *HostActionResponse, err error) {
+	return h.HostActionContext(context.Background(), ActionHideHost, ids, opts)
+}
+
+// HideHostsContext is like HideHosts with a context for cancellation and deadlines
+func (h *Host) HideHostsContext(ctx context.Context, ids []string, opts *HostActionOptions) (resp *HostActionResponse, err error) {
+	return h.HostActionContext(ctx, ActionHideHost, ids, opts)
+}
+
+// UnhideHosts restores hidden devices to the console
+func (h *Host) UnhideHosts(ids []string, opts *HostActionOptions) (resp *HostActionResponse, err error) {
+	return h.HostActionContext(context.Background(), ActionUnhideHost, ids, opts)
+}
+
+// UnhideHostsContext is like UnhideHosts with a context for cancellation and deadlines
+func (h *Host) UnhideHostsContext(ctx context.Context, ids []string, opts *HostActionOptions) (resp *HostActionResponse, err error) {
+	return h.HostActionContext(ctx, ActionUnhideHost, ids, opts)
+}
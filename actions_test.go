@@ -0,0 +1,113 @@
+package gocs
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+)
+
+// actionsHandler accepts the host actions, failing the devices in failed
+func actionsHandler(t *testing.T, calls *int32, failed map[string]bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		if r.URL.Path != "/devices/entities/devices-actions/v2" || r.URL.Query().Get("action_name") == "" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		var body struct {
+			IDs []string `json:"ids"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		var resources []map[string]string
+		var errs []Error
+		for _, id := range body.IDs {
+			if failed[id] {
+				errs = append(errs, Error{Code: "404", Message: "Device not found", ID: id})
+			} else {
+				resources = append(resources, map[string]string{"id": id})
+			}
+		}
+		writeJSON(w, http.StatusAccepted, map[string]interface{}{"resources": resources, "errors": errs})
+	}
+}
+
+func TestContainRequiresConfirmation(t *testing.T) {
+	var calls int32
+	h := newTestHost(t, actionsHandler(t, &calls, nil))
+	if _, err := h.ContainHosts([]string{"dev1"}, nil); err != ErrConfirmationRequired {
+		t.Fatalf("expected ErrConfirmationRequired, got %v", err)
+	}
+	// Without a configured token only dry runs are allowed
+	for _, confirm := range []string{"", ActionContain} {
+		if _, err := h.ContainHosts([]string{"dev1"}, &HostActionOptions{Confirm: confirm}); err != ErrConfirmationRequired {
+			t.Fatalf("expected %q not to confirm, got %v", confirm, err)
+		}
+	}
+	if calls != 0 {
+		t.Fatalf("expected no request before confirmation, got %d", calls)
+	}
+}
+
+func TestActionConfirmationToken(t *testing.T) {
+	var calls int32
+	h := newTestHost(t, actionsHandler(t, &calls, nil), SetActionConfirmation("yes-really"))
+	if _, err := h.ContainHosts([]string{"dev1"}, &HostActionOptions{Confirm: ActionContain}); err != ErrConfirmationRequired {
+		t.Fatalf("expected the action name not to confirm when a token is set, got %v", err)
+	}
+	resp, err := h.ContainHosts([]string{"dev1"}, &HostActionOptions{Confirm: "yes-really"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 || len(resp.Resources) != 1 {
+		t.Fatalf("expected the confirmed action to be sent, got %d calls", calls)
+	}
+}
+
+func TestActionDryRun(t *testing.T) {
+	var calls int32
+	h := newTestHost(t, actionsHandler(t, &calls, nil))
+	resp, err := h.ContainHosts([]string{"dev1", "dev2"}, &HostActionOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 0 || !resp.DryRun {
+		t.Fatalf("expected a dry run without requests, got %d calls", calls)
+	}
+	for _, r := range resp.Results() {
+		if r.Sent || r.Err != nil {
+			t.Fatalf("unexpected result %+v", r)
+		}
+	}
+}
+
+func TestActionConfirmationOptOut(t *testing.T) {
+	var calls int32
+	h := newTestHost(t, actionsHandler(t, &calls, nil), SetRequireActionConfirmation(false))
+	if _, err := h.HideHosts([]string{"dev1"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected the action to be sent, got %d calls", calls)
+	}
+}
+
+func TestActionChunksAndResults(t *testing.T) {
+	var calls int32
+	h := newTestHost(t, actionsHandler(t, &calls, map[string]bool{"dev-120": true}), SetActionConfirmation("yes-really"))
+	ids := make([]string, 150)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("dev-%d", i)
+	}
+	resp, err := h.ContainHosts(ids, &HostActionOptions{Confirm: "yes-really"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Fatalf("expected 2 requests, got %d", calls)
+	}
+	results := resp.Results()
+	if len(results) != 150 || !results[0].Sent || results[120].Sent || results[120].Err == nil {
+		t.Fatalf("unexpected results %+v %+v", results[0], results[120])
+	}
+}
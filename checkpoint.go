@@ -0,0 +1,124 @@
+package gocs
+
+import (
+	"context"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+)
+
+// CheckpointStore persists the position of a long-running consumer so that it resumes where it left off
+// after a restart. A checkpoint is saved only after the items before it were handled.
+// Implementations must be safe for concurrent use.
+type CheckpointStore interface {
+	// Load returns the checkpoint saved under key, or nil if there is none
+	Load(ctx context.Context, key string) ([]byte, error)
+	// Save replaces the checkpoint saved under key
+	Save(ctx context.Context, key string, value []byte) error
+}
+
+// MemoryCheckpointStore keeps the checkpoints in memory.
+// It is useful for tests and for resuming within the same process.
+type MemoryCheckpointStore struct {
+	mu sync.Mutex
+	m  map[string][]byte
+}
+
+// NewMemoryCheckpointStore returns an empty in-memory store
+func NewMemoryCheckpointStore() *MemoryCheckpointStore {
+	return &MemoryCheckpointStore{m: make(map[string][]byte)}
+}
+
+// Load returns the checkpoint saved under key, or nil if there is none
+func (s *MemoryCheckpointStore) Load(ctx context.Context, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.m[key]
+	if !ok {
+		return nil, nil
+	}
+	return append([]byte(nil), v...), nil
+}
+
+// Save replaces the checkpoint saved under key
+func (s *MemoryCheckpointStore) Save(ctx context.Context, key string, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.m[key] = append([]byte(nil), value...)
+	return nil
+}
+
+// FileCheckpointStore keeps each checkpoint in its own file under a directory.
+// Files are replaced atomically and synced with the directory, so a crash never leaves a partial
+// checkpoint behind and a saved checkpoint survives a crash.
+type FileCheckpointStore struct {
+	dir string
+}
+
+// NewFileCheckpointStore returns a store that keeps the checkpoints under dir, creating it if needed
+func NewFileCheckpointStore(dir string) (*FileCheckpointStore, error) {
+	if dir == "" {
+		return nil, ErrMissingParams
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &FileCheckpointStore{dir: dir}, nil
+}
+
+// path returns the file of the key. Keys are hex encoded so they cannot leave the directory.
+func (s *FileCheckpointStore) path(key string) string {
+	return filepath.Join(s.dir, "checkpoint-"+hex.EncodeToString([]byte(key)))
+}
+
+// Load returns the checkpoint saved under key, or nil if there is none
+func (s *FileCheckpointStore) Load(ctx context.Context, key string) ([]byte, error) {
+	b, err := ioutil.ReadFile(s.path(key))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return b, err
+}
+
+// Save replaces the checkpoint saved under key by writing a temporary file and renaming it over the old one
+func (s *FileCheckpointStore) Save(ctx context.Context, key string, value []byte) error {
+	f, err := ioutil.TempFile(s.dir, ".checkpoint-")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	if _, err = f.Write(value); err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, s.path(key))
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return syncDir(s.dir)
+}
+
+// syncDir flushes the directory entries so a rename in it is durable.
+// Windows does not support syncing directories and persists renames on its own.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if closeErr := d.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,175 @@
+package gocs
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemoryCheckpointStore(t *testing.T) {
+	s := NewMemoryCheckpointStore()
+	ctx := context.Background()
+	if b, err := s.Load(ctx, "k"); b != nil || err != nil {
+		t.Fatalf("expected no checkpoint, got %q %v", b, err)
+	}
+	v := []byte("1")
+	s.Save(ctx, "k", v)
+	v[0] = '2'
+	if b, _ := s.Load(ctx, "k"); string(b) != "1" {
+		t.Fatalf("expected the saved value to be copied, got %q", b)
+	}
+}
+
+func TestFileCheckpointStore(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewFileCheckpointStore(filepath.Join(dir, "checkpoints"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if b, err := s.Load(ctx, "stream/app/0"); b != nil || err != nil {
+		t.Fatalf("expected no checkpoint, got %q %v", b, err)
+	}
+	for _, key := range []string{"stream/app/0", ".", "..", "../escape", ""} {
+		if err = s.Save(ctx, key, []byte("v"+key)); err != nil {
+			t.Fatalf("save %q: %v", key, err)
+		}
+		s.Save(ctx, key, []byte(key))
+		if b, err := s.Load(ctx, key); err != nil || string(b) != key {
+			t.Fatalf("unexpected checkpoint of %q - %q %v", key, b, err)
+		}
+	}
+	// Every key has its own file inside the directory and no temporary files are left behind
+	files, _ := ioutil.ReadDir(filepath.Join(dir, "checkpoints"))
+	if len(files) != 5 {
+		t.Fatalf("expected 5 checkpoint files, got %d", len(files))
+	}
+	for _, f := range files {
+		if !strings.HasPrefix(f.Name(), "checkpoint-") {
+			t.Fatalf("unexpected file %s", f.Name())
+		}
+	}
+	if files, _ = ioutil.ReadDir(dir); len(files) != 1 {
+		t.Fatalf("a key escaped the directory - %d entries", len(files))
+	}
+	if _, err = NewFileCheckpointStore(""); err != ErrMissingParams {
+		t.Fatalf("expected ErrMissingParams, got %v", err)
+	}
+}
+
+func TestConsumeStreamCheckpoints(t *testing.T) {
+	store := NewMemoryCheckpointStore()
+	store.Save(context.Background(), "stream/app/0", []byte("5"))
+	var mu sync.Mutex
+	var offsets []string
+	h := newTestHost(t, streamHandler(t, [][]string{{streamEvent(5, "Other", `{}`), streamEvent(6, "Other", `{}`)}}, &mu, &offsets))
+	stop := &Error{Code: "stop", Message: "stop"}
+	err := h.ConsumeStream(context.Background(), &StreamOptions{AppID: "app", Checkpoints: store}, func(ctx context.Context, e *StreamEvent) error {
+		if e.Metadata.Offset == 6 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop {
+		t.Fatalf("expected the handler error, got %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(offsets) != 1 || offsets[0] != "5" {
+		t.Fatalf("expected to resume from the checkpoint, got %v", offsets)
+	}
+	// The event that failed is not checkpointed
+	if b, _ := store.Load(context.Background(), "stream/app/0"); string(b) != "6" {
+		t.Fatalf("unexpected checkpoint %q", b)
+	}
+}
+
+func TestConsumeStreamBadCheckpoint(t *testing.T) {
+	store := NewMemoryCheckpointStore()
+	store.Save(context.Background(), "stream/app/0", []byte("x"))
+	var mu sync.Mutex
+	var offsets []string
+	h := newTestHost(t, streamHandler(t, nil, &mu, &offsets))
+	err := h.ConsumeStream(context.Background(), &StreamOptions{AppID: "app", Checkpoints: store}, func(context.Context, *StreamEvent) error {
+		return nil
+	})
+	if e, ok := err.(*Error); !ok || e.Code != "bad_checkpoint" {
+		t.Fatalf("expected a bad checkpoint error, got %v", err)
+	}
+}
+
+// detectsHandler serves the detections, all updated at the given time
+func detectsHandler(t *testing.T, updated string, ids ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/detects/queries/detects/v1":
+			writeJSON(w, http.StatusOK, map[string]interface{}{
+				"meta":      map[string]interface{}{"pagination": map[string]interface{}{"total": len(ids)}},
+				"resources": ids,
+			})
+		case "/detects/entities/summaries/GET/v1":
+			var detects []map[string]interface{}
+			for _, id := range ids {
+				detects = append(detects, map[string]interface{}{"detection_id": id, "date_updated": updated})
+			}
+			writeJSON(w, http.StatusOK, map[string]interface{}{"resources": detects})
+		default:
+			t.Errorf("unexpected request %s", r.URL)
+		}
+	}
+}
+
+func TestPollDetectsCheckpoint(t *testing.T) {
+	h := newTestHost(t, detectsHandler(t, "2020-03-01T10:00:00Z", "ldt:1", "ldt:2"))
+	store := NewMemoryCheckpointStore()
+	stop := &Error{Code: "stop", Message: "stop"}
+	var handled []string
+	handler := func(ctx context.Context, d *Detection) error {
+		handled = append(handled, d.DetectionID)
+		return stop
+	}
+	opts := &DetectsPollOptions{Checkpoints: store, Interval: time.Millisecond}
+	if err := h.PollDetects(context.Background(), opts, handler); err != stop {
+		t.Fatalf("expected the handler error, got %v", err)
+	}
+	// The failed detection is handled again after a restart
+	if err := h.PollDetects(context.Background(), opts, handler); err != stop {
+		t.Fatalf("expected the handler error, got %v", err)
+	}
+	if len(handled) != 2 || handled[1] != "ldt:1" {
+		t.Fatalf("unexpected detections %v", handled)
+	}
+	store.Save(context.Background(), "detects", []byte(`{"since":"2020-03-01T10:00:00Z","seen":["ldt:1"]}`))
+	if err := h.PollDetects(context.Background(), opts, handler); err != stop || handled[2] != "ldt:2" {
+		t.Fatalf("expected the handled detection to be skipped, got %v %v", err, handled)
+	}
+	b, _ := store.Load(context.Background(), "detects")
+	var cursor detectsCursor
+	if err := json.Unmarshal(b, &cursor); err != nil || len(cursor.Seen) != 1 {
+		t.Fatalf("unexpected cursor %s", b)
+	}
+}
+
+func TestPollDetectsCursor(t *testing.T) {
+	h := newTestHost(t, detectsHandler(t, "2020-03-01T10:00:00Z", "ldt:1", "ldt:2"))
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	var handled []string
+	err := h.PollDetects(ctx, &DetectsPollOptions{Interval: 10 * time.Millisecond}, func(ctx context.Context, d *Detection) error {
+		handled = append(handled, d.DetectionID)
+		return nil
+	})
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected the context error, got %v", err)
+	}
+	// The detections are returned by every poll but handled once
+	if len(handled) != 2 || handled[0] != "ldt:1" || handled[1] != "ldt:2" {
+		t.Fatalf("unexpected detections %v", handled)
+	}
+}
@@ -0,0 +1,81 @@
+package gocs
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Falcon clouds for SetCloud
+const (
+	CloudUS1    = "us-1"     // US-1 - https://api.crowdstrike.com/
+	CloudUS2    = "us-2"     // US-2 - https://api.us-2.crowdstrike.com/
+	CloudEU1    = "eu-1"     // EU-1 - https://api.eu-1.crowdstrike.com/
+	CloudUSGov1 = "us-gov-1" // US-GOV-1 - https://api.laggar.gcw.crowdstrike.com/
+	// CloudAuto discovers the cloud of the tenant when fetching the OAuth2 token
+	CloudAuto = "auto"
+)
+
+// cloudURLs maps the Falcon clouds to their API base URL
+var cloudURLs = map[string]string{
+	CloudUS1:    "https://api.crowdstrike.com/",
+	CloudUS2:    "https://api.us-2.crowdstrike.com/",
+	CloudEU1:    "https://api.eu-1.crowdstrike.com/",
+	CloudUSGov1: "https://api.laggar.gcw.crowdstrike.com/",
+}
+
+// SetCloud sets the URL to the API of the given Falcon cloud.
+//
+// With CloudAuto, the client starts with US-1 and switches to the cloud of the tenant as reported
+// by the X-Cs-Region header (or the redirect) of the token endpoint. This requires SetOAuth2Credentials.
+func SetCloud(region string) OptionFunc {
+	return func(c *client) error {
+		region = strings.ToLower(region)
+		if region == CloudAuto {
+			c.url, c.discover = cloudURLs[CloudUS1], true
+			return nil
+		}
+		u, ok := cloudURLs[region]
+		if !ok {
+			err := &Error{Code: "bad_cloud", Message: fmt.Sprintf("Unknown Falcon cloud [%s]", region)}
+			c.errorf("%v\n", err)
+			return err
+		}
+		c.url, c.discover = u, false
+		return nil
+	}
+}
+
+// baseURL returns the URL the relative API paths are resolved against.
+// With cloud discovery, the OAuth2 token is fetched first so we know where to go.
+func (c *client) baseURL(ctx context.Context) (string, error) {
+	if c.discover {
+		if _, err := c.accessToken(ctx); err != nil {
+			return "", err
+		}
+	}
+	c.urlMu.RLock()
+	defer c.urlMu.RUnlock()
+	return c.url, nil
+}
+
+// discoverCloud switches the base URL according to the token endpoint response
+func (c *client) discoverCloud(resp *http.Response) {
+	u := cloudURLs[strings.ToLower(resp.Header.Get("X-Cs-Region"))]
+	if u == "" && resp.Request != nil {
+		// We were redirected to the right cloud
+		u = resp.Request.URL.Scheme + "://" + resp.Request.URL.Host + "/"
+	}
+	c.urlMu.Lock()
+	defer c.urlMu.Unlock()
+	if u != "" && u != c.url {
+		c.tracef("Switching to cloud URL [%s]\n", u)
+		c.url = u
+	}
+}
+
+// isAbsolute returns true if the URL should not be resolved against the base URL
+func isAbsolute(rawurl string) bool {
+	return strings.HasPrefix(rawurl, "https://") || strings.HasPrefix(rawurl, "http://")
+}
@@ -0,0 +1,98 @@
+package gocs
+
+import (
+	"net/http"
+	"testing"
+)
+
+// setCloudURL points the cloud at a test server for the duration of the test
+func setCloudURL(t *testing.T, region, u string) {
+	old := cloudURLs[region]
+	cloudURLs[region] = u + "/"
+	t.Cleanup(func() { cloudURLs[region] = old })
+}
+
+func TestSetCloud(t *testing.T) {
+	h, err := NewHost(SetCredentials("id", "key"), SetCloud("EU-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.url != "https://api.eu-1.crowdstrike.com/" {
+		t.Fatalf("unexpected URL %s", h.url)
+	}
+	if _, err = NewHost(SetCredentials("id", "key"), SetCloud("mars-1")); err == nil {
+		t.Fatal("expected an error for an unknown cloud")
+	}
+	if _, err = NewHost(SetCredentials("id", "key"), SetCloud(CloudAuto)); err == nil {
+		t.Fatal("expected discovery to require OAuth2 credentials")
+	}
+}
+
+func TestCloudDiscoveryByHeader(t *testing.T) {
+	tenant := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/devices/queries/devices/v1" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"resources": []string{"a"}})
+	})
+	us1 := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/oauth2/token" {
+			t.Errorf("unexpected request to the default cloud %s", r.URL.Path)
+			return
+		}
+		w.Header().Set("X-Cs-Region", "eu-1")
+		writeJSON(w, http.StatusCreated, tokenResponse{AccessToken: "token", ExpiresIn: 1799})
+	})
+	setCloudURL(t, CloudUS1, us1.URL)
+	setCloudURL(t, CloudEU1, tenant.URL)
+
+	h, err := NewHost(SetOAuth2Credentials("client", "secret"), SetCloud(CloudAuto))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := h.DeviceSearch("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Resources) != 1 || h.currentURL() != tenant.URL+"/" {
+		t.Fatalf("expected to switch to the tenant cloud, got %s", h.currentURL())
+	}
+}
+
+func TestCloudDiscoveryByRedirect(t *testing.T) {
+	tenant := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/oauth2/token" {
+			if r.FormValue("client_id") != "client" {
+				t.Errorf("the redirected token request lost its body")
+			}
+			writeJSON(w, http.StatusCreated, tokenResponse{AccessToken: "token", ExpiresIn: 1799})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"resources": []string{"a"}})
+	})
+	us1 := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, tenant.URL+r.URL.Path, http.StatusPermanentRedirect)
+	})
+	setCloudURL(t, CloudUS1, us1.URL)
+
+	h, err := NewHost(SetOAuth2Credentials("client", "secret"), SetCloud(CloudAuto))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = h.DeviceSearch("", ""); err != nil {
+		t.Fatal(err)
+	}
+	if h.currentURL() != tenant.URL+"/" {
+		t.Fatalf("expected to follow the redirect to the tenant cloud, got %s", h.currentURL())
+	}
+}
+
+func TestSetURLOverridesCloud(t *testing.T) {
+	h, err := NewHost(SetOAuth2Credentials("client", "secret"), SetCloud(CloudAuto), SetURL("http://localhost:1234"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.discover || h.url != "http://localhost:1234/" {
+		t.Fatalf("expected SetURL to disable discovery, got %s %v", h.url, h.discover)
+	}
+}
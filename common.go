@@ -1,28 +1,55 @@
 package gocs
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
+	"math"
+	"mime/multipart"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 // Error structs are returned from this library for known error conditions
 type Error struct {
-	Code    string `json:"code"`    // Code of the error
-	Message string `json:"message"` // Message of the error
+	Code    string `json:"code"`         // Code of the error
+	Message string `json:"message"`      // Message of the error
+	ID      string `json:"id,omitempty"` // ID of the item the error refers to, if provided by the API
 }
 
 func (e *Error) Error() string {
 	return fmt.Sprintf("%s: %s", e.Code, e.Message)
 }
 
+// UnmarshalJSON accepts both the string codes and the numeric codes returned by the Host API
+func (e *Error) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Code    json.RawMessage `json:"code"`
+		Message string          `json:"message"`
+		ID      string          `json:"id"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	e.Message, e.ID = raw.Message, raw.ID
+	e.Code = ""
+	if len(raw.Code) > 0 && string(raw.Code) != "null" {
+		if err := json.Unmarshal(raw.Code, &e.Code); err != nil {
+			// Not a string so keep the number as is
+			e.Code = string(raw.Code)
+		}
+	}
+	return nil
+}
+
 var (
 	// ErrMissingCredentials is returned when API key is missing
 	ErrMissingCredentials = &Error{Code: "missing_credentials", Message: "You must provide the CrowsStrike API ID and key"}
@@ -32,12 +59,20 @@ var (
 
 // client interacts with the services provided by CrowdStrike.
 type client struct {
-	id       string       // The API ID
-	key      string       // The API key
-	url      string       // CS URL
-	errorlog *log.Logger  // Optional logger to write errors to
-	tracelog *log.Logger  // Optional logger to write trace and debug data to
-	c        *http.Client // The client to use for requests
+	id        string       // The API ID
+	key       string       // The API key
+	url       string       // CS URL
+	urlMu     sync.RWMutex // Guards url when it is discovered
+	discover  bool         // Should we discover the cloud of the tenant
+	errorlog  *log.Logger  // Optional logger to write errors to
+	tracelog  *log.Logger  // Optional logger to write trace and debug data to
+	c         *http.Client // The client to use for requests
+	retry     *RetryPolicy // Optional policy for retrying failed requests
+	limiter   *rateLimiter // Tracks the API request budget
+	oauth     *oauth2Token // OAuth2 token if we are using client credentials instead of the API key
+	strict    bool         // Should per-item errors in successful responses fail the call
+	confirm   string       // Token required for host actions, only dry runs are allowed if empty
+	noConfirm bool         // Host actions are sent without confirmation
 }
 
 // OptionFunc is a function that configures a Client.
@@ -61,7 +96,8 @@ func (c *client) tracef(format string, args ...interface{}) {
 func newClient(options ...OptionFunc) (*client, error) {
 	// Set up the client
 	c := &client{
-		c: http.DefaultClient,
+		c:       http.DefaultClient,
+		limiter: &rateLimiter{},
 	}
 
 	// Run the options on it
@@ -76,6 +112,11 @@ func newClient(options ...OptionFunc) (*client, error) {
 		c.errorf("Missing credentials")
 		return nil, ErrMissingCredentials
 	}
+	if c.discover && c.oauth == nil {
+		err := &Error{Code: "bad_cloud", Message: "Cloud discovery requires OAuth2 credentials"}
+		c.errorf("%v\n", err)
+		return nil, err
+	}
 	return c, nil
 }
 
@@ -123,7 +164,7 @@ func SetURL(rawurl string) OptionFunc {
 			c.errorf("%v", err)
 			return err
 		}
-		c.url = rawurl
+		c.url, c.discover = rawurl, false
 		if !strings.HasSuffix(c.url, "/") {
 			c.url += "/"
 		}
@@ -160,8 +201,14 @@ func (c *client) dumpRequest(req *http.Request) {
 
 // dumpResponse dumps a response to the debug logger if it was defined
 func (c *client) dumpResponse(resp *http.Response) {
+	c.dumpResponseBody(resp, true)
+}
+
+// dumpResponseBody dumps a response to the debug logger if it was defined.
+// Streamed responses should not dump the body as that reads all of it to memory.
+func (c *client) dumpResponseBody(resp *http.Response, body bool) {
 	if c.tracelog != nil {
-		out, err := httputil.DumpResponse(resp, true)
+		out, err := httputil.DumpResponse(resp, body)
 		if err == nil {
 			c.tracef("%s\n", string(out))
 		}
@@ -179,69 +226,282 @@ func (c *client) handleError(resp *http.Response) error {
 				c.errorf("%s\n", string(out))
 			}
 		}
-		msg := fmt.Sprintf("Unexpected status code: %d (%s)", resp.StatusCode, http.StatusText(resp.StatusCode))
-		c.errorf(msg)
-		return &Error{Code: "http_error", Message: msg}
+		err := newAPIError(resp)
+		c.errorf("%v\n", err)
+		return err
 	}
 	return nil
 }
 
-// do executes the API request.
-// Returns the response if the status code is between 200 and 299
-// `body` is an optional body for the POST requests.
-func (c *client) do(method, rawurl string, params url.Values, body io.Reader, result interface{}, authFunc func(*http.Request)) error {
+// send executes the API request, retrying it according to the retry policy.
+// Returns the response if the status code is between 200 and 299, or 304 for conditional requests -
+// the caller must close its body.
+// `body` is an optional body for the POST requests. It is sent as JSON unless it is a *typedBody
+// or header specifies a Content-Type.
+// `rawurl` is resolved against the base URL unless it is absolute.
+// `header` holds optional additional request headers.
+// The request is bound to ctx - if ctx is done before the response arrives, ctx.Err() is returned.
+func (c *client) send(ctx context.Context, method, rawurl string, params url.Values, body io.Reader, header http.Header, authFunc func(*http.Request) error) (*http.Response, error) {
 	if len(params) > 0 {
 		rawurl += "?" + params.Encode()
 	}
 
-	req, err := http.NewRequest(method, c.url+rawurl, body)
+	if !isAbsolute(rawurl) {
+		base, err := c.baseURL(ctx)
+		if err != nil {
+			return nil, err
+		}
+		rawurl = base + rawurl
+	}
+	contentType := "application/json"
+	if tb, ok := body.(*typedBody); ok {
+		body, contentType = tb.Reader, tb.contentType
+	}
+	req, err := http.NewRequest(method, rawurl, body)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	req = req.WithContext(ctx)
 	req.Header.Set("Accept", "application/json")
-	authFunc(req)
-	var t time.Time
-	if c.tracelog != nil {
-		c.dumpRequest(req)
-		t = time.Now()
-		c.tracef("Start request %s at %v", rawurl, t)
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
 	}
-	resp, err := c.c.Do(req)
-	if c.tracelog != nil {
-		c.tracef("End request %s at %v - took %v", rawurl, time.Now(), time.Since(t))
+	for k, v := range header {
+		req.Header[k] = v
 	}
-	if err != nil {
-		return err
+	rateLimited, reauthorized := 0, false
+	for attempt, sent := 0, 0; ; attempt, sent = attempt+1, sent+1 {
+		if sent > 0 && req.GetBody != nil {
+			if req.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+		if err = authFunc(req); err != nil {
+			return nil, err
+		}
+		if err = c.limiter.wait(ctx); err != nil {
+			return nil, err
+		}
+		var t time.Time
+		if c.tracelog != nil {
+			c.dumpRequest(req)
+			t = time.Now()
+			c.tracef("Start request %s at %v", rawurl, t)
+		}
+		resp, err := c.c.Do(req)
+		if c.tracelog != nil {
+			c.tracef("End request %s at %v - took %v", rawurl, time.Now(), time.Since(t))
+		}
+		if err != nil {
+			// Prefer the context error over the wrapped transport error
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, ctxErr
+			}
+			if wait, ok := c.retryWait(req, nil, attempt); ok {
+				c.tracef("Request %s failed (%v) - retrying in %v", rawurl, err, wait)
+				if err = sleepContext(ctx, wait); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			return nil, err
+		}
+		c.limiter.update(resp)
+		if resp.StatusCode == http.StatusTooManyRequests && c.limiter.throttle && rateLimited < maxRateLimitWaits && canResend(req) {
+			c.tracef("Request %s was rate limited - waiting for the limit to reset", rawurl)
+			rateLimited++
+			attempt--
+			c.limiter.exhausted(resp)
+			drainBody(resp)
+			continue
+		}
+		// The token might have been revoked or expired early - get a fresh one and try once more
+		if resp.StatusCode == http.StatusUnauthorized && c.oauth != nil && !reauthorized && canResend(req) {
+			if token := bearerToken(req); token != "" {
+				c.tracef("Request %s was unauthorized - refreshing the OAuth2 token", rawurl)
+				reauthorized = true
+				attempt--
+				c.oauth.invalidate(token)
+				drainBody(resp)
+				continue
+			}
+		}
+		if wait, ok := c.retryWait(req, resp, attempt); ok {
+			c.tracef("Request %s returned %d - retrying in %v", rawurl, resp.StatusCode, wait)
+			drainBody(resp)
+			if err = sleepContext(ctx, wait); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if resp.StatusCode == http.StatusNotModified && isConditional(req) {
+			return resp, nil
+		}
+		if err = c.handleError(resp); err != nil {
+			drainBody(resp)
+			return nil, err
+		}
+		return resp, nil
 	}
-	if resp.Body != nil {
-		defer resp.Body.Close()
+}
+
+// isConditional returns true if the request asks for the resource only if it changed
+func isConditional(req *http.Request) bool {
+	return req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != ""
+}
+
+// do executes the API request and decodes the response into result.
+// If result is an io.Writer, the response body is streamed to it as is.
+func (c *client) do(ctx context.Context, method, rawurl string, params url.Values, body io.Reader, result interface{}, authFunc func(*http.Request) error) error {
+	if w, ok := result.(io.Writer); ok {
+		return c.stream(ctx, method, rawurl, params, body, nil, w, authFunc)
 	}
-	if err = c.handleError(resp); err != nil {
+	resp, err := c.send(ctx, method, rawurl, params, body, nil, authFunc)
+	if err != nil {
 		return err
 	}
+	defer resp.Body.Close()
 	c.dumpResponse(resp)
 	if result != nil {
-		switch result := result.(type) {
-		// Should we just dump the response body
-		case io.Writer:
-			if _, err = io.Copy(result, resp.Body); err != nil {
-				return err
+		if err = json.NewDecoder(resp.Body).Decode(result); err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
 			}
-		default:
-			if err = json.NewDecoder(resp.Body).Decode(result); err != nil {
-				if c.errorlog != nil {
-					out, err := httputil.DumpResponse(resp, true)
-					if err == nil {
-						c.errorf("%s\n", string(out))
-					}
+			if c.errorlog != nil {
+				out, err := httputil.DumpResponse(resp, true)
+				if err == nil {
+					c.errorf("%s\n", string(out))
 				}
-				return err
 			}
+			return err
 		}
 	}
 	return nil
 }
 
+// typedBody is a request body with a content type other than JSON
+type typedBody struct {
+	io.Reader
+	contentType string
+}
+
+// multipartFile is a file field in a multipart body
+type multipartFile struct {
+	field    string
+	filename string
+	r        io.Reader
+}
+
+// multipartBody encodes the fields and the optional file as a multipart/form-data body.
+// The body is buffered so the request can be retried.
+func multipartBody(fields url.Values, file *multipartFile) (*typedBody, error) {
+	var b bytes.Buffer
+	mw := multipart.NewWriter(&b)
+	for name, values := range fields {
+		for _, v := range values {
+			if err := mw.WriteField(name, v); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if file != nil {
+		fw, err := mw.CreateFormFile(file.field, file.filename)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = io.Copy(fw, file.r); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return &typedBody{Reader: &b, contentType: mw.FormDataContentType()}, nil
+}
+
+// stream executes the API request and copies the response body to w as it arrives,
+// without holding it in memory.
+func (c *client) stream(ctx context.Context, method, rawurl string, params url.Values, body io.Reader, header http.Header, w io.Writer, authFunc func(*http.Request) error) error {
+	resp, err := c.send(ctx, method, rawurl, params, body, header, authFunc)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	c.dumpResponseBody(resp, false)
+	if _, err = io.Copy(w, resp.Body); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return err
+	}
+	return nil
+}
+
+// timestamp decodes the time formats used by the APIs - epoch seconds, possibly fractional, either as
+// numbers or strings, and RFC3339 strings. Zero epochs, empty strings and null decode to the zero time.
+type timestamp struct {
+	time.Time
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (t *timestamp) UnmarshalJSON(b []byte) error {
+	s := string(b)
+	if s == "null" {
+		t.Time = time.Time{}
+		return nil
+	}
+	if strings.HasPrefix(s, `"`) {
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+		if s == "" {
+			t.Time = time.Time{}
+			return nil
+		}
+		if _, err := strconv.ParseFloat(s, 64); err != nil {
+			parsed, err := time.Parse(time.RFC3339Nano, s)
+			if err != nil {
+				return &Error{Code: "bad_timestamp", Message: fmt.Sprintf("Invalid timestamp [%s]", s)}
+			}
+			t.Time = parsed
+			return nil
+		}
+	}
+	epoch, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return &Error{Code: "bad_timestamp", Message: fmt.Sprintf("Invalid timestamp [%s]", b)}
+	}
+	t.Time = epochTime(epoch)
+	return nil
+}
+
+// epoch returns the time in fractional seconds since the epoch, 0 for the zero time
+func (t timestamp) epoch() float64 {
+	if t.IsZero() {
+		return 0
+	}
+	return float64(t.UnixNano()) / float64(time.Second)
+}
+
+// firstTime returns the first of the timestamps that is set
+func firstTime(ts ...timestamp) time.Time {
+	for _, t := range ts {
+		if !t.IsZero() {
+			return t.Time
+		}
+	}
+	return time.Time{}
+}
+
+// epochTime converts fractional seconds since the epoch to a time, 0 to the zero time
+func epochTime(epoch float64) time.Time {
+	if epoch == 0 {
+		return time.Time{}
+	}
+	sec, frac := math.Modf(epoch)
+	return time.Unix(int64(sec), int64(math.Round(frac*float64(time.Second))))
+}
+
 // Common structs
 
 // SortField ...
@@ -256,6 +516,28 @@ type Paging struct {
 	Limit  int `json:"limit"`
 }
 
+// QueryRequest is a filtered, sorted and paged query used by the entity APIs
+type QueryRequest struct {
+	Filter string     `json:"filter"` // FQL filter
+	Sort   *SortField `json:"sort"`
+	Paging
+}
+
+func queryRequestToParams(req *QueryRequest) url.Values {
+	params := url.Values{}
+	addString("filter", req.Filter, params)
+	if req.Sort != nil {
+		addSortFields("sort", []SortField{*req.Sort}, params)
+	}
+	if req.Limit != 0 {
+		addInt("limit", req.Limit, params)
+	}
+	if req.Offset != 0 {
+		addInt("offset", req.Offset, params)
+	}
+	return params
+}
+
 func addString(name, val string, params url.Values) {
 	if val != "" {
 		params.Add(name, val)
@@ -274,6 +556,18 @@ func addStringArr(name string, val []string, params url.Values) {
 	}
 }
 
+// addPipeSort adds the sort in the field|order format used by the newer APIs
+func addPipeSort(name string, sort *SortField, params url.Values) {
+	if sort == nil {
+		return
+	}
+	order := "desc"
+	if sort.Ascending {
+		order = "asc"
+	}
+	params.Set(name, sort.Name+"|"+order)
+}
+
 func addSortFields(name string, sortFields []SortField, params url.Values) {
 	for i := range sortFields {
 		val := sortFields[i].Name + "."
@@ -0,0 +1,487 @@
+package gocs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/demisto/gocs/fql"
+)
+
+// QueryDetectsRequest searches for detections
+type QueryDetectsRequest struct {
+	Filter string     `json:"filter"` // FQL filter, e.g. status:'new'+max_severity:>=50
+	Query  string     `json:"q"`      // Free text search across all the fields
+	Sort   *SortField `json:"sort"`
+	Paging
+}
+
+// DetectionDevice is the host a detection happened on
+type DetectionDevice struct {
+	DeviceID           string    `json:"device_id"`
+	CID                string    `json:"cid"`
+	AgentLoadFlags     string    `json:"agent_load_flags"`
+	AgentLocalTime     string    `json:"agent_local_time"`
+	AgentVersion       string    `json:"agent_version"`
+	BIOSManufacturer   string    `json:"bios_manufacturer"`
+	BIOSVersion        string    `json:"bios_version"`
+	ConfigIDBase       string    `json:"config_id_base"`
+	ConfigIDBuild      string    `json:"config_id_build"`
+	ConfigIDPlatform   string    `json:"config_id_platform"`
+	ExternalIP         string    `json:"external_ip"`
+	Hostname           string    `json:"hostname"`
+	FirstSeen          time.Time `json:"first_seen"`
+	LastSeen           time.Time `json:"last_seen"`
+	LocalIP            string    `json:"local_ip"`
+	MACAddress         string    `json:"mac_address"`
+	MajorVersion       string    `json:"major_version"`
+	MinorVersion       string    `json:"minor_version"`
+	OSVersion          string    `json:"os_version"`
+	PlatformID         string    `json:"platform_id"`
+	PlatformName       string    `json:"platform_name"`
+	ProductType        string    `json:"product_type"`
+	ProductTypeDesc    string    `json:"product_type_desc"`
+	Status             string    `json:"status"`
+	SystemManufacturer string    `json:"system_manufacturer"`
+	SystemProductName  string    `json:"system_product_name"`
+	ModifiedTimestamp  time.Time `json:"modified_timestamp"`
+	Groups             []string  `json:"groups"`
+}
+
+// UnmarshalJSON decodes the dates from epoch seconds or RFC3339 strings
+func (d *DetectionDevice) UnmarshalJSON(b []byte) error {
+	type detectionDevice DetectionDevice
+	aux := &struct {
+		*detectionDevice
+		FirstSeen         timestamp `json:"first_seen"`
+		LastSeen          timestamp `json:"last_seen"`
+		ModifiedTimestamp timestamp `json:"modified_timestamp"`
+	}{detectionDevice: (*detectionDevice)(d)}
+	if err := json.Unmarshal(b, aux); err != nil {
+		return err
+	}
+	d.FirstSeen = aux.FirstSeen.Time
+	d.LastSeen = aux.LastSeen.Time
+	d.ModifiedTimestamp = aux.ModifiedTimestamp.Time
+	return nil
+}
+
+// ParentDetails of the process that triggered a behavior
+type ParentDetails struct {
+	ParentSHA256         string `json:"parent_sha256"`
+	ParentMD5            string `json:"parent_md5"`
+	ParentCommandLine    string `json:"parent_cmdline"`
+	ParentProcessGraphID string `json:"parent_process_graph_id"`
+}
+
+// Behavior is a single malicious behavior that is part of a detection
+type Behavior struct {
+	DeviceID                  string          `json:"device_id"`
+	Timestamp                 time.Time       `json:"timestamp"`
+	TemplateInstanceID        string          `json:"template_instance_id"`
+	BehaviorID                string          `json:"behavior_id"`
+	FileName                  string          `json:"filename"`
+	FilePath                  string          `json:"filepath"`
+	AllegedFileType           string          `json:"alleged_filetype"`
+	CommandLine               string          `json:"cmdline"`
+	Scenario                  string          `json:"scenario"`
+	Objective                 string          `json:"objective"`
+	Tactic                    string          `json:"tactic"`
+	TacticID                  string          `json:"tactic_id"`
+	Technique                 string          `json:"technique"`
+	TechniqueID               string          `json:"technique_id"`
+	DisplayName               string          `json:"display_name"`
+	Description               string          `json:"description"`
+	Severity                  int             `json:"severity"`
+	Confidence                int             `json:"confidence"`
+	IOCType                   string          `json:"ioc_type"`
+	IOCValue                  string          `json:"ioc_value"`
+	IOCSource                 string          `json:"ioc_source"`
+	IOCDescription            string          `json:"ioc_description"`
+	UserName                  string          `json:"user_name"`
+	UserID                    string          `json:"user_id"`
+	ControlGraphID            string          `json:"control_graph_id"`
+	TriggeringProcessGraphID  string          `json:"triggering_process_graph_id"`
+	SHA256                    string          `json:"sha256"`
+	MD5                       string          `json:"md5"`
+	ParentDetails             ParentDetails   `json:"parent_details"`
+	PatternDisposition        int             `json:"pattern_disposition"`
+	PatternDispositionDetails map[string]bool `json:"pattern_disposition_details"`
+}
+
+// UnmarshalJSON decodes the dates from epoch seconds or RFC3339 strings
+func (bh *Behavior) UnmarshalJSON(b []byte) error {
+	type behavior Behavior
+	aux := &struct {
+		*behavior
+		Timestamp timestamp `json:"timestamp"`
+	}{behavior: (*behavior)(bh)}
+	if err := json.Unmarshal(b, aux); err != nil {
+		return err
+	}
+	bh.Timestamp = aux.Timestamp.Time
+	return nil
+}
+
+// HostInfo holds the directory information of the host
+type HostInfo struct {
+	Domain                   string   `json:"domain"`
+	ActiveDirectoryDNDisplay []string `json:"active_directory_dn_display"`
+}
+
+// Detection is a detection summary with its behaviors
+type Detection struct {
+	CID                    string          `json:"cid"`
+	DetectionID            string          `json:"detection_id"`
+	CreatedTimestamp       time.Time       `json:"created_timestamp"`
+	Device                 DetectionDevice `json:"device"`
+	Behaviors              []Behavior      `json:"behaviors"`
+	BehaviorsProcessed     []string        `json:"behaviors_processed"`
+	EmailSent              bool            `json:"email_sent"`
+	FirstBehavior          time.Time       `json:"first_behavior"`
+	LastBehavior           time.Time       `json:"last_behavior"`
+	MaxConfidence          int             `json:"max_confidence"`
+	MaxSeverity            int             `json:"max_severity"`
+	MaxSeverityDisplayName string          `json:"max_severity_displayname"`
+	ShowInUI               bool            `json:"show_in_ui"`
+	Status                 string          `json:"status"`
+	HostInfo               HostInfo        `json:"hostinfo"`
+	SecondsToTriaged       int             `json:"seconds_to_triaged"`
+	SecondsToResolved      int             `json:"seconds_to_resolved"`
+	AssignedToName         string          `json:"assigned_to_name"`
+	AssignedToUID          string          `json:"assigned_to_uid"`
+	DateUpdated            time.Time       `json:"date_updated"`
+}
+
+// UnmarshalJSON decodes the dates from epoch seconds or RFC3339 strings
+func (d *Detection) UnmarshalJSON(b []byte) error {
+	type detection Detection
+	aux := &struct {
+		*detection
+		CreatedTimestamp timestamp `json:"created_timestamp"`
+		FirstBehavior    timestamp `json:"first_behavior"`
+		LastBehavior     timestamp `json:"last_behavior"`
+		DateUpdated      timestamp `json:"date_updated"`
+	}{detection: (*detection)(d)}
+	if err := json.Unmarshal(b, aux); err != nil {
+		return err
+	}
+	d.CreatedTimestamp = aux.CreatedTimestamp.Time
+	d.FirstBehavior = aux.FirstBehavior.Time
+	d.LastBehavior = aux.LastBehavior.Time
+	d.DateUpdated = aux.DateUpdated.Time
+	return nil
+}
+
+// DetectsResponse is returned from GetDetectSummaries
+type DetectsResponse struct {
+	Meta struct {
+		QueryTime float64 `json:"query_time"`
+		TraceID   string  `json:"trace_id"`
+	} `json:"meta"`
+	Resources []Detection `json:"resources"`
+	Errors    []Error     `json:"errors"`
+	items     []string
+}
+
+// Err returns a *PartialError if the response carries per-item errors, nil otherwise
+func (r *DetectsResponse) Err() error {
+	return newPartialError(r.items, r.Errors)
+}
+
+func queryDetectsRequestToParams(req *QueryDetectsRequest) url.Values {
+	params := url.Values{}
+	addString("filter", req.Filter, params)
+	addString("q", req.Query, params)
+	if req.Sort != nil {
+		addSortFields("sort", []SortField{*req.Sort}, params)
+	}
+	if req.Limit != 0 {
+		addInt("limit", req.Limit, params)
+	}
+	if req.Offset != 0 {
+		addInt("offset", req.Offset, params)
+	}
+	return params
+}
+
+// idsBody encodes the IDs as the JSON body expected by the entity APIs
+func idsBody(ids []string) (*bytes.Buffer, error) {
+	var b bytes.Buffer
+	err := json.NewEncoder(&b).Encode(struct {
+		IDs []string `json:"ids"`
+	}{IDs: ids})
+	return &b, err
+}
+
+// QueryDetects returns a page of detection IDs matching the request
+func (h *Host) QueryDetects(req *QueryDetectsRequest) (resp *QueryResponse, err error) {
+	return h.QueryDetectsContext(context.Background(), req)
+}
+
+// QueryDetectsContext is like QueryDetects with a context for cancellation and deadlines
+func (h *Host) QueryDetectsContext(ctx context.Context, req *QueryDetectsRequest) (resp *QueryResponse, err error) {
+	resp = &QueryResponse{}
+	params := queryDetectsRequestToParams(req)
+	err = h.do(ctx, "GET", "detects/queries/detects/v1", params, nil, resp, h.authFunc())
+	if err == nil {
+		err = h.partial(resp.Err())
+	}
+	return
+}
+
+// QueryDetectsAll returns a pager over all the detection IDs matching the request starting at its offset.
+// The request limit is used as the page size. If max is positive, at most max IDs are returned.
+func (h *Host) QueryDetectsAll(req *QueryDetectsRequest, max int) *IDPager {
+	return h.QueryDetectsAllContext(context.Background(), req, max)
+}
+
+// QueryDetectsAllContext is like QueryDetectsAll with a context for cancellation and deadlines
+func (h *Host) QueryDetectsAllContext(ctx context.Context, req *QueryDetectsRequest, max int) *IDPager {
+	r := *req
+	p := &IDPager{}
+	p.pager = newPager(ctx, r.Offset, max, queryPage(&p.ids, func(ctx context.Context, offset int, _ string) (*QueryResponse, error) {
+		r.Offset = offset
+		return h.QueryDetectsContext(ctx, &r)
+	}))
+	return p
+}
+
+// GetDetectSummaries returns the detections with the given IDs
+func (h *Host) GetDetectSummaries(ids []string) (resp *DetectsResponse, err error) {
+	return h.GetDetectSummariesContext(context.Background(), ids)
+}
+
+// GetDetectSummariesContext is like GetDetectSummaries with a context for cancellation and deadlines
+func (h *Host) GetDetectSummariesContext(ctx context.Context, ids []string) (resp *DetectsResponse, err error) {
+	if len(ids) == 0 {
+		return nil, ErrMissingParams
+	}
+	resp = &DetectsResponse{items: ids}
+	b, err := idsBody(ids)
+	if err != nil {
+		return
+	}
+	err = h.do(ctx, "POST", "detects/entities/summaries/GET/v1", nil, b, resp, h.authFunc())
+	if err == nil {
+		err = h.partial(resp.Err())
+	}
+	return
+}
+
+// Detection statuses for UpdateDetects
+const (
+	DetectStatusNew           = "new"
+	DetectStatusInProgress    = "in_progress"
+	DetectStatusTruePositive  = "true_positive"
+	DetectStatusFalsePositive = "false_positive"
+	DetectStatusIgnored       = "ignored"
+	DetectStatusClosed        = "closed"
+	DetectStatusReopened      = "reopened"
+)
+
+// maxDetectsPerUpdate is the number of IDs the update API accepts in a single request
+const maxDetectsPerUpdate = 1000
+
+// UpdateDetectsRequest updates the state of detections. Only the fields that are set are updated.
+type UpdateDetectsRequest struct {
+	IDs            []string `json:"ids"`
+	Status         string   `json:"status,omitempty"`           // One of the DetectStatus constants
+	AssignedToUUID string   `json:"assigned_to_uuid,omitempty"` // The user to assign the detections to
+	Comment        string   `json:"comment,omitempty"`          // Comment for the audit log
+	ShowInUI       *bool    `json:"show_in_ui,omitempty"`       // Set to false to hide the detections
+}
+
+func (req *UpdateDetectsRequest) validate() error {
+	if len(req.IDs) == 0 || (req.Status == "" && req.AssignedToUUID == "" && req.Comment == "" && req.ShowInUI == nil) {
+		return ErrMissingParams
+	}
+	switch req.Status {
+	case "", DetectStatusNew, DetectStatusInProgress, DetectStatusTruePositive, DetectStatusFalsePositive,
+		DetectStatusIgnored, DetectStatusClosed, DetectStatusReopened:
+		return nil
+	}
+	return &Error{Code: "bad_status", Message: fmt.Sprintf("Invalid detection status [%s]", req.Status)}
+}
+
+// UpdateDetects updates the status, assignment, comment and visibility of detections.
+// Large ID lists are split into several requests and the results are combined.
+func (h *Host) UpdateDetects(req *UpdateDetectsRequest) (resp *ResolveResponse, err error) {
+	return h.UpdateDetectsContext(context.Background(), req)
+}
+
+// UpdateDetectsContext is like UpdateDetects with a context for cancellation and deadlines
+func (h *Host) UpdateDetectsContext(ctx context.Context, req *UpdateDetectsRequest) (resp *ResolveResponse, err error) {
+	if err = req.validate(); err != nil {
+		h.errorf("%v\n", err)
+		return nil, err
+	}
+	resp = &ResolveResponse{items: req.IDs}
+	for _, chunk := range chunks(req.IDs, maxDetectsPerUpdate) {
+		batch := *req
+		batch.IDs = chunk
+		var b bytes.Buffer
+		if err = json.NewEncoder(&b).Encode(&batch); err != nil {
+			return
+		}
+		r := &ResolveResponse{}
+		if err = h.do(ctx, "PATCH", "detects/entities/detects/v2", nil, &b, r, h.authFunc()); err != nil {
+			return
+		}
+		resp.Meta.QueryTime += r.Meta.QueryTime
+		resp.Meta.TraceID = r.Meta.TraceID
+		resp.Meta.Writes.ResourcesAffected += r.Meta.Writes.ResourcesAffected
+		resp.Errors = append(resp.Errors, r.Errors...)
+	}
+	err = h.partial(resp.Err())
+	return
+}
+
+const (
+	// DefaultDetectsPollInterval is how often PollDetects checks for updated detections
+	DefaultDetectsPollInterval = time.Minute
+	// maxDetectsPerSummary is the number of IDs we ask summaries for in a single request
+	maxDetectsPerSummary = 1000
+)
+
+// DetectsPollOptions control PollDetects
+type DetectsPollOptions struct {
+	Filter   string        // Optional FQL filter to narrow the detections, e.g. max_severity:>=50
+	Since    time.Time     // Only detections updated at or after this time. Ignored if there is a checkpoint.
+	Interval time.Duration // How often to poll. Defaults to DefaultDetectsPollInterval.
+	// Checkpoints optionally persists the position after each handled detection, so a restarted poller
+	// resumes after the last handled detection
+	Checkpoints CheckpointStore
+	Key         string // Key of the checkpoint. Defaults to "detects".
+}
+
+// detectsCursor is the position of PollDetects - the last update time handled and the detections
+// handled with exactly that time, as more detections might still arrive with it
+type detectsCursor struct {
+	Since time.Time `json:"since"`
+	Seen  []string  `json:"seen"`
+}
+
+// advance moves the cursor past the detection
+func (c *detectsCursor) advance(d *Detection) {
+	if d.DateUpdated.After(c.Since) {
+		c.Since, c.Seen = d.DateUpdated, nil
+	}
+	c.Seen = append(c.Seen, d.DetectionID)
+}
+
+// seen returns true if the detection was already handled
+func (c *detectsCursor) seen(d *Detection) bool {
+	if d.DateUpdated.Before(c.Since) {
+		return true
+	}
+	if d.DateUpdated.Equal(c.Since) {
+		for _, id := range c.Seen {
+			if id == d.DetectionID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// PollDetects calls handler for every detection created or updated since the last poll, in update order.
+// A detection is handled again whenever it is updated. PollDetects blocks until ctx is done, returning
+// ctx.Err(), or until handler or the checkpoint store return an error, which is returned.
+// Failed polls are logged and retried on the next interval.
+func (h *Host) PollDetects(ctx context.Context, opts *DetectsPollOptions, handler func(ctx context.Context, d *Detection) error) error {
+	if opts == nil || handler == nil {
+		return ErrMissingParams
+	}
+	if err := fql.Validate(opts.Filter); err != nil {
+		h.errorf("%v\n", err)
+		return err
+	}
+	interval, key := opts.Interval, opts.Key
+	if interval <= 0 {
+		interval = DefaultDetectsPollInterval
+	}
+	if key == "" {
+		key = "detects"
+	}
+	cursor := &detectsCursor{Since: opts.Since}
+	if opts.Checkpoints != nil {
+		b, err := opts.Checkpoints.Load(ctx, key)
+		if err != nil {
+			return err
+		}
+		if b != nil {
+			if err = json.Unmarshal(b, cursor); err != nil {
+				return err
+			}
+		}
+	}
+	for {
+		detects, err := h.pollDetects(ctx, opts.Filter, cursor.Since)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			h.errorf("Failed to poll detections - %v\n", err)
+		}
+		for i := range detects {
+			d := &detects[i]
+			if cursor.seen(d) {
+				continue
+			}
+			if err = handler(ctx, d); err != nil {
+				return err
+			}
+			cursor.advance(d)
+			if opts.Checkpoints != nil {
+				b, err := json.Marshal(cursor)
+				if err != nil {
+					return err
+				}
+				if err = opts.Checkpoints.Save(ctx, key, b); err != nil {
+					return err
+				}
+			}
+		}
+		if err = sleepContext(ctx, interval); err != nil {
+			return err
+		}
+	}
+}
+
+// pollDetects returns the detections updated at or after since, oldest first
+func (h *Host) pollDetects(ctx context.Context, filter string, since time.Time) ([]Detection, error) {
+	f, err := fql.Parse(filter)
+	if err != nil {
+		return nil, err
+	}
+	// The time is truncated to seconds - the detections that were already handled are skipped by the cursor
+	if !since.IsZero() {
+		f = fql.And(fql.Gte("date_updated", since), f)
+	}
+	req := &QueryDetectsRequest{Filter: f.String(), Sort: &SortField{Name: "date_updated", Ascending: true}}
+	var ids []string
+	p := h.QueryDetectsAllContext(ctx, req, 0)
+	for p.Next() {
+		ids = append(ids, p.ID())
+	}
+	if err := p.Err(); err != nil {
+		return nil, err
+	}
+	var detects []Detection
+	for _, chunk := range chunks(ids, maxDetectsPerSummary) {
+		resp, err := h.GetDetectSummariesContext(ctx, chunk)
+		if err != nil {
+			return nil, err
+		}
+		detects = append(detects, resp.Resources...)
+	}
+	sort.SliceStable(detects, func(i, j int) bool {
+		return detects[i].DateUpdated.Before(detects[j].DateUpdated)
+	})
+	return detects, nil
+}
@@ -0,0 +1,174 @@
+package gocs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/demisto/gocs/fql"
+)
+
+func TestQueryDetects(t *testing.T) {
+	h := newTestHost(t, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/detects/queries/detects/v1" || q.Get("filter") != "status:'new'" || q.Get("q") != "mimikatz" ||
+			q.Get("sort") != "max_severity.desc" || q.Get("limit") != "10" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"resources": []string{"ldt:1"}})
+	})
+	resp, err := h.QueryDetects(&QueryDetectsRequest{Filter: "status:'new'", Query: "mimikatz", Sort: &SortField{Name: "max_severity"}, Paging: Paging{Limit: 10}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Resources) != 1 || resp.Resources[0] != "ldt:1" {
+		t.Fatalf("unexpected resources %v", resp.Resources)
+	}
+}
+
+func TestGetDetectSummaries(t *testing.T) {
+	h := newTestHost(t, func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			IDs []string `json:"ids"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || r.Method != "POST" || len(body.IDs) != 2 {
+			t.Errorf("unexpected request %s %v", r.Method, body.IDs)
+		}
+		w.Write([]byte(`{"resources":[{
+			"detection_id": "ldt:1",
+			"created_timestamp": "2020-03-01T10:00:00.123Z",
+			"device": {"device_id": "dev1", "hostname": "host1", "first_seen": "2019-01-01T00:00:00Z"},
+			"behaviors": [{"behavior_id": "b1", "timestamp": "2020-03-01T09:59:00Z", "severity": 70,
+				"parent_details": {"parent_md5": "abc"}, "pattern_disposition_details": {"killed": true}}],
+			"max_severity": 70,
+			"status": "new",
+			"date_updated": "2020-03-01T10:05:00Z"
+		}]}`))
+	})
+	resp, err := h.GetDetectSummaries([]string{"ldt:1", "ldt:2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := resp.Resources[0]
+	if d.DetectionID != "ldt:1" || d.Device.Hostname != "host1" || d.MaxSeverity != 70 || d.Status != DetectStatusNew {
+		t.Fatalf("unexpected detection %+v", d)
+	}
+	if !d.CreatedTimestamp.Equal(time.Date(2020, 3, 1, 10, 0, 0, 123e6, time.UTC)) || d.Device.FirstSeen.Year() != 2019 {
+		t.Fatalf("unexpected times %v %v", d.CreatedTimestamp, d.Device.FirstSeen)
+	}
+	b := d.Behaviors[0]
+	if b.BehaviorID != "b1" || b.ParentDetails.ParentMD5 != "abc" || !b.PatternDispositionDetails["killed"] {
+		t.Fatalf("unexpected behavior %+v", b)
+	}
+}
+
+func TestGetDetectSummariesMissingIDs(t *testing.T) {
+	h := newTestHost(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request")
+	})
+	if _, err := h.GetDetectSummaries(nil); err != ErrMissingParams {
+		t.Fatalf("expected ErrMissingParams, got %v", err)
+	}
+}
+
+func TestDetectionTimestamps(t *testing.T) {
+	var d Detection
+	err := json.Unmarshal([]byte(`{"detection_id":"ldt:1","created_timestamp":1583056800,"first_behavior":"","date_updated":"1583056800",
+		"device":{"first_seen":"","last_seen":1583056800},"behaviors":[{"behavior_id":"b1","timestamp":null}]}`), &d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	epoch := time.Unix(1583056800, 0)
+	if !d.CreatedTimestamp.Equal(epoch) || !d.DateUpdated.Equal(epoch) || !d.FirstBehavior.IsZero() || d.DetectionID != "ldt:1" {
+		t.Fatalf("unexpected detection %+v", d)
+	}
+	if !d.Device.FirstSeen.IsZero() || !d.Device.LastSeen.Equal(epoch) || d.Behaviors[0].BehaviorID != "b1" || !d.Behaviors[0].Timestamp.IsZero() {
+		t.Fatalf("unexpected device or behavior %+v %+v", d.Device, d.Behaviors[0])
+	}
+}
+
+func TestUpdateDetectsChunks(t *testing.T) {
+	var sizes []int
+	h := newTestHost(t, func(w http.ResponseWriter, r *http.Request) {
+		var req UpdateDetectsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || r.Method != "PATCH" || r.URL.Path != "/detects/entities/detects/v2" {
+			t.Errorf("unexpected request %s %s - %v", r.Method, r.URL, err)
+		}
+		if req.Status != DetectStatusInProgress || req.Comment != "triage" || req.ShowInUI != nil || req.AssignedToUUID != "" {
+			t.Errorf("unexpected update %+v", req)
+		}
+		sizes = append(sizes, len(req.IDs))
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"meta":   map[string]interface{}{"writes": map[string]interface{}{"resources_affected": len(req.IDs)}},
+			"errors": []Error{{Code: "404", Message: "not found", ID: req.IDs[0]}},
+		})
+	})
+	ids := make([]string, 2500)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("ldt:%d", i)
+	}
+	resp, err := h.UpdateDetects(&UpdateDetectsRequest{IDs: ids, Status: DetectStatusInProgress, Comment: "triage"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sizes) != 3 || sizes[0] != 1000 || sizes[2] != 500 {
+		t.Fatalf("unexpected chunks %v", sizes)
+	}
+	if resp.Meta.Writes.ResourcesAffected != 2500 || len(resp.Errors) != 3 {
+		t.Fatalf("expected the results to be combined, got %+v", resp)
+	}
+	pe := resp.Err().(*PartialError)
+	if pe.Errors[1].Item != "ldt:1000" {
+		t.Fatalf("unexpected item %q", pe.Errors[1].Item)
+	}
+}
+
+func TestUpdateDetectsValidation(t *testing.T) {
+	h := newTestHost(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request")
+	})
+	if _, err := h.UpdateDetects(&UpdateDetectsRequest{IDs: []string{"a"}}); err != ErrMissingParams {
+		t.Fatalf("expected ErrMissingParams for an empty update, got %v", err)
+	}
+	if _, err := h.UpdateDetects(&UpdateDetectsRequest{Status: DetectStatusClosed}); err != ErrMissingParams {
+		t.Fatalf("expected ErrMissingParams without IDs, got %v", err)
+	}
+	if _, err := h.UpdateDetects(&UpdateDetectsRequest{IDs: []string{"a"}, Status: "done"}); err == nil {
+		t.Fatal("expected an error for an invalid status")
+	}
+}
+
+func TestPollDetectsFilterGrouping(t *testing.T) {
+	var filter string
+	h := newTestHost(t, func(w http.ResponseWriter, r *http.Request) {
+		filter = r.URL.Query().Get("filter")
+		writeJSON(w, http.StatusOK, map[string]interface{}{"resources": []string{}})
+	})
+	since := time.Date(2020, 3, 1, 10, 0, 0, 500, time.UTC)
+	if _, err := h.pollDetects(context.Background(), "status:'new',status:'reopened'", since); err != nil {
+		t.Fatal(err)
+	}
+	// The OR of the caller must not escape the time bound
+	if filter != "date_updated:>='2020-03-01T10:00:00Z'+(status:'new',status:'reopened')" {
+		t.Fatalf("unexpected filter %q", filter)
+	}
+	if _, err := h.pollDetects(context.Background(), "", since); err != nil {
+		t.Fatal(err)
+	}
+	if filter != "date_updated:>='2020-03-01T10:00:00Z'" {
+		t.Fatalf("unexpected filter %q", filter)
+	}
+}
+
+func TestPollDetectsInvalidFilter(t *testing.T) {
+	h := newTestHost(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request")
+	})
+	err := h.PollDetects(context.Background(), &DetectsPollOptions{Filter: "status:'new"}, func(context.Context, *Detection) error { return nil })
+	if _, ok := err.(*fql.SyntaxError); !ok {
+		t.Fatalf("expected a syntax error, got %v", err)
+	}
+}
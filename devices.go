@@ -0,0 +1,174 @@
+package gocs
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"time"
+)
+
+// maxDevicesPerRequest is the number of IDs the device details API accepts in a single request
+const maxDevicesPerRequest = 100
+
+// DevicePolicy is a policy applied to a device
+type DevicePolicy struct {
+	PolicyType   string    `json:"policy_type"`
+	PolicyID     string    `json:"policy_id"`
+	Applied      bool      `json:"applied"`
+	SettingsHash string    `json:"settings_hash"`
+	AssignedDate time.Time `json:"assigned_date"`
+	AppliedDate  time.Time `json:"applied_date"`
+	RuleGroups   []string  `json:"rule_groups"`
+}
+
+// UnmarshalJSON decodes the dates from epoch seconds or RFC3339 strings
+func (p *DevicePolicy) UnmarshalJSON(b []byte) error {
+	type devicePolicy DevicePolicy
+	aux := &struct {
+		*devicePolicy
+		AssignedDate timestamp `json:"assigned_date"`
+		AppliedDate  timestamp `json:"applied_date"`
+	}{devicePolicy: (*devicePolicy)(p)}
+	if err := json.Unmarshal(b, aux); err != nil {
+		return err
+	}
+	p.AssignedDate, p.AppliedDate = aux.AssignedDate.Time, aux.AppliedDate.Time
+	return nil
+}
+
+// Device holds the details of a host running the Falcon sensor
+type Device struct {
+	DeviceID                 string                  `json:"device_id"`
+	CID                      string                  `json:"cid"`
+	AgentLoadFlags           string                  `json:"agent_load_flags"`
+	AgentLocalTime           string                  `json:"agent_local_time"`
+	AgentVersion             string                  `json:"agent_version"`
+	BIOSManufacturer         string                  `json:"bios_manufacturer"`
+	BIOSVersion              string                  `json:"bios_version"`
+	BuildNumber              string                  `json:"build_number"`
+	ConfigIDBase             string                  `json:"config_id_base"`
+	ConfigIDBuild            string                  `json:"config_id_build"`
+	ConfigIDPlatform         string                  `json:"config_id_platform"`
+	ExternalIP               string                  `json:"external_ip"`
+	LocalIP                  string                  `json:"local_ip"`
+	MACAddress               string                  `json:"mac_address"`
+	Hostname                 string                  `json:"hostname"`
+	MachineDomain            string                  `json:"machine_domain"`
+	SiteName                 string                  `json:"site_name"`
+	OU                       []string                `json:"ou"`
+	FirstSeen                time.Time               `json:"first_seen"`
+	LastSeen                 time.Time               `json:"last_seen"`
+	ModifiedTimestamp        time.Time               `json:"modified_timestamp"`
+	MajorVersion             string                  `json:"major_version"`
+	MinorVersion             string                  `json:"minor_version"`
+	OSVersion                string                  `json:"os_version"`
+	OSBuild                  string                  `json:"os_build"`
+	KernelVersion            string                  `json:"kernel_version"`
+	PlatformID               string                  `json:"platform_id"`
+	PlatformName             string                  `json:"platform_name"`
+	ProductType              string                  `json:"product_type"`
+	ProductTypeDesc          string                  `json:"product_type_desc"`
+	ChassisTypeDesc          string                  `json:"chassis_type_desc"`
+	ProvisionStatus          string                  `json:"provision_status"`
+	ReducedFunctionalityMode string                  `json:"reduced_functionality_mode"`
+	SerialNumber             string                  `json:"serial_number"`
+	Status                   string                  `json:"status"`
+	SystemManufacturer       string                  `json:"system_manufacturer"`
+	SystemProductName        string                  `json:"system_product_name"`
+	Policies                 []DevicePolicy          `json:"policies"`
+	DevicePolicies           map[string]DevicePolicy `json:"device_policies"` // Policies by type, e.g. prevention
+	Groups                   []string                `json:"groups"`
+	Tags                     []string                `json:"tags"`
+}
+
+// UnmarshalJSON decodes the dates from epoch seconds or RFC3339 strings
+func (d *Device) UnmarshalJSON(b []byte) error {
+	type device Device
+	aux := &struct {
+		*device
+		FirstSeen         timestamp `json:"first_seen"`
+		LastSeen          timestamp `json:"last_seen"`
+		ModifiedTimestamp timestamp `json:"modified_timestamp"`
+	}{device: (*device)(d)}
+	if err := json.Unmarshal(b, aux); err != nil {
+		return err
+	}
+	d.FirstSeen = aux.FirstSeen.Time
+	d.LastSeen = aux.LastSeen.Time
+	d.ModifiedTimestamp = aux.ModifiedTimestamp.Time
+	return nil
+}
+
+// DevicesResponse is returned from GetDeviceDetails
+type DevicesResponse struct {
+	Meta struct {
+		QueryTime float64 `json:"query_time"`
+		TraceID   string  `json:"trace_id"`
+	} `json:"meta"`
+	Resources []Device `json:"resources"`
+	Errors    []Error  `json:"errors"`
+	items     []string
+}
+
+// Err returns a *PartialError if the response carries per-item errors, nil otherwise
+func (r *DevicesResponse) Err() error {
+	return newPartialError(r.items, r.Errors)
+}
+
+// chunks splits the IDs to slices of at most size IDs
+func chunks(ids []string, size int) [][]string {
+	var res [][]string
+	for len(ids) > size {
+		res = append(res, ids[:size])
+		ids = ids[size:]
+	}
+	if len(ids) > 0 {
+		res = append(res, ids)
+	}
+	return res
+}
+
+// GetDeviceDetails returns the details of the devices with the given IDs.
+// Large ID lists are split into several requests and the results are combined.
+func (h *Host) GetDeviceDetails(ids []string) (resp *DevicesResponse, err error) {
+	return h.GetDeviceDetailsContext(context.Background(), ids)
+}
+
+// GetDeviceDetailsContext is like GetDeviceDetails with a context for cancellation and deadlines
+func (h *Host) GetDeviceDetailsContext(ctx context.Context, ids []string) (resp *DevicesResponse, err error) {
+	if len(ids) == 0 {
+		return nil, ErrMissingParams
+	}
+	resp = &DevicesResponse{items: ids}
+	for _, chunk := range chunks(ids, maxDevicesPerRequest) {
+		params := url.Values{}
+		addStringArr("ids", chunk, params)
+		r := &DevicesResponse{}
+		if err = h.do(ctx, "GET", "devices/entities/devices/v1", params, nil, r, h.authFunc()); err != nil {
+			return
+		}
+		resp.Meta.QueryTime += r.Meta.QueryTime
+		resp.Meta.TraceID = r.Meta.TraceID
+		resp.Resources = append(resp.Resources, r.Resources...)
+		resp.Errors = append(resp.Errors, r.Errors...)
+	}
+	err = h.partial(resp.Err())
+	return
+}
+
+// DevicesRanOnDetails returns the details of the devices that observed the given IOC type and value
+func (h *Host) DevicesRanOnDetails(t, v string) (resp *DevicesResponse, err error) {
+	return h.DevicesRanOnDetailsContext(context.Background(), t, v)
+}
+
+// DevicesRanOnDetailsContext is like DevicesRanOnDetails with a context for cancellation and deadlines
+func (h *Host) DevicesRanOnDetailsContext(ctx context.Context, t, v string) (resp *DevicesResponse, err error) {
+	ids, err := h.DevicesRanOnContext(ctx, t, v)
+	if err != nil {
+		return nil, err
+	}
+	if len(ids.Resources) == 0 {
+		return &DevicesResponse{}, nil
+	}
+	return h.GetDeviceDetailsContext(ctx, ids.Resources)
+}
@@ -0,0 +1,134 @@
+package gocs
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestChunks(t *testing.T) {
+	ids := []string{"a", "b", "c", "d", "e"}
+	res := chunks(ids, 2)
+	if len(res) != 3 || len(res[0]) != 2 || len(res[2]) != 1 || res[2][0] != "e" {
+		t.Fatalf("unexpected chunks %v", res)
+	}
+	if res := chunks(ids[:2], 2); len(res) != 1 {
+		t.Fatalf("unexpected chunks %v", res)
+	}
+	if res := chunks(nil, 2); len(res) != 0 {
+		t.Fatalf("unexpected chunks %v", res)
+	}
+}
+
+func TestGetDeviceDetailsChunks(t *testing.T) {
+	var sizes []int
+	h := newTestHost(t, func(w http.ResponseWriter, r *http.Request) {
+		ids := r.URL.Query()["ids"]
+		sizes = append(sizes, len(ids))
+		var devices []map[string]interface{}
+		for _, id := range ids {
+			devices = append(devices, map[string]interface{}{
+				"device_id":       id,
+				"hostname":        "host-" + id,
+				"first_seen":      "2019-05-01T12:00:00Z",
+				"device_policies": map[string]interface{}{"prevention": map[string]interface{}{"policy_id": "p1", "applied": true}},
+			})
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"meta": map[string]interface{}{"trace_id": "t"}, "resources": devices})
+	})
+	ids := make([]string, 250)
+	for i := range ids {
+		ids[i] = fmt.Sprint(i)
+	}
+	resp, err := h.GetDeviceDetails(ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sizes) != 3 || sizes[0] != maxDevicesPerRequest || sizes[2] != 50 {
+		t.Fatalf("unexpected chunks %v", sizes)
+	}
+	if len(resp.Resources) != 250 || resp.Resources[249].Hostname != "host-249" || resp.Meta.TraceID != "t" {
+		t.Fatalf("expected the results to be combined, got %d devices", len(resp.Resources))
+	}
+	d := resp.Resources[0]
+	if d.FirstSeen.Year() != 2019 || !d.DevicePolicies["prevention"].Applied {
+		t.Fatalf("unexpected device %+v", d)
+	}
+}
+
+func TestDevicesRanOnDetails(t *testing.T) {
+	h := newTestHost(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/indicators/queries/devices/v1":
+			if r.URL.Query().Get("type") != "domain" || r.URL.Query().Get("value") != "example.com" {
+				t.Errorf("unexpected query %s", r.URL)
+			}
+			writeJSON(w, http.StatusOK, map[string]interface{}{"resources": []string{"dev1"}})
+		case "/devices/entities/devices/v1":
+			writeJSON(w, http.StatusOK, map[string]interface{}{"resources": []map[string]interface{}{{"device_id": "dev1"}}})
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	})
+	resp, err := h.DevicesRanOnDetails("domain", "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Resources) != 1 || resp.Resources[0].DeviceID != "dev1" {
+		t.Fatalf("unexpected devices %+v", resp.Resources)
+	}
+}
+
+func TestDevicesRanOnDetailsNone(t *testing.T) {
+	calls := 0
+	h := newTestHost(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		writeJSON(w, http.StatusOK, map[string]interface{}{"resources": []string{}})
+	})
+	resp, err := h.DevicesRanOnDetails("domain", "example.com")
+	if err != nil || len(resp.Resources) != 0 || calls != 1 {
+		t.Fatalf("expected no details call, got %d calls - %v", calls, err)
+	}
+}
+
+func TestDeviceTimestamps(t *testing.T) {
+	var d Device
+	err := json.Unmarshal([]byte(`{"first_seen":"","last_seen":1583056800,"modified_timestamp":null,
+		"policies":[{"assigned_date":"1583056800.5","applied_date":"2020-03-01T10:00:00Z"}]}`), &d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !d.FirstSeen.IsZero() || !d.ModifiedTimestamp.IsZero() || !d.LastSeen.Equal(time.Unix(1583056800, 0)) {
+		t.Fatalf("unexpected times %v %v %v", d.FirstSeen, d.LastSeen, d.ModifiedTimestamp)
+	}
+	p := d.Policies[0]
+	if !p.AssignedDate.Equal(time.Unix(1583056800, 5e8)) || !p.AppliedDate.Equal(time.Unix(1583056800, 0)) {
+		t.Fatalf("unexpected policy times %v %v", p.AssignedDate, p.AppliedDate)
+	}
+	if err = json.Unmarshal([]byte(`{"first_seen":"yesterday"}`), &d); err == nil {
+		t.Fatal("expected an error for an invalid time")
+	}
+}
+
+func TestProcessRoundTrip(t *testing.T) {
+	var p Process
+	if err := json.Unmarshal([]byte(`{"process_id":"pid:1","start_timestamp":"","start_timestamp_raw":1583056800.25,"stop_timestamp":"2020-03-01T11:00:00Z"}`), &p); err != nil {
+		t.Fatal(err)
+	}
+	if !p.StartTimestamp.Equal(time.Unix(1583056800, 25e7)) || p.StopTimestamp.Hour() != 11 {
+		t.Fatalf("unexpected times %v %v", p.StartTimestamp, p.StopTimestamp)
+	}
+	b, err := json.Marshal(&p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var again Process
+	if err = json.Unmarshal(b, &again); err != nil {
+		t.Fatal(err)
+	}
+	if !again.StartTimestamp.Equal(p.StartTimestamp) || !again.StopTimestamp.Equal(p.StopTimestamp) || again.StartTimestampEpoch != p.StartTimestampEpoch {
+		t.Fatalf("the round trip changed the process %s", b)
+	}
+}
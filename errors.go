@@ -0,0 +1,99 @@
+package gocs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// maxErrorBody limits how much of an error response we read
+const maxErrorBody = 1 << 20
+
+var (
+	// ErrUnauthorized matches API errors with status 401 using errors.Is
+	ErrUnauthorized = &Error{Code: "unauthorized", Message: "The credentials were rejected by the API"}
+	// ErrForbidden matches API errors with status 403 using errors.Is
+	ErrForbidden = &Error{Code: "forbidden", Message: "The credentials do not have access to the requested resource"}
+	// ErrNotFound matches API errors with status 404 using errors.Is
+	ErrNotFound = &Error{Code: "not_found", Message: "The requested resource was not found"}
+	// ErrRateLimited matches API errors with status 429 using errors.Is
+	ErrRateLimited = &Error{Code: "rate_limited", Message: "The API rate limit was exceeded"}
+	// ErrValidation matches API errors with status 400 or 422 using errors.Is
+	ErrValidation = &Error{Code: "validation", Message: "The request was rejected as invalid"}
+)
+
+// APIError is returned when the API responds with a status code different from success.
+// Use errors.As to access the details and errors.Is with ErrUnauthorized, ErrForbidden,
+// ErrNotFound, ErrRateLimited or ErrValidation to check the class of the error.
+type APIError struct {
+	StatusCode int     // HTTP status code of the response
+	Method     string  // Method of the failed request
+	Path       string  // Path of the failed request
+	TraceID    string  // Trace ID to provide when opening a support case
+	Errors     []Error // Errors returned by the API, if any
+}
+
+func (e *APIError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "http_error: Unexpected status code: %d (%s) for %s %s", e.StatusCode, http.StatusText(e.StatusCode), e.Method, e.Path)
+	if e.TraceID != "" {
+		fmt.Fprintf(&b, " [trace_id: %s]", e.TraceID)
+	}
+	for i := range e.Errors {
+		sep := "; "
+		if i == 0 {
+			sep = " - "
+		}
+		b.WriteString(sep + e.Errors[i].Message)
+	}
+	return b.String()
+}
+
+// Is reports whether the error belongs to the class of target
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrValidation:
+		return e.StatusCode == http.StatusBadRequest || e.StatusCode == http.StatusUnprocessableEntity
+	}
+	return false
+}
+
+// newAPIError builds the error from the response, consuming the body
+func newAPIError(resp *http.Response) *APIError {
+	e := &APIError{StatusCode: resp.StatusCode, TraceID: resp.Header.Get("X-Cs-Traceid")}
+	if resp.Request != nil {
+		e.Method, e.Path = resp.Request.Method, resp.Request.URL.Path
+	}
+	if resp.Body == nil {
+		return e
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	if err != nil || len(data) == 0 {
+		return e
+	}
+	var body struct {
+		Meta struct {
+			TraceID string `json:"trace_id"`
+		} `json:"meta"`
+		Errors []Error `json:"errors"`
+	}
+	// Not all APIs return a JSON body for errors so ignore decoding issues
+	if json.Unmarshal(data, &body) == nil {
+		if body.Meta.TraceID != "" {
+			e.TraceID = body.Meta.TraceID
+		}
+		e.Errors = body.Errors
+	}
+	return e
+}
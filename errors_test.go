@@ -0,0 +1,79 @@
+package gocs
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAPIErrorFromBody(t *testing.T) {
+	h := newTestHost(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusForbidden, map[string]interface{}{
+			"meta":   map[string]interface{}{"trace_id": "trace-1"},
+			"errors": []map[string]interface{}{{"code": 403, "message": "access denied, authorization failed"}},
+		})
+	})
+	_, err := h.SearchIOCs(&SearchIOCsRequest{})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected an APIError, got %v", err)
+	}
+	if apiErr.StatusCode != http.StatusForbidden || apiErr.TraceID != "trace-1" || apiErr.Method != "GET" || apiErr.Path != "/indicators/queries/iocs/v1" {
+		t.Fatalf("unexpected error %+v", apiErr)
+	}
+	if len(apiErr.Errors) != 1 || apiErr.Errors[0].Code != "403" {
+		t.Fatalf("unexpected errors %+v", apiErr.Errors)
+	}
+	if !strings.Contains(err.Error(), "trace-1") || !strings.Contains(err.Error(), "access denied") {
+		t.Fatalf("unexpected message %q", err.Error())
+	}
+}
+
+func TestAPIErrorTraceHeader(t *testing.T) {
+	h := newTestHost(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Cs-Traceid", "trace-2")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("not json"))
+	})
+	_, err := h.SearchIOCs(&SearchIOCsRequest{})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.TraceID != "trace-2" || len(apiErr.Errors) != 0 {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestAPIErrorIs(t *testing.T) {
+	classes := map[int]error{
+		http.StatusUnauthorized:        ErrUnauthorized,
+		http.StatusForbidden:           ErrForbidden,
+		http.StatusNotFound:            ErrNotFound,
+		http.StatusTooManyRequests:     ErrRateLimited,
+		http.StatusBadRequest:          ErrValidation,
+		http.StatusUnprocessableEntity: ErrValidation,
+	}
+	for status, class := range classes {
+		err := error(&APIError{StatusCode: status})
+		if !errors.Is(err, class) {
+			t.Errorf("%d is not %v", status, class)
+		}
+		if status != http.StatusNotFound && errors.Is(err, ErrNotFound) {
+			t.Errorf("%d should not be ErrNotFound", status)
+		}
+	}
+	if errors.Is(&APIError{StatusCode: http.StatusInternalServerError}, ErrValidation) {
+		t.Error("500 should not be a validation error")
+	}
+}
+
+func TestErrorCodes(t *testing.T) {
+	var errs []Error
+	data := `[{"code":"invalid","message":"a"},{"code":500,"message":"b","id":"x"},{"code":null,"message":"c"}]`
+	if err := json.Unmarshal([]byte(data), &errs); err != nil {
+		t.Fatal(err)
+	}
+	if errs[0].Code != "invalid" || errs[1].Code != "500" || errs[1].ID != "x" || errs[2].Code != "" {
+		t.Fatalf("unexpected errors %+v", errs)
+	}
+}
@@ -0,0 +1,257 @@
+// Package fql builds and validates Falcon Query Language filters as accepted by the filter parameter
+// of the CrowdStrike APIs.
+//
+// Values are quoted and escaped so user input cannot change the structure of the filter:
+//
+//	f := fql.And(
+//	  fql.Eq("hostname", userInput),
+//	  fql.Or(fql.Eq("platform_name", "Windows"), fql.Eq("platform_name", "Linux")),
+//	  fql.Gte("last_seen", time.Now().Add(-24*time.Hour)),
+//	)
+//	if err := f.Err(); err != nil {
+//	  ...
+//	}
+//	resp, err := h.DeviceSearch(f.String(), "")
+package fql
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Operators of comparisons
+const (
+	OpEq       = ""   // Equal
+	OpNe       = "!"  // Not equal
+	OpGt       = ">"  // Greater than
+	OpGte      = ">=" // Greater than or equal
+	OpLt       = "<"  // Less than
+	OpLte      = "<=" // Less than or equal
+	OpMatch    = "~"  // Text match, case insensitive
+	OpNotMatch = "!~" // Does not text match
+	OpWildcard = "*"  // Wildcard match - * in the value matches any characters
+)
+
+// Filter is an FQL filter expression. The zero value is the empty filter.
+// Filters are immutable and can be combined with And and Or.
+type Filter struct {
+	s   string
+	op  byte // The combinator of the top level if it combines several expressions - '+' or ','
+	err error
+}
+
+// String returns the filter in FQL syntax
+func (f Filter) String() string {
+	return f.s
+}
+
+// Err returns the first error in building the filter, such as an invalid field name or an unsupported value type
+func (f Filter) Err() error {
+	return f.err
+}
+
+// IsEmpty returns true for the empty filter
+func (f Filter) IsEmpty() bool {
+	return f.s == "" && f.err == nil
+}
+
+// fieldRE matches the valid field names. Nested fields are separated by dots.
+var fieldRE = regexp.MustCompile(`^[A-Za-z0-9_]+(\.[A-Za-z0-9_]+)*$`)
+
+// Compare returns a filter comparing the field to the value with one of the Op constants.
+// Supported values are strings, time.Time, bools, integers and floats.
+func Compare(field, op string, value interface{}) Filter {
+	if !fieldRE.MatchString(field) {
+		return Filter{err: fmt.Errorf("fql: invalid field name %q", field)}
+	}
+	switch op {
+	case OpEq, OpNe, OpGt, OpGte, OpLt, OpLte, OpMatch, OpNotMatch, OpWildcard:
+	default:
+		return Filter{err: fmt.Errorf("fql: invalid operator %q", op)}
+	}
+	v, err := literal(value)
+	if err != nil {
+		return Filter{err: err}
+	}
+	return Filter{s: field + ":" + op + v}
+}
+
+// Eq matches the field equal to the value
+func Eq(field string, value interface{}) Filter {
+	return Compare(field, OpEq, value)
+}
+
+// Ne matches the field not equal to the value
+func Ne(field string, value interface{}) Filter {
+	return Compare(field, OpNe, value)
+}
+
+// Gt matches the field greater than the value
+func Gt(field string, value interface{}) Filter {
+	return Compare(field, OpGt, value)
+}
+
+// Gte matches the field greater than or equal to the value
+func Gte(field string, value interface{}) Filter {
+	return Compare(field, OpGte, value)
+}
+
+// Lt matches the field less than the value
+func Lt(field string, value interface{}) Filter {
+	return Compare(field, OpLt, value)
+}
+
+// Lte matches the field less than or equal to the value
+func Lte(field string, value interface{}) Filter {
+	return Compare(field, OpLte, value)
+}
+
+// Match matches the field containing the text, case insensitive
+func Match(field, text string) Filter {
+	return Compare(field, OpMatch, text)
+}
+
+// Wildcard matches the field against the pattern, where * matches any characters
+func Wildcard(field, pattern string) Filter {
+	return Compare(field, OpWildcard, pattern)
+}
+
+// Between matches the field in the inclusive range from lo to hi
+func Between(field string, lo, hi interface{}) Filter {
+	return And(Gte(field, lo), Lte(field, hi))
+}
+
+// In matches the field equal to any of the values
+func In(field string, values ...interface{}) Filter {
+	return list(field, OpEq, values)
+}
+
+// NotIn matches the field not equal to any of the values
+func NotIn(field string, values ...interface{}) Filter {
+	return list(field, OpNe, values)
+}
+
+func list(field, op string, values []interface{}) Filter {
+	if !fieldRE.MatchString(field) {
+		return Filter{err: fmt.Errorf("fql: invalid field name %q", field)}
+	}
+	if len(values) == 0 {
+		return Filter{err: fmt.Errorf("fql: no values for field %q", field)}
+	}
+	vals := make([]string, len(values))
+	for i := range values {
+		v, err := literal(values[i])
+		if err != nil {
+			return Filter{err: err}
+		}
+		vals[i] = v
+	}
+	return Filter{s: field + ":" + op + "[" + strings.Join(vals, ",") + "]"}
+}
+
+// And matches when all the filters match. Empty filters are ignored.
+func And(filters ...Filter) Filter {
+	return combine('+', filters)
+}
+
+// Or matches when any of the filters match. Empty filters are ignored.
+func Or(filters ...Filter) Filter {
+	return combine(',', filters)
+}
+
+// combine joins the filters, grouping the ones that are combined differently so precedence never matters
+func combine(op byte, filters []Filter) Filter {
+	var parts []string
+	for _, f := range filters {
+		if f.err != nil {
+			return Filter{err: f.err}
+		}
+		if f.s == "" {
+			continue
+		}
+		if f.op != 0 && f.op != op {
+			parts = append(parts, "("+f.s+")")
+		} else {
+			parts = append(parts, f.s)
+		}
+	}
+	switch len(parts) {
+	case 0:
+		return Filter{}
+	case 1:
+		// A single filter keeps its own combinator
+		for _, f := range filters {
+			if f.s != "" {
+				return f
+			}
+		}
+	}
+	return Filter{s: strings.Join(parts, string(op)), op: op}
+}
+
+// Quote returns s as a quoted FQL string literal
+func Quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('\'')
+	for _, r := range s {
+		if r == '\'' || r == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	b.WriteByte('\'')
+	return b.String()
+}
+
+// literal encodes the value as an FQL literal
+func literal(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return Quote(v), nil
+	case time.Time:
+		return Quote(v.UTC().Format(time.RFC3339)), nil
+	case *time.Time:
+		if v == nil {
+			return "", fmt.Errorf("fql: nil time")
+		}
+		return Quote(v.UTC().Format(time.RFC3339)), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int8:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int16:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int32:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case uint:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint8:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint16:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case float32:
+		return formatFloat(float64(v), 32)
+	case float64:
+		return formatFloat(v, 64)
+	}
+	return "", fmt.Errorf("fql: unsupported value type %T", value)
+}
+
+func formatFloat(f float64, bits int) (string, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return "", fmt.Errorf("fql: invalid number %v", f)
+	}
+	return strconv.FormatFloat(f, 'f', -1, bits), nil
+}
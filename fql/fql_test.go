@@ -0,0 +1,154 @@
+package fql
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestCompare(t *testing.T) {
+	ts := time.Date(2020, 3, 1, 12, 0, 0, 0, time.FixedZone("IST", 2*3600))
+	for _, c := range []struct {
+		f        Filter
+		expected string
+	}{
+		{Eq("hostname", "web-1"), `hostname:'web-1'`},
+		{Ne("status", "closed"), `status:!'closed'`},
+		{Gte("max_severity", 50), `max_severity:>=50`},
+		{Lt("score", 1.5), `score:<1.5`},
+		{Lte("last_seen", ts), `last_seen:<='2020-03-01T10:00:00Z'`},
+		{Gt("count", uint8(3)), `count:>3`},
+		{Eq("device.hidden", false), `device.hidden:false`},
+		{Match("name", "mimi"), `name:~'mimi'`},
+		{Wildcard("hostname", "web-*"), `hostname:*'web-*'`},
+		{In("platform_name", "Windows", "Mac"), `platform_name:['Windows','Mac']`},
+		{NotIn("id", 1, 2), `id:![1,2]`},
+		{Between("date", 1, 2), `date:>=1+date:<=2`},
+	} {
+		if err := c.f.Err(); err != nil || c.f.String() != c.expected {
+			t.Fatalf("expected %s, got %s - %v", c.expected, c.f, err)
+		}
+	}
+}
+
+func TestQuote(t *testing.T) {
+	if q := Quote(`it's a \ test`); q != `'it\'s a \\ test'` {
+		t.Fatalf("unexpected quoting %s", q)
+	}
+	// Quoted input cannot change the structure of the filter
+	f := Eq("hostname", "x'+status:'closed")
+	if _, err := Parse(f.String()); err != nil {
+		t.Fatalf("the quoted filter is invalid - %v", err)
+	}
+	if f.String() != `hostname:'x\'+status:\'closed'` {
+		t.Fatalf("unexpected filter %s", f)
+	}
+}
+
+func TestCompareErrors(t *testing.T) {
+	for _, f := range []Filter{
+		Eq("host name", "x"),
+		Eq("hostname", struct{}{}),
+		Eq("score", math.NaN()),
+		Eq("last_seen", (*time.Time)(nil)),
+		Compare("hostname", "=", "x"),
+		In("hostname"),
+		In("hostname", "a", []string{"b"}),
+		And(Eq("a", 1), Eq("b c", 2)),
+	} {
+		if f.Err() == nil || f.String() != "" {
+			t.Fatalf("expected an error, got %s", f)
+		}
+	}
+}
+
+func TestCombine(t *testing.T) {
+	a, b, c := Eq("a", 1), Eq("b", 2), Eq("c", 3)
+	for _, tc := range []struct {
+		f        Filter
+		expected string
+	}{
+		{And(a, b, c), `a:1+b:2+c:3`},
+		{Or(a, b), `a:1,b:2`},
+		{And(Or(a, b), c), `(a:1,b:2)+c:3`},
+		{Or(And(a, b), c), `(a:1+b:2),c:3`},
+		{And(And(a, b), c), `a:1+b:2+c:3`},
+		{And(Filter{}, a, Filter{}), `a:1`},
+		{And(Or(a, b)), `a:1,b:2`},
+		{And(c, Or(a, b)), `c:3+(a:1,b:2)`},
+		{And(Or(Filter{}, a), b), `a:1+b:2`},
+	} {
+		if tc.f.String() != tc.expected {
+			t.Fatalf("expected %s, got %s", tc.expected, tc.f)
+		}
+	}
+	// The single filter keeps its combinator and is grouped when combined
+	if f := And(c, And(Or(a, b))); f.String() != `c:3+(a:1,b:2)` {
+		t.Fatalf("unexpected filter %s", f)
+	}
+	if !And().IsEmpty() || !Or(Filter{}).IsEmpty() {
+		t.Fatal("expected an empty filter")
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"  ",
+		"status:'new'",
+		"status:'new',status:'reopened'",
+		"max_severity:>=50+(status:'new',status:\"reopened\")",
+		"hostname:['a','b'] + platform_name:!'Mac'",
+		"device.platform_name:Windows",
+		"name:~'it\\'s'",
+		"last_seen:<=now-1d",
+	} {
+		if err := Validate(s); err != nil {
+			t.Fatalf("expected %q to be valid - %v", s, err)
+		}
+	}
+}
+
+func TestParseGrouping(t *testing.T) {
+	f, err := Parse(" status:'new',status:'reopened' ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The parsed OR is grouped when it is combined with AND
+	if g := And(Gte("date", 1), f); g.String() != `date:>=1+(status:'new',status:'reopened')` {
+		t.Fatalf("unexpected filter %s", g)
+	}
+	f, _ = Parse("a:1+b:2")
+	if g := And(f, Eq("c", 3)); g.String() != `a:1+b:2+c:3` {
+		t.Fatalf("unexpected filter %s", g)
+	}
+	f, _ = Parse("(a:1,b:2)")
+	if g := And(f, Eq("c", 3)); g.String() != `(a:1,b:2)+c:3` {
+		t.Fatalf("unexpected filter %s", g)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, c := range []struct {
+		s      string
+		offset int
+	}{
+		{"status", 6},
+		{"status:'new", 7},
+		{":'new'", 0},
+		{"status:'new'+", 13},
+		{"(status:'new'", 13},
+		{"status:'new')", 12},
+		{"hostname:['a' 'b']", 14},
+		{"status:", 7},
+	} {
+		err := Validate(c.s)
+		se, ok := err.(*SyntaxError)
+		if !ok {
+			t.Fatalf("expected a syntax error for %q, got %v", c.s, err)
+		}
+		if se.Offset != c.offset {
+			t.Fatalf("unexpected offset of %q - %v", c.s, se)
+		}
+	}
+}
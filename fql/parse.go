@@ -0,0 +1,204 @@
+package fql
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SyntaxError describes an invalid FQL filter
+type SyntaxError struct {
+	Offset int    // Byte offset of the error in the filter
+	Msg    string // Description of the error
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("fql: %s at offset %d", e.Msg, e.Offset)
+}
+
+// Validate returns a *SyntaxError if s is not a valid FQL filter. The empty string is valid.
+// Field names are not checked against the fields of any API.
+func Validate(s string) error {
+	_, err := Parse(s)
+	return err
+}
+
+// Parse validates s and returns it as a Filter that can be combined with others.
+// A *SyntaxError is returned if s is not a valid FQL filter.
+func Parse(s string) (Filter, error) {
+	p := &parser{s: s}
+	p.skipSpace()
+	if p.eof() {
+		return Filter{}, nil
+	}
+	op, err := p.expr()
+	if err != nil {
+		return Filter{}, err
+	}
+	if !p.eof() {
+		return Filter{}, p.errorf("unexpected %q", p.s[p.pos])
+	}
+	return Filter{s: strings.TrimSpace(s), op: op}, nil
+}
+
+// parser is a recursive descent parser of the grammar:
+//
+//	expr       = and { "," and }
+//	and        = primary { "+" primary }
+//	primary    = "(" expr ")" | comparison
+//	comparison = field ":" [ operator ] ( value | "[" value { "," value } "]" )
+//	operator   = "!" | ">" | ">=" | "<" | "<=" | "~" | "!~" | "*"
+//	value      = quoted string | bare word
+type parser struct {
+	s   string
+	pos int
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.s)
+}
+
+func (p *parser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return &SyntaxError{Offset: p.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) skipSpace() {
+	for !p.eof() && strings.IndexByte(" \t\r\n", p.s[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+// expr parses an OR of ANDs. Returns ',' if there was more than one, otherwise the combinator of the AND.
+func (p *parser) expr() (byte, error) {
+	op, err := p.and()
+	if err != nil {
+		return 0, err
+	}
+	for p.peek() == ',' {
+		p.pos++
+		p.skipSpace()
+		if _, err = p.and(); err != nil {
+			return 0, err
+		}
+		op = ','
+	}
+	return op, nil
+}
+
+// and parses an AND of primaries. Returns '+' if there was more than one.
+func (p *parser) and() (byte, error) {
+	if err := p.primary(); err != nil {
+		return 0, err
+	}
+	var op byte
+	for p.peek() == '+' {
+		p.pos++
+		p.skipSpace()
+		if err := p.primary(); err != nil {
+			return 0, err
+		}
+		op = '+'
+	}
+	return op, nil
+}
+
+func (p *parser) primary() error {
+	if p.peek() == '(' {
+		p.pos++
+		p.skipSpace()
+		if _, err := p.expr(); err != nil {
+			return err
+		}
+		if p.peek() != ')' {
+			return p.errorf("expected ')'")
+		}
+		p.pos++
+		p.skipSpace()
+		return nil
+	}
+	return p.comparison()
+}
+
+func (p *parser) comparison() error {
+	start := p.pos
+	for !p.eof() && (isWordChar(p.s[p.pos]) || p.s[p.pos] == '.') {
+		p.pos++
+	}
+	field := p.s[start:p.pos]
+	if !fieldRE.MatchString(field) {
+		p.pos = start
+		return p.errorf("expected field name")
+	}
+	p.skipSpace()
+	if p.peek() != ':' {
+		return p.errorf("expected ':' after field %q", field)
+	}
+	p.pos++
+	p.skipSpace()
+	for _, op := range []string{OpNotMatch, OpGte, OpLte, OpNe, OpGt, OpLt, OpMatch, OpWildcard} {
+		if strings.HasPrefix(p.s[p.pos:], op) {
+			p.pos += len(op)
+			break
+		}
+	}
+	p.skipSpace()
+	if p.peek() != '[' {
+		return p.value()
+	}
+	p.pos++
+	p.skipSpace()
+	for {
+		if err := p.value(); err != nil {
+			return err
+		}
+		switch p.peek() {
+		case ',':
+			p.pos++
+			p.skipSpace()
+		case ']':
+			p.pos++
+			p.skipSpace()
+			return nil
+		default:
+			return p.errorf("expected ',' or ']' in list")
+		}
+	}
+}
+
+func (p *parser) value() error {
+	switch q := p.peek(); q {
+	case '\'', '"':
+		start := p.pos
+		for p.pos++; !p.eof(); p.pos++ {
+			switch p.s[p.pos] {
+			case '\\':
+				p.pos++
+			case q:
+				p.pos++
+				p.skipSpace()
+				return nil
+			}
+		}
+		p.pos = start
+		return p.errorf("unterminated string")
+	}
+	start := p.pos
+	for !p.eof() && (isWordChar(p.s[p.pos]) || strings.IndexByte(".-", p.s[p.pos]) >= 0) {
+		p.pos++
+	}
+	if p.pos == start {
+		return p.errorf("expected value")
+	}
+	p.skipSpace()
+	return nil
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || ('0' <= c && c <= '9') || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
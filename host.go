@@ -7,6 +7,7 @@ package gocs
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io"
 	"net/http"
@@ -22,6 +23,7 @@ const (
 // Host interacts with the services provided by CrowdStrike Falcon Host API.
 type Host struct {
 	*client
+	sessions *rtrRegistry // Open RTR sessions to clean up on Close
 }
 
 // NewHost creates a new CS client.
@@ -48,7 +50,13 @@ func NewHost(options ...OptionFunc) (*Host, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &Host{client: c}, nil
+	return &Host{client: c, sessions: newRTRRegistry()}, nil
+}
+
+// Close deletes the open RTR sessions and releases the resources held by the client
+func (h *Host) Close() error {
+	h.sessions.closeAll()
+	return h.client.Close()
 }
 
 // Structs
@@ -66,22 +74,32 @@ type SearchIOCsRequest struct {
 	Paging
 }
 
-// SearchIOCsResponse ...
-type SearchIOCsResponse struct {
+// QueryResponse is returned by the APIs that return a list of IDs
+type QueryResponse struct {
 	Meta struct {
 		QueryTime  float64 `json:"query_time"`
 		Pagination struct {
-			Total  int `json:"total"`
-			Offset int `json:"offset"`
-			Limit  int `json:"limit"`
+			Total  int    `json:"total"`
+			Offset int    `json:"offset"`
+			Limit  int    `json:"limit"`
+			After  string `json:"after"` // Token for the next page for APIs that use them
 		} `json:"pagination"`
 		TraceID string `json:"trace_id"`
 		Entity  string `json:"entity"`
 	} `json:"meta"`
 	Resources []string `json:"resources"`
 	Errors    []Error  `json:"errors"`
+	items     []string // The input items for mapping the errors
+}
+
+// Err returns a *PartialError if the response carries per-item errors, nil otherwise
+func (r *QueryResponse) Err() error {
+	return newPartialError(r.items, r.Errors)
 }
 
+// SearchIOCsResponse is the QueryResponse returned by the IOC APIs
+type SearchIOCsResponse = QueryResponse
+
 // DeviceCountResponse ...
 type DeviceCountResponse struct {
 	Meta struct {
@@ -92,19 +110,43 @@ type DeviceCountResponse struct {
 		DeviceCount int `json:"device_count"`
 	} `json:"resources"`
 	Errors []Error `json:"errors"`
+	items  []string
+}
+
+// Err returns a *PartialError if the response carries per-item errors, nil otherwise
+func (r *DeviceCountResponse) Err() error {
+	return newPartialError(r.items, r.Errors)
 }
 
 // Process holds the information about a detected process
 type Process struct {
-	DeviceID            string `json:"device_id"`
-	CommandLine         string `json:"command_line"`
-	ProcessID           string `json:"process_id"`
-	ProcessIDLocal      string `json:"process_id_local"`
-	FileName            string `json:"file_name"`
-	StartTimestamp      time.Time
-	StartTimestampEpoch float64 `json:"start_timestamp_raw"`
-	StopTimestamp       time.Time
-	StopTimestampEpoch  float64 `json:"stop_timestamp_raw"`
+	DeviceID            string    `json:"device_id"`
+	CommandLine         string    `json:"command_line"`
+	ProcessID           string    `json:"process_id"`
+	ProcessIDLocal      string    `json:"process_id_local"`
+	FileName            string    `json:"file_name"`
+	StartTimestamp      time.Time `json:"start_timestamp"`
+	StartTimestampEpoch float64   `json:"start_timestamp_raw"`
+	StopTimestamp       time.Time `json:"stop_timestamp"`
+	StopTimestampEpoch  float64   `json:"stop_timestamp_raw"`
+}
+
+// UnmarshalJSON fills the timestamps from either the formatted or the raw epoch fields
+func (p *Process) UnmarshalJSON(b []byte) error {
+	type process Process
+	aux := &struct {
+		*process
+		Start    timestamp `json:"start_timestamp"`
+		StartRaw timestamp `json:"start_timestamp_raw"`
+		Stop     timestamp `json:"stop_timestamp"`
+		StopRaw  timestamp `json:"stop_timestamp_raw"`
+	}{process: (*process)(p)}
+	if err := json.Unmarshal(b, aux); err != nil {
+		return err
+	}
+	p.StartTimestamp, p.StartTimestampEpoch = firstTime(aux.Start, aux.StartRaw), aux.StartRaw.epoch()
+	p.StopTimestamp, p.StopTimestampEpoch = firstTime(aux.Stop, aux.StopRaw), aux.StopRaw.epoch()
+	return nil
 }
 
 // IOC ...
@@ -126,6 +168,12 @@ type ProcessResponse struct {
 	} `json:"meta"`
 	Resources []Process `json:"resources"`
 	Errors    []Error   `json:"errors"`
+	items     []string
+}
+
+// Err returns a *PartialError if the response carries per-item errors, nil otherwise
+func (r *ProcessResponse) Err() error {
+	return newPartialError(r.items, r.Errors)
 }
 
 // ResolveResponse ...
@@ -138,6 +186,12 @@ type ResolveResponse struct {
 		} `json:"writes"`
 	} `json:"meta"`
 	Errors []Error `json:"errors"`
+	items  []string
+}
+
+// Err returns a *PartialError if the response carries per-item errors, nil otherwise
+func (r *ResolveResponse) Err() error {
+	return newPartialError(r.items, r.Errors)
 }
 
 func addRFCTime(name string, t *time.Time, params url.Values) {
@@ -167,107 +221,198 @@ func searchRequestToParams(req *SearchIOCsRequest) url.Values {
 	return params
 }
 
-func (h *Host) authFunc() func(*http.Request) {
-	return func(req *http.Request) {
+// iocValues returns the values of the IOCs for mapping errors back to them
+func iocValues(iocs []IOC) []string {
+	values := make([]string, len(iocs))
+	for i := range iocs {
+		values[i] = iocs[i].Value
+	}
+	return values
+}
+
+func (h *Host) authFunc() func(*http.Request) error {
+	return func(req *http.Request) error {
+		if h.oauth != nil {
+			return h.bearerAuth(req)
+		}
 		req.SetBasicAuth(h.id, h.key)
+		return nil
 	}
 }
 
 // SearchIOCs ...
 func (h *Host) SearchIOCs(req *SearchIOCsRequest) (resp *SearchIOCsResponse, err error) {
-	resp = &SearchIOCsResponse{}
+	return h.SearchIOCsContext(context.Background(), req)
+}
+
+// SearchIOCsContext is like SearchIOCs with a context for cancellation and deadlines
+func (h *Host) SearchIOCsContext(ctx context.Context, req *SearchIOCsRequest) (resp *SearchIOCsResponse, err error) {
+	resp = &SearchIOCsResponse{items: req.Values}
 	params := searchRequestToParams(req)
-	err = h.do("GET", "indicators/queries/iocs/v1", params, nil, resp, h.authFunc())
+	err = h.do(ctx, "GET", "indicators/queries/iocs/v1", params, nil, resp, h.authFunc())
+	if err == nil {
+		err = h.partial(resp.Err())
+	}
 	return
 }
 
 // SearchIOCsJSON ...
 func (h *Host) SearchIOCsJSON(req *SearchIOCsRequest, w io.Writer) (err error) {
+	return h.SearchIOCsJSONContext(context.Background(), req, w)
+}
+
+// SearchIOCsJSONContext is like SearchIOCsJSON with a context for cancellation and deadlines
+func (h *Host) SearchIOCsJSONContext(ctx context.Context, req *SearchIOCsRequest, w io.Writer) (err error) {
 	params := searchRequestToParams(req)
-	err = h.do("GET", "indicators/queries/iocs/v1", params, nil, w, h.authFunc())
+	err = h.do(ctx, "GET", "indicators/queries/iocs/v1", params, nil, w, h.authFunc())
 	return
 }
 
 // DeviceCount ...
 func (h *Host) DeviceCount(t, v string) (resp *DeviceCountResponse, err error) {
-	resp = &DeviceCountResponse{}
+	return h.DeviceCountContext(context.Background(), t, v)
+}
+
+// DeviceCountContext is like DeviceCount with a context for cancellation and deadlines
+func (h *Host) DeviceCountContext(ctx context.Context, t, v string) (resp *DeviceCountResponse, err error) {
+	resp = &DeviceCountResponse{items: []string{v}}
 	params := url.Values{"type": {t}, "value": {v}}
-	err = h.do("GET", "indicators/aggregates/devices-count/v1", params, nil, resp, h.authFunc())
+	err = h.do(ctx, "GET", "indicators/aggregates/devices-count/v1", params, nil, resp, h.authFunc())
+	if err == nil {
+		err = h.partial(resp.Err())
+	}
 	return
 }
 
 // DeviceCountJSON ...
 func (h *Host) DeviceCountJSON(t, v string, w io.Writer) (err error) {
+	return h.DeviceCountJSONContext(context.Background(), t, v, w)
+}
+
+// DeviceCountJSONContext is like DeviceCountJSON with a context for cancellation and deadlines
+func (h *Host) DeviceCountJSONContext(ctx context.Context, t, v string, w io.Writer) (err error) {
 	params := url.Values{"type": {t}, "value": {v}}
-	err = h.do("GET", "indicators/aggregates/devices-count/v1", params, nil, w, h.authFunc())
+	err = h.do(ctx, "GET", "indicators/aggregates/devices-count/v1", params, nil, w, h.authFunc())
 	return
 }
 
 // DevicesRanOn ...
-func (h *Host) DevicesRanOn(t, v string) (resp *SearchIOCsResponse, err error) {
-	resp = &SearchIOCsResponse{}
+func (h *Host) DevicesRanOn(t, v string) (resp *QueryResponse, err error) {
+	return h.DevicesRanOnContext(context.Background(), t, v)
+}
+
+// DevicesRanOnContext is like DevicesRanOn with a context for cancellation and deadlines
+func (h *Host) DevicesRanOnContext(ctx context.Context, t, v string) (resp *QueryResponse, err error) {
+	resp = &QueryResponse{items: []string{v}}
 	params := url.Values{"type": {t}, "value": {v}}
-	err = h.do("GET", "indicators/queries/devices/v1", params, nil, resp, h.authFunc())
+	err = h.do(ctx, "GET", "indicators/queries/devices/v1", params, nil, resp, h.authFunc())
+	if err == nil {
+		err = h.partial(resp.Err())
+	}
 	return
 }
 
 // DevicesRanOnJSON ...
 func (h *Host) DevicesRanOnJSON(t, v string, w io.Writer) (err error) {
+	return h.DevicesRanOnJSONContext(context.Background(), t, v, w)
+}
+
+// DevicesRanOnJSONContext is like DevicesRanOnJSON with a context for cancellation and deadlines
+func (h *Host) DevicesRanOnJSONContext(ctx context.Context, t, v string, w io.Writer) (err error) {
 	params := url.Values{"type": {t}, "value": {v}}
-	err = h.do("GET", "indicators/queries/devices/v1", params, nil, w, h.authFunc())
+	err = h.do(ctx, "GET", "indicators/queries/devices/v1", params, nil, w, h.authFunc())
 	return
 }
 
 // ProcessesRanOn ...
-func (h *Host) ProcessesRanOn(t, v, device string) (resp *SearchIOCsResponse, err error) {
-	resp = &SearchIOCsResponse{}
+func (h *Host) ProcessesRanOn(t, v, device string) (resp *QueryResponse, err error) {
+	return h.ProcessesRanOnContext(context.Background(), t, v, device)
+}
+
+// ProcessesRanOnContext is like ProcessesRanOn with a context for cancellation and deadlines
+func (h *Host) ProcessesRanOnContext(ctx context.Context, t, v, device string) (resp *QueryResponse, err error) {
+	resp = &QueryResponse{items: []string{v}}
 	params := url.Values{"type": {t}, "value": {v}, "device_id": {device}}
-	err = h.do("GET", "indicators/queries/processes/v1", params, nil, resp, h.authFunc())
+	err = h.do(ctx, "GET", "indicators/queries/processes/v1", params, nil, resp, h.authFunc())
+	if err == nil {
+		err = h.partial(resp.Err())
+	}
 	return
 }
 
 // ProcessesRanOnJSON ...
 func (h *Host) ProcessesRanOnJSON(t, v, device string, w io.Writer) (err error) {
+	return h.ProcessesRanOnJSONContext(context.Background(), t, v, device, w)
+}
+
+// ProcessesRanOnJSONContext is like ProcessesRanOnJSON with a context for cancellation and deadlines
+func (h *Host) ProcessesRanOnJSONContext(ctx context.Context, t, v, device string, w io.Writer) (err error) {
 	params := url.Values{"type": {t}, "value": {v}, "device_id": {device}}
-	err = h.do("GET", "indicators/queries/processes/v1", params, nil, w, h.authFunc())
+	err = h.do(ctx, "GET", "indicators/queries/processes/v1", params, nil, w, h.authFunc())
 	return
 }
 
 // ProcessDetails ...
 func (h *Host) ProcessDetails(ids []string) (resp *ProcessResponse, err error) {
-	resp = &ProcessResponse{}
+	return h.ProcessDetailsContext(context.Background(), ids)
+}
+
+// ProcessDetailsContext is like ProcessDetails with a context for cancellation and deadlines
+func (h *Host) ProcessDetailsContext(ctx context.Context, ids []string) (resp *ProcessResponse, err error) {
+	resp = &ProcessResponse{items: ids}
 	params := url.Values{}
 	addStringArr("ids", ids, params)
-	err = h.do("GET", "processes/entities/processes/v1", params, nil, resp, h.authFunc())
+	err = h.do(ctx, "GET", "processes/entities/processes/v1", params, nil, resp, h.authFunc())
+	if err == nil {
+		err = h.partial(resp.Err())
+	}
 	return
 }
 
 // ProcessDetailsJSON ...
 func (h *Host) ProcessDetailsJSON(ids []string, w io.Writer) (err error) {
+	return h.ProcessDetailsJSONContext(context.Background(), ids, w)
+}
+
+// ProcessDetailsJSONContext is like ProcessDetailsJSON with a context for cancellation and deadlines
+func (h *Host) ProcessDetailsJSONContext(ctx context.Context, ids []string, w io.Writer) (err error) {
 	params := url.Values{}
 	addStringArr("ids", ids, params)
-	err = h.do("GET", "processes/entities/processes/v1", params, nil, w, h.authFunc())
+	err = h.do(ctx, "GET", "processes/entities/processes/v1", params, nil, w, h.authFunc())
 	return
 }
 
 // UploadIOCs ...
 func (h *Host) UploadIOCs(iocs []IOC) (resp *SearchIOCsResponse, err error) {
-	resp = &SearchIOCsResponse{}
+	return h.UploadIOCsContext(context.Background(), iocs)
+}
+
+// UploadIOCsContext is like UploadIOCs with a context for cancellation and deadlines
+func (h *Host) UploadIOCsContext(ctx context.Context, iocs []IOC) (resp *SearchIOCsResponse, err error) {
+	resp = &SearchIOCsResponse{items: iocValues(iocs)}
 	var b bytes.Buffer
 	err = json.NewEncoder(&b).Encode(iocs)
 	if err != nil {
 		return
 	}
-	err = h.do("POST", "indicators/entities/iocs/v1", nil, &b, resp, h.authFunc())
+	err = h.do(ctx, "POST", "indicators/entities/iocs/v1", nil, &b, resp, h.authFunc())
+	if err == nil {
+		err = h.partial(resp.Err())
+	}
 	return
 }
 
 // UpdateIOCs ...
 func (h *Host) UpdateIOCs(ids []string, ioc *IOC) (resp *SearchIOCsResponse, err error) {
+	return h.UpdateIOCsContext(context.Background(), ids, ioc)
+}
+
+// UpdateIOCsContext is like UpdateIOCs with a context for cancellation and deadlines
+func (h *Host) UpdateIOCsContext(ctx context.Context, ids []string, ioc *IOC) (resp *SearchIOCsResponse, err error) {
 	if ioc == nil {
 		return nil, ErrMissingParams
 	}
-	resp = &SearchIOCsResponse{}
+	resp = &SearchIOCsResponse{items: ids}
 	params := url.Values{}
 	addStringArr("ids", ids, params)
 	var b bytes.Buffer
@@ -275,39 +420,194 @@ func (h *Host) UpdateIOCs(ids []string, ioc *IOC) (resp *SearchIOCsResponse, err
 	if err != nil {
 		return
 	}
-	err = h.do("PATCH", "indicators/entities/iocs/v1", params, &b, resp, h.authFunc())
+	err = h.do(ctx, "PATCH", "indicators/entities/iocs/v1", params, &b, resp, h.authFunc())
+	if err == nil {
+		err = h.partial(resp.Err())
+	}
 	return
 }
 
 // DeleteIOCs ...
 func (h *Host) DeleteIOCs(ids []string) (resp *SearchIOCsResponse, err error) {
-	resp = &SearchIOCsResponse{}
+	return h.DeleteIOCsContext(context.Background(), ids)
+}
+
+// DeleteIOCsContext is like DeleteIOCs with a context for cancellation and deadlines
+func (h *Host) DeleteIOCsContext(ctx context.Context, ids []string) (resp *SearchIOCsResponse, err error) {
+	resp = &SearchIOCsResponse{items: ids}
 	params := url.Values{}
 	addStringArr("ids", ids, params)
-	err = h.do("DELETE", "indicators/entities/iocs/v1", params, nil, resp, h.authFunc())
+	err = h.do(ctx, "DELETE", "indicators/entities/iocs/v1", params, nil, resp, h.authFunc())
+	if err == nil {
+		err = h.partial(resp.Err())
+	}
 	return
 }
 
-// DeviceSearch ...
-func (h *Host) DeviceSearch(filter string, query string) (resp *SearchIOCsResponse, err error) {
-	resp = &SearchIOCsResponse{}
+// DeviceSearchRequest searches for devices by FQL filter and free text query
+type DeviceSearchRequest struct {
+	Filter string     `json:"filter"`
+	Query  string     `json:"q"`
+	Sort   *SortField `json:"sort"`
+	Paging
+}
+
+func deviceSearchRequestToParams(req *DeviceSearchRequest) url.Values {
 	params := url.Values{}
-	if len(filter) > 0 {
-		params.Add("filter",filter)
+	addString("filter", req.Filter, params)
+	addString("q", req.Query, params)
+	if req.Sort != nil {
+		addSortFields("sort", []SortField{*req.Sort}, params)
+	}
+	if req.Limit != 0 {
+		addInt("limit", req.Limit, params)
+	}
+	if req.Offset != 0 {
+		addInt("offset", req.Offset, params)
 	}
-	if len(query) > 0 {
-		params.Add("q",query)
+	return params
+}
+
+// DeviceSearch ...
+func (h *Host) DeviceSearch(filter string, query string) (resp *QueryResponse, err error) {
+	return h.DeviceSearchContext(context.Background(), filter, query)
+}
+
+// DeviceSearchContext is like DeviceSearch with a context for cancellation and deadlines
+func (h *Host) DeviceSearchContext(ctx context.Context, filter string, query string) (resp *QueryResponse, err error) {
+	return h.SearchDevicesContext(ctx, &DeviceSearchRequest{Filter: filter, Query: query})
+}
+
+// SearchDevices returns a page of device IDs matching the request
+func (h *Host) SearchDevices(req *DeviceSearchRequest) (resp *QueryResponse, err error) {
+	return h.SearchDevicesContext(context.Background(), req)
+}
+
+// SearchDevicesContext is like SearchDevices with a context for cancellation and deadlines
+func (h *Host) SearchDevicesContext(ctx context.Context, req *DeviceSearchRequest) (resp *QueryResponse, err error) {
+	resp = &QueryResponse{}
+	params := deviceSearchRequestToParams(req)
+	err = h.do(ctx, "GET", "devices/queries/devices/v1", params, nil, resp, h.authFunc())
+	if err == nil {
+		err = h.partial(resp.Err())
 	}
-	err = h.do("GET", "devices/queries/devices/v1", params, nil, resp, h.authFunc())
 	return
 }
 
 // Resolve ...
 func (h *Host) Resolve(ids []string, toState string) (resp *ResolveResponse, err error) {
-	resp = &ResolveResponse{}
+	return h.ResolveContext(context.Background(), ids, toState)
+}
+
+// ResolveContext is like Resolve with a context for cancellation and deadlines
+func (h *Host) ResolveContext(ctx context.Context, ids []string, toState string) (resp *ResolveResponse, err error) {
+	resp = &ResolveResponse{items: ids}
 	params := url.Values{}
 	addStringArr("ids", ids, params)
 	addString("to_status", toState, params)
-	err = h.do("PATCH", "detects/entities/detects/v1", params, nil, resp, h.authFunc())
+	err = h.do(ctx, "PATCH", "detects/entities/detects/v1", params, nil, resp, h.authFunc())
+	if err == nil {
+		err = h.partial(resp.Err())
+	}
+	return
+}
+
+// queryPage adapts a query returning a page of IDs to the pager
+func queryPage(ids *[]string, query func(ctx context.Context, offset int, after string) (*QueryResponse, error)) pageFunc {
+	return func(ctx context.Context, offset int, after string) (int, int, string, error) {
+		resp, err := query(ctx, offset, after)
+		if err != nil {
+			return 0, 0, "", err
+		}
+		*ids = resp.Resources
+		return len(resp.Resources), resp.Meta.Pagination.Total, resp.Meta.Pagination.After, nil
+	}
+}
+
+// SearchIOCsAll returns a pager over all the IOC IDs matching the request starting at its offset.
+// The request limit is used as the page size. If max is positive, at most max IDs are returned.
+func (h *Host) SearchIOCsAll(req *SearchIOCsRequest, max int) *IDPager {
+	return h.SearchIOCsAllContext(context.Background(), req, max)
+}
+
+// SearchIOCsAllContext is like SearchIOCsAll with a context for cancellation and deadlines
+func (h *Host) SearchIOCsAllContext(ctx context.Context, req *SearchIOCsRequest, max int) *IDPager {
+	r := *req
+	p := &IDPager{}
+	p.pager = newPager(ctx, r.Offset, max, queryPage(&p.ids, func(ctx context.Context, offset int, _ string) (*QueryResponse, error) {
+		r.Offset = offset
+		return h.SearchIOCsContext(ctx, &r)
+	}))
+	return p
+}
+
+// DeviceSearchAll returns a pager over all the device IDs matching the request.
+// The request limit is used as the page size. If max is positive, at most max IDs are returned.
+//
+// Devices are paged with the scroll API which is not bound by the 10,000 offset limit of SearchDevices.
+// The scroll API does not support free text queries and starting offsets, so requests with a Query or an
+// Offset are paged by offset instead.
+func (h *Host) DeviceSearchAll(req *DeviceSearchRequest, max int) *IDPager {
+	return h.DeviceSearchAllContext(context.Background(), req, max)
+}
+
+// DeviceSearchAllContext is like DeviceSearchAll with a context for cancellation and deadlines
+func (h *Host) DeviceSearchAllContext(ctx context.Context, req *DeviceSearchRequest, max int) *IDPager {
+	r := *req
+	p := &IDPager{}
+	if r.Query != "" || r.Offset != 0 {
+		p.pager = newPager(ctx, r.Offset, max, queryPage(&p.ids, func(ctx context.Context, offset int, _ string) (*QueryResponse, error) {
+			r.Offset = offset
+			return h.SearchDevicesContext(ctx, &r)
+		}))
+		return p
+	}
+	p.pager = newPager(ctx, 0, max, func(ctx context.Context, offset int, after string) (int, int, string, error) {
+		resp, err := h.scrollDevices(ctx, &r, after)
+		if err != nil {
+			return 0, 0, "", err
+		}
+		p.ids = resp.Resources
+		n, total, next := len(resp.Resources), resp.Meta.Pagination.Total, resp.Meta.Pagination.Offset
+		// The API keeps handing out tokens after the last page
+		if offset+n >= total {
+			next = ""
+		}
+		return n, total, next, nil
+	})
+	return p
+}
+
+// deviceScrollResponse is returned by the device scroll API which returns the token of the next page as the offset
+type deviceScrollResponse struct {
+	Meta struct {
+		QueryTime  float64 `json:"query_time"`
+		Pagination struct {
+			Total     int    `json:"total"`
+			Offset    string `json:"offset"`
+			ExpiresAt int64  `json:"expires_at"`
+		} `json:"pagination"`
+		TraceID string `json:"trace_id"`
+	} `json:"meta"`
+	Resources []string `json:"resources"`
+	Errors    []Error  `json:"errors"`
+}
+
+// scrollDevices returns the page of device IDs following the after token, or the first page if it is empty
+func (h *Host) scrollDevices(ctx context.Context, req *DeviceSearchRequest, after string) (resp *deviceScrollResponse, err error) {
+	resp = &deviceScrollResponse{}
+	params := url.Values{}
+	addString("filter", req.Filter, params)
+	if req.Sort != nil {
+		addSortFields("sort", []SortField{*req.Sort}, params)
+	}
+	if req.Limit != 0 {
+		addInt("limit", req.Limit, params)
+	}
+	addString("offset", after, params)
+	err = h.do(ctx, "GET", "devices/queries/devices-scroll/v1", params, nil, resp, h.authFunc())
+	if err == nil {
+		err = h.partial(newPartialError(nil, resp.Errors))
+	}
 	return
 }
@@ -0,0 +1,126 @@
+package gocs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestServer starts a server with the handler that is closed when the test ends
+func newTestServer(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// newTestHost returns a Host talking to a test server with the handler
+func newTestHost(t *testing.T, handler http.HandlerFunc, options ...OptionFunc) *Host {
+	t.Helper()
+	srv := newTestServer(t, handler)
+	options = append([]OptionFunc{SetCredentials("id", "key"), SetURL(srv.URL)}, options...)
+	h, err := NewHost(options...)
+	if err != nil {
+		t.Fatalf("NewHost: %v", err)
+	}
+	return h
+}
+
+// newTestIntel returns an Intel talking to a test server with the handler
+func newTestIntel(t *testing.T, handler http.HandlerFunc, options ...OptionFunc) *Intel {
+	t.Helper()
+	srv := newTestServer(t, handler)
+	options = append([]OptionFunc{SetCredentials("id", "key"), SetURL(srv.URL)}, options...)
+	c, err := NewIntel(options...)
+	if err != nil {
+		t.Fatalf("NewIntel: %v", err)
+	}
+	return c
+}
+
+// writeJSON writes v as the JSON response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func TestContextCancel(t *testing.T) {
+	h := newTestHost(t, func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	_, err := h.SearchIOCsContext(ctx, &SearchIOCsRequest{})
+	if err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestContextDeadline(t *testing.T) {
+	c := newTestIntel(t, func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := c.ActorsContext(ctx, &ActorRequest{})
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestContextDoneBeforeSend(t *testing.T) {
+	var calls int32
+	h := newTestHost(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := h.DeviceCountContext(ctx, "domain", "example.com"); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if calls != 0 {
+		t.Fatalf("expected no requests, got %d", calls)
+	}
+}
+
+func TestHostAuthAndDecode(t *testing.T) {
+	h := newTestHost(t, func(w http.ResponseWriter, r *http.Request) {
+		if id, key, ok := r.BasicAuth(); !ok || id != "id" || key != "key" {
+			t.Errorf("unexpected credentials %q %q", id, key)
+		}
+		if r.URL.Path != "/indicators/queries/iocs/v1" || r.URL.Query().Get("types") != "domain" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"resources": []string{"a", "b"}})
+	})
+	resp, err := h.SearchIOCs(&SearchIOCsRequest{Types: []string{"domain"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Resources) != 2 || resp.Resources[1] != "b" {
+		t.Fatalf("unexpected resources %v", resp.Resources)
+	}
+}
+
+func TestJSONStreamsBody(t *testing.T) {
+	const body = `{"resources":["x"]}`
+	c := newTestIntel(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(AuthHeaderID) != "id" || r.Header.Get(AuthHeaderKey) != "key" {
+			t.Errorf("missing credentials headers")
+		}
+		w.Write([]byte(body))
+	})
+	var b bytes.Buffer
+	if err := c.ActorsJSONContext(context.Background(), &ActorRequest{}, &b); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != body {
+		t.Fatalf("unexpected body %q", b.String())
+	}
+}
@@ -0,0 +1,371 @@
+package gocs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/demisto/gocs/fql"
+)
+
+// Host group types
+const (
+	HostGroupStatic  = "static"
+	HostGroupDynamic = "dynamic"
+)
+
+// HostGroup is a group of hosts. Static groups have explicit members while dynamic groups
+// contain the hosts matching the FQL assignment rule.
+type HostGroup struct {
+	ID                string    `json:"id,omitempty"`
+	GroupType         string    `json:"group_type,omitempty"`
+	Name              string    `json:"name,omitempty"`
+	Description       string    `json:"description,omitempty"`
+	AssignmentRule    string    `json:"assignment_rule,omitempty"`
+	CreatedBy         string    `json:"created_by,omitempty"`
+	CreatedTimestamp  time.Time `json:"created_timestamp"`
+	ModifiedBy        string    `json:"modified_by,omitempty"`
+	ModifiedTimestamp time.Time `json:"modified_timestamp"`
+}
+
+// UnmarshalJSON decodes the dates from epoch seconds or RFC3339 strings
+func (g *HostGroup) UnmarshalJSON(b []byte) error {
+	type hostGroup HostGroup
+	aux := &struct {
+		*hostGroup
+		CreatedTimestamp  timestamp `json:"created_timestamp"`
+		ModifiedTimestamp timestamp `json:"modified_timestamp"`
+	}{hostGroup: (*hostGroup)(g)}
+	if err := json.Unmarshal(b, aux); err != nil {
+		return err
+	}
+	g.CreatedTimestamp, g.ModifiedTimestamp = aux.CreatedTimestamp.Time, aux.ModifiedTimestamp.Time
+	return nil
+}
+
+// HostGroupsResponse is returned from the host group APIs
+type HostGroupsResponse struct {
+	Meta struct {
+		QueryTime  float64 `json:"query_time"`
+		Pagination struct {
+			Total  int `json:"total"`
+			Offset int `json:"offset"`
+			Limit  int `json:"limit"`
+		} `json:"pagination"`
+		TraceID string `json:"trace_id"`
+	} `json:"meta"`
+	Resources []HostGroup `json:"resources"`
+	Errors    []Error     `json:"errors"`
+	items     []string
+}
+
+// Err returns a *PartialError if the response carries per-item errors, nil otherwise
+func (r *HostGroupsResponse) Err() error {
+	return newPartialError(r.items, r.Errors)
+}
+
+// maxMembersPerAction is the number of devices we add or remove in a single host group action
+const maxMembersPerAction = 100
+
+// HostGroupUpdate updates a host group. Nil fields are left unchanged.
+type HostGroupUpdate struct {
+	ID             string  `json:"id"`                        // Required
+	Name           string  `json:"name,omitempty"`            // Empty to leave unchanged
+	Description    *string `json:"description,omitempty"`     // Point to an empty string to clear the description
+	AssignmentRule *string `json:"assignment_rule,omitempty"` // Only for dynamic groups
+}
+
+// resourcesBody encodes the resources as the JSON body expected by the API
+func resourcesBody(resources interface{}) (*bytes.Buffer, error) {
+	var b bytes.Buffer
+	err := json.NewEncoder(&b).Encode(struct {
+		Resources interface{} `json:"resources"`
+	}{Resources: resources})
+	return &b, err
+}
+
+// hostGroupsCreateBody encodes the groups as the JSON body expected by the API
+func hostGroupsCreateBody(groups []HostGroup) (*bytes.Buffer, error) {
+	// The read only fields are not accepted so send just the editable ones
+	type editable struct {
+		GroupType      string `json:"group_type"`
+		Name           string `json:"name"`
+		Description    string `json:"description,omitempty"`
+		AssignmentRule string `json:"assignment_rule,omitempty"`
+	}
+	resources := make([]editable, len(groups))
+	for i := range groups {
+		g := &groups[i]
+		resources[i] = editable{GroupType: g.GroupType, Name: g.Name, Description: g.Description}
+		if g.GroupType == HostGroupDynamic {
+			resources[i].AssignmentRule = g.AssignmentRule
+		}
+	}
+	return resourcesBody(resources)
+}
+
+// QueryHostGroups returns a page of host group IDs matching the request
+func (h *Host) QueryHostGroups(req *QueryRequest) (resp *QueryResponse, err error) {
+	return h.QueryHostGroupsContext(context.Background(), req)
+}
+
+// QueryHostGroupsContext is like QueryHostGroups with a context for cancellation and deadlines
+func (h *Host) QueryHostGroupsContext(ctx context.Context, req *QueryRequest) (resp *QueryResponse, err error) {
+	resp = &QueryResponse{}
+	err = h.do(ctx, "GET", "devices/queries/host-groups/v1", queryRequestToParams(req), nil, resp, h.authFunc())
+	if err == nil {
+		err = h.partial(resp.Err())
+	}
+	return
+}
+
+// ListHostGroups returns a page of host groups matching the request
+func (h *Host) ListHostGroups(req *QueryRequest) (resp *HostGroupsResponse, err error) {
+	return h.ListHostGroupsContext(context.Background(), req)
+}
+
+// ListHostGroupsContext is like ListHostGroups with a context for cancellation and deadlines
+func (h *Host) ListHostGroupsContext(ctx context.Context, req *QueryRequest) (resp *HostGroupsResponse, err error) {
+	resp = &HostGroupsResponse{}
+	err = h.do(ctx, "GET", "devices/combined/host-groups/v1", queryRequestToParams(req), nil, resp, h.authFunc())
+	if err == nil {
+		err = h.partial(resp.Err())
+	}
+	return
+}
+
+// HostGroupsAll returns a pager over all the host groups matching the request starting at its offset.
+// The request limit is used as the page size. If max is positive, at most max groups are returned.
+func (h *Host) HostGroupsAll(req *QueryRequest, max int) *HostGroupPager {
+	return h.HostGroupsAllContext(context.Background(), req, max)
+}
+
+// HostGroupsAllContext is like HostGroupsAll with a context for cancellation and deadlines
+func (h *Host) HostGroupsAllContext(ctx context.Context, req *QueryRequest, max int) *HostGroupPager {
+	r := *req
+	p := &HostGroupPager{}
+	p.pager = newPager(ctx, r.Offset, max, func(ctx context.Context, offset int, _ string) (int, int, string, error) {
+		r.Offset = offset
+		resp, err := h.ListHostGroupsContext(ctx, &r)
+		if err != nil {
+			return 0, 0, "", err
+		}
+		p.groups = resp.Resources
+		return len(resp.Resources), resp.Meta.Pagination.Total, "", nil
+	})
+	return p
+}
+
+// GetHostGroups returns the host groups with the given IDs
+func (h *Host) GetHostGroups(ids []string) (resp *HostGroupsResponse, err error) {
+	return h.GetHostGroupsContext(context.Background(), ids)
+}
+
+// GetHostGroupsContext is like GetHostGroups with a context for cancellation and deadlines
+func (h *Host) GetHostGroupsContext(ctx context.Context, ids []string) (resp *HostGroupsResponse, err error) {
+	if len(ids) == 0 {
+		return nil, ErrMissingParams
+	}
+	resp = &HostGroupsResponse{items: ids}
+	params := url.Values{}
+	addStringArr("ids", ids, params)
+	err = h.do(ctx, "GET", "devices/entities/host-groups/v1", params, nil, resp, h.authFunc())
+	if err == nil {
+		err = h.partial(resp.Err())
+	}
+	return
+}
+
+// CreateHostGroups creates the given host groups. Dynamic groups must have an assignment rule.
+func (h *Host) CreateHostGroups(groups []HostGroup) (resp *HostGroupsResponse, err error) {
+	return h.CreateHostGroupsContext(context.Background(), groups)
+}
+
+// CreateHostGroupsContext is like CreateHostGroups with a context for cancellation and deadlines
+func (h *Host) CreateHostGroupsContext(ctx context.Context, groups []HostGroup) (resp *HostGroupsResponse, err error) {
+	if len(groups) == 0 {
+		return nil, ErrMissingParams
+	}
+	names := make([]string, len(groups))
+	for i := range groups {
+		g := &groups[i]
+		if g.Name == "" || (g.GroupType != HostGroupStatic && g.GroupType != HostGroupDynamic) {
+			err = &Error{Code: "bad_host_group", Message: fmt.Sprintf("Host group [%s] must have a name and a group type of static or dynamic", g.Name)}
+			h.errorf("%v\n", err)
+			return nil, err
+		}
+		names[i] = g.Name
+	}
+	resp = &HostGroupsResponse{items: names}
+	b, err := hostGroupsCreateBody(groups)
+	if err != nil {
+		return
+	}
+	err = h.do(ctx, "POST", "devices/entities/host-groups/v1", nil, b, resp, h.authFunc())
+	if err == nil {
+		err = h.partial(resp.Err())
+	}
+	return
+}
+
+// UpdateHostGroups updates the name, description and assignment rule of host groups.
+// Only the fields that are set are updated. The type of a group cannot be changed.
+func (h *Host) UpdateHostGroups(groups []HostGroupUpdate) (resp *HostGroupsResponse, err error) {
+	return h.UpdateHostGroupsContext(context.Background(), groups)
+}
+
+// UpdateHostGroupsContext is like UpdateHostGroups with a context for cancellation and deadlines
+func (h *Host) UpdateHostGroupsContext(ctx context.Context, groups []HostGroupUpdate) (resp *HostGroupsResponse, err error) {
+	if len(groups) == 0 {
+		return nil, ErrMissingParams
+	}
+	ids := make([]string, len(groups))
+	for i := range groups {
+		if groups[i].ID == "" {
+			return nil, ErrMissingParams
+		}
+		ids[i] = groups[i].ID
+	}
+	resp = &HostGroupsResponse{items: ids}
+	b, err := resourcesBody(groups)
+	if err != nil {
+		return
+	}
+	err = h.do(ctx, "PATCH", "devices/entities/host-groups/v1", nil, b, resp, h.authFunc())
+	if err == nil {
+		err = h.partial(resp.Err())
+	}
+	return
+}
+
+// DeleteHostGroups deletes the host groups with the given IDs
+func (h *Host) DeleteHostGroups(ids []string) (resp *QueryResponse, err error) {
+	return h.DeleteHostGroupsContext(context.Background(), ids)
+}
+
+// DeleteHostGroupsContext is like DeleteHostGroups with a context for cancellation and deadlines
+func (h *Host) DeleteHostGroupsContext(ctx context.Context, ids []string) (resp *QueryResponse, err error) {
+	if len(ids) == 0 {
+		return nil, ErrMissingParams
+	}
+	resp = &QueryResponse{items: ids}
+	params := url.Values{}
+	addStringArr("ids", ids, params)
+	err = h.do(ctx, "DELETE", "devices/entities/host-groups/v1", params, nil, resp, h.authFunc())
+	if err == nil {
+		err = h.partial(resp.Err())
+	}
+	return
+}
+
+// deviceIDFilter returns the FQL filter matching the given device IDs
+func deviceIDFilter(ids []string) string {
+	values := make([]interface{}, len(ids))
+	for i := range ids {
+		values[i] = ids[i]
+	}
+	// The host group actions take the filter in parentheses
+	return "(" + fql.In("device_id", values...).String() + ")"
+}
+
+// actionParameter is a name value pair passed to the entity action APIs
+type actionParameter struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// actionBody encodes the body expected by the entity action APIs
+func actionBody(ids []string, params ...actionParameter) (*bytes.Buffer, error) {
+	var b bytes.Buffer
+	err := json.NewEncoder(&b).Encode(struct {
+		ActionParameters []actionParameter `json:"action_parameters,omitempty"`
+		IDs              []string          `json:"ids"`
+	}{ActionParameters: params, IDs: ids})
+	return &b, err
+}
+
+// hostGroupMembersAction adds or removes members of a static host group.
+// Large device lists are split into several requests and the results are combined.
+func (h *Host) hostGroupMembersAction(ctx context.Context, action, groupID string, deviceIDs []string) (resp *HostGroupsResponse, err error) {
+	if groupID == "" || len(deviceIDs) == 0 {
+		return nil, ErrMissingParams
+	}
+	resp = &HostGroupsResponse{items: []string{groupID}}
+	params := url.Values{"action_name": {action}}
+	for _, chunk := range chunks(deviceIDs, maxMembersPerAction) {
+		b, err := actionBody([]string{groupID}, actionParameter{Name: "filter", Value: deviceIDFilter(chunk)})
+		if err != nil {
+			return resp, err
+		}
+		r := &HostGroupsResponse{}
+		if err = h.do(ctx, "POST", "devices/entities/host-group-actions/v1", params, b, r, h.authFunc()); err != nil {
+			return resp, err
+		}
+		resp.Meta.QueryTime += r.Meta.QueryTime
+		resp.Meta.TraceID = r.Meta.TraceID
+		// Every request returns the updated group so keep the latest
+		resp.Resources = r.Resources
+		resp.Errors = append(resp.Errors, r.Errors...)
+	}
+	err = h.partial(resp.Err())
+	return
+}
+
+// AddHostGroupMembers adds the devices to the static host group
+func (h *Host) AddHostGroupMembers(groupID string, deviceIDs []string) (resp *HostGroupsResponse, err error) {
+	return h.hostGroupMembersAction(context.Background(), "add-hosts", groupID, deviceIDs)
+}
+
+// AddHostGroupMembersContext is like AddHostGroupMembers with a context for cancellation and deadlines
+func (h *Host) AddHostGroupMembersContext(ctx context.Context, groupID string, deviceIDs []string) (resp *HostGroupsResponse, err error) {
+	return h.hostGroupMembersAction(ctx, "add-hosts", groupID, deviceIDs)
+}
+
+// RemoveHostGroupMembers removes the devices from the static host group
+func (h *Host) RemoveHostGroupMembers(groupID string, deviceIDs []string) (resp *HostGroupsResponse, err error) {
+	return h.hostGroupMembersAction(context.Background(), "remove-hosts", groupID, deviceIDs)
+}
+
+// RemoveHostGroupMembersContext is like RemoveHostGroupMembers with a context for cancellation and deadlines
+func (h *Host) RemoveHostGroupMembersContext(ctx context.Context, groupID string, deviceIDs []string) (resp *HostGroupsResponse, err error) {
+	return h.hostGroupMembersAction(ctx, "remove-hosts", groupID, deviceIDs)
+}
+
+// QueryHostGroupMembers returns a page of the IDs of the devices in the host group
+func (h *Host) QueryHostGroupMembers(groupID string, req *QueryRequest) (resp *QueryResponse, err error) {
+	return h.QueryHostGroupMembersContext(context.Background(), groupID, req)
+}
+
+// QueryHostGroupMembersContext is like QueryHostGroupMembers with a context for cancellation and deadlines
+func (h *Host) QueryHostGroupMembersContext(ctx context.Context, groupID string, req *QueryRequest) (resp *QueryResponse, err error) {
+	if groupID == "" {
+		return nil, ErrMissingParams
+	}
+	resp = &QueryResponse{}
+	params := queryRequestToParams(req)
+	params.Set("id", groupID)
+	err = h.do(ctx, "GET", "devices/queries/host-group-members/v1", params, nil, resp, h.authFunc())
+	if err == nil {
+		err = h.partial(resp.Err())
+	}
+	return
+}
+
+// HostGroupMembersAll returns a pager over the IDs of all the devices in the host group
+func (h *Host) HostGroupMembersAll(groupID string, req *QueryRequest, max int) *IDPager {
+	return h.HostGroupMembersAllContext(context.Background(), groupID, req, max)
+}
+
+// HostGroupMembersAllContext is like HostGroupMembersAll with a context for cancellation and deadlines
+func (h *Host) HostGroupMembersAllContext(ctx context.Context, groupID string, req *QueryRequest, max int) *IDPager {
+	r := *req
+	p := &IDPager{}
+	p.pager = newPager(ctx, r.Offset, max, queryPage(&p.ids, func(ctx context.Context, offset int, _ string) (*QueryResponse, error) {
+		r.Offset = offset
+		return h.QueryHostGroupMembersContext(ctx, groupID, &r)
+	}))
+	return p
+}
@@ -0,0 +1,136 @@
+package gocs
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// decodeResources decodes the resources of the request body as generic maps
+func decodeResources(t *testing.T, r *http.Request) []map[string]interface{} {
+	t.Helper()
+	var body struct {
+		Resources []map[string]interface{} `json:"resources"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		t.Errorf("bad body: %v", err)
+	}
+	return body.Resources
+}
+
+func TestUpdateHostGroupsSendsSetFields(t *testing.T) {
+	var resources []map[string]interface{}
+	h := newTestHost(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PATCH" {
+			t.Errorf("unexpected method %s", r.Method)
+		}
+		resources = decodeResources(t, r)
+		writeJSON(w, http.StatusOK, map[string]interface{}{"resources": []HostGroup{{ID: "g1", Name: "renamed"}}})
+	})
+	empty := ""
+	_, err := h.UpdateHostGroups([]HostGroupUpdate{{ID: "g1", Name: "renamed"}, {ID: "g2", Description: &empty}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resources[0]) != 2 || resources[0]["id"] != "g1" || resources[0]["name"] != "renamed" {
+		t.Fatalf("expected only the ID and the name, got %v", resources[0])
+	}
+	if desc, ok := resources[1]["description"]; len(resources[1]) != 2 || !ok || desc != "" {
+		t.Fatalf("expected the description to be cleared, got %v", resources[1])
+	}
+	for _, res := range resources {
+		if _, ok := res["group_type"]; ok {
+			t.Fatalf("group_type must not be sent on update, got %v", res)
+		}
+	}
+}
+
+func TestUpdateHostGroupsRequiresID(t *testing.T) {
+	h := newTestHost(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request")
+	})
+	if _, err := h.UpdateHostGroups([]HostGroupUpdate{{Name: "x"}}); err != ErrMissingParams {
+		t.Fatalf("expected ErrMissingParams, got %v", err)
+	}
+}
+
+func TestCreateHostGroups(t *testing.T) {
+	var resources []map[string]interface{}
+	h := newTestHost(t, func(w http.ResponseWriter, r *http.Request) {
+		resources = decodeResources(t, r)
+		writeJSON(w, http.StatusCreated, map[string]interface{}{"resources": []HostGroup{{ID: "g1"}, {ID: "g2"}}})
+	})
+	resp, err := h.CreateHostGroups([]HostGroup{
+		{Name: "static", GroupType: HostGroupStatic, AssignmentRule: "ignored"},
+		{Name: "dynamic", GroupType: HostGroupDynamic, Description: "windows", AssignmentRule: "platform_name:'Windows'"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Resources) != 2 {
+		t.Fatalf("unexpected resources %v", resp.Resources)
+	}
+	if _, ok := resources[0]["assignment_rule"]; ok || resources[0]["group_type"] != HostGroupStatic {
+		t.Fatalf("unexpected static group %v", resources[0])
+	}
+	if resources[1]["assignment_rule"] != "platform_name:'Windows'" || resources[1]["description"] != "windows" {
+		t.Fatalf("unexpected dynamic group %v", resources[1])
+	}
+	if _, ok := resources[0]["id"]; ok {
+		t.Fatalf("read only fields must not be sent, got %v", resources[0])
+	}
+	if _, err = h.CreateHostGroups([]HostGroup{{Name: "x", GroupType: "other"}}); err == nil {
+		t.Fatal("expected an error for an invalid group type")
+	}
+}
+
+func TestHostGroupMembersChunks(t *testing.T) {
+	var filters []string
+	h := newTestHost(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("action_name") != "add-hosts" {
+			t.Errorf("unexpected action %s", r.URL)
+		}
+		var body struct {
+			ActionParameters []actionParameter `json:"action_parameters"`
+			IDs              []string          `json:"ids"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if len(body.IDs) != 1 || body.IDs[0] != "g1" || body.ActionParameters[0].Name != "filter" {
+			t.Errorf("unexpected body %+v", body)
+		}
+		filters = append(filters, body.ActionParameters[0].Value)
+		writeJSON(w, http.StatusOK, map[string]interface{}{"resources": []HostGroup{{ID: "g1"}}})
+	})
+	ids := make([]string, 250)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("dev%d", i)
+	}
+	resp, err := h.AddHostGroupMembers("g1", ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(filters) != 3 || strings.Count(filters[0], ",") != maxMembersPerAction-1 || !strings.Contains(filters[2], "'dev249'") {
+		t.Fatalf("unexpected filters %d", len(filters))
+	}
+	if len(resp.Resources) != 1 {
+		t.Fatalf("expected the group once, got %v", resp.Resources)
+	}
+}
+
+func TestDeviceIDFilter(t *testing.T) {
+	if f := deviceIDFilter([]string{"a", "b'c", `d\`}); f != `(device_id:['a','b\'c','d\\'])` {
+		t.Fatalf("unexpected filter %s", f)
+	}
+}
+
+func TestHostGroupTimestamps(t *testing.T) {
+	var g HostGroup
+	if err := json.Unmarshal([]byte(`{"id":"g1","created_timestamp":1583056800,"modified_timestamp":""}`), &g); err != nil {
+		t.Fatal(err)
+	}
+	if g.ID != "g1" || g.CreatedTimestamp.Unix() != 1583056800 || !g.ModifiedTimestamp.IsZero() {
+		t.Fatalf("unexpected group %+v", g)
+	}
+}
@@ -0,0 +1,330 @@
+package gocs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Incident statuses
+const (
+	IncidentStatusNew        = 20
+	IncidentStatusReopened   = 25
+	IncidentStatusInProgress = 30
+	IncidentStatusClosed     = 40
+)
+
+// Incident groups related detections and behaviors across hosts
+type Incident struct {
+	IncidentID                 string            `json:"incident_id"`
+	IncidentType               int               `json:"incident_type"`
+	CID                        string            `json:"cid"`
+	Name                       string            `json:"name"`
+	Description                string            `json:"description"`
+	HostIDs                    []string          `json:"host_ids"`
+	Hosts                      []DetectionDevice `json:"hosts"`
+	LateralMovementHostIDs     []string          `json:"lm_host_ids"`
+	LateralMovementHostsCapped bool              `json:"lm_hosts_capped"`
+	Users                      []string          `json:"users"`
+	Created                    time.Time         `json:"created"`
+	Start                      time.Time         `json:"start"`
+	End                        time.Time         `json:"end"`
+	ModifiedTimestamp          time.Time         `json:"modified_timestamp"`
+	State                      string            `json:"state"`
+	Status                     int               `json:"status"` // One of the IncidentStatus constants
+	FineScore                  int               `json:"fine_score"`
+	AssignedTo                 string            `json:"assigned_to"`
+	AssignedToName             string            `json:"assigned_to_name"`
+	Tags                       []string          `json:"tags"`
+	Tactics                    []string          `json:"tactics"`
+	Techniques                 []string          `json:"techniques"`
+	Objectives                 []string          `json:"objectives"`
+	Visibility                 int               `json:"visibility"`
+}
+
+// UnmarshalJSON decodes the dates from epoch seconds or RFC3339 strings
+func (i *Incident) UnmarshalJSON(b []byte) error {
+	type incident Incident
+	aux := &struct {
+		*incident
+		Created           timestamp `json:"created"`
+		Start             timestamp `json:"start"`
+		End               timestamp `json:"end"`
+		ModifiedTimestamp timestamp `json:"modified_timestamp"`
+	}{incident: (*incident)(i)}
+	if err := json.Unmarshal(b, aux); err != nil {
+		return err
+	}
+	i.Created = aux.Created.Time
+	i.Start = aux.Start.Time
+	i.End = aux.End.Time
+	i.ModifiedTimestamp = aux.ModifiedTimestamp.Time
+	return nil
+}
+
+// IncidentsResponse is returned from GetIncidents
+type IncidentsResponse struct {
+	Meta struct {
+		QueryTime float64 `json:"query_time"`
+		TraceID   string  `json:"trace_id"`
+	} `json:"meta"`
+	Resources []Incident `json:"resources"`
+	Errors    []Error    `json:"errors"`
+	items     []string
+}
+
+// Err returns a *PartialError if the response carries per-item errors, nil otherwise
+func (r *IncidentsResponse) Err() error {
+	return newPartialError(r.items, r.Errors)
+}
+
+// IncidentBehavior is a behavior that is part of an incident
+type IncidentBehavior struct {
+	BehaviorID         string    `json:"behavior_id"`
+	CID                string    `json:"cid"`
+	AgentID            string    `json:"aid"`
+	IncidentID         string    `json:"incident_id"`
+	IncidentIDs        []string  `json:"incident_ids"`
+	PatternID          int       `json:"pattern_id"`
+	TemplateInstanceID int       `json:"template_instance_id"`
+	Timestamp          time.Time `json:"timestamp"`
+	CommandLine        string    `json:"cmdline"`
+	FilePath           string    `json:"filepath"`
+	Domain             string    `json:"domain"`
+	UserName           string    `json:"user_name"`
+	SHA256             string    `json:"sha256"`
+	DisplayName        string    `json:"display_name"`
+	Objective          string    `json:"objective"`
+	Tactic             string    `json:"tactic"`
+	TacticID           string    `json:"tactic_id"`
+	Technique          string    `json:"technique"`
+	TechniqueID        string    `json:"technique_id"`
+	CompoundTTP        string    `json:"compound_tto"`
+	PatternDisposition int       `json:"pattern_disposition"`
+}
+
+// UnmarshalJSON decodes the dates from epoch seconds or RFC3339 strings
+func (bh *IncidentBehavior) UnmarshalJSON(b []byte) error {
+	type incidentBehavior IncidentBehavior
+	aux := &struct {
+		*incidentBehavior
+		Timestamp timestamp `json:"timestamp"`
+	}{incidentBehavior: (*incidentBehavior)(bh)}
+	if err := json.Unmarshal(b, aux); err != nil {
+		return err
+	}
+	bh.Timestamp = aux.Timestamp.Time
+	return nil
+}
+
+// BehaviorsResponse is returned from GetBehaviors
+type BehaviorsResponse struct {
+	Meta struct {
+		QueryTime float64 `json:"query_time"`
+		TraceID   string  `json:"trace_id"`
+	} `json:"meta"`
+	Resources []IncidentBehavior `json:"resources"`
+	Errors    []Error            `json:"errors"`
+	items     []string
+}
+
+// Err returns a *PartialError if the response carries per-item errors, nil otherwise
+func (r *BehaviorsResponse) Err() error {
+	return newPartialError(r.items, r.Errors)
+}
+
+// QueryIncidents returns a page of incident IDs matching the request
+func (h *Host) QueryIncidents(req *QueryRequest) (resp *QueryResponse, err error) {
+	return h.QueryIncidentsContext(context.Background(), req)
+}
+
+// QueryIncidentsContext is like QueryIncidents with a context for cancellation and deadlines
+func (h *Host) QueryIncidentsContext(ctx context.Context, req *QueryRequest) (resp *QueryResponse, err error) {
+	resp = &QueryResponse{}
+	err = h.do(ctx, "GET", "incidents/queries/incidents/v1", queryRequestToParams(req), nil, resp, h.authFunc())
+	if err == nil {
+		err = h.partial(resp.Err())
+	}
+	return
+}
+
+// QueryIncidentsAll returns a pager over all the incident IDs matching the request starting at its offset.
+// The request limit is used as the page size. If max is positive, at most max IDs are returned.
+func (h *Host) QueryIncidentsAll(req *QueryRequest, max int) *IDPager {
+	return h.QueryIncidentsAllContext(context.Background(), req, max)
+}
+
+// QueryIncidentsAllContext is like QueryIncidentsAll with a context for cancellation and deadlines
+func (h *Host) QueryIncidentsAllContext(ctx context.Context, req *QueryRequest, max int) *IDPager {
+	r := *req
+	p := &IDPager{}
+	p.pager = newPager(ctx, r.Offset, max, queryPage(&p.ids, func(ctx context.Context, offset int, _ string) (*QueryResponse, error) {
+		r.Offset = offset
+		return h.QueryIncidentsContext(ctx, &r)
+	}))
+	return p
+}
+
+// GetIncidents returns the incidents with the given IDs
+func (h *Host) GetIncidents(ids []string) (resp *IncidentsResponse, err error) {
+	return h.GetIncidentsContext(context.Background(), ids)
+}
+
+// GetIncidentsContext is like GetIncidents with a context for cancellation and deadlines
+func (h *Host) GetIncidentsContext(ctx context.Context, ids []string) (resp *IncidentsResponse, err error) {
+	if len(ids) == 0 {
+		return nil, ErrMissingParams
+	}
+	resp = &IncidentsResponse{items: ids}
+	b, err := idsBody(ids)
+	if err != nil {
+		return
+	}
+	err = h.do(ctx, "POST", "incidents/entities/incidents/GET/v1", nil, b, resp, h.authFunc())
+	if err == nil {
+		err = h.partial(resp.Err())
+	}
+	return
+}
+
+// QueryBehaviors returns a page of behavior IDs matching the request
+func (h *Host) QueryBehaviors(req *QueryRequest) (resp *QueryResponse, err error) {
+	return h.QueryBehaviorsContext(context.Background(), req)
+}
+
+// QueryBehaviorsContext is like QueryBehaviors with a context for cancellation and deadlines
+func (h *Host) QueryBehaviorsContext(ctx context.Context, req *QueryRequest) (resp *QueryResponse, err error) {
+	resp = &QueryResponse{}
+	err = h.do(ctx, "GET", "incidents/queries/behaviors/v1", queryRequestToParams(req), nil, resp, h.authFunc())
+	if err == nil {
+		err = h.partial(resp.Err())
+	}
+	return
+}
+
+// QueryBehaviorsAll returns a pager over all the behavior IDs matching the request starting at its offset.
+// The request limit is used as the page size. If max is positive, at most max IDs are returned.
+func (h *Host) QueryBehaviorsAll(req *QueryRequest, max int) *IDPager {
+	return h.QueryBehaviorsAllContext(context.Background(), req, max)
+}
+
+// QueryBehaviorsAllContext is like QueryBehaviorsAll with a context for cancellation and deadlines
+func (h *Host) QueryBehaviorsAllContext(ctx context.Context, req *QueryRequest, max int) *IDPager {
+	r := *req
+	p := &IDPager{}
+	p.pager = newPager(ctx, r.Offset, max, queryPage(&p.ids, func(ctx context.Context, offset int, _ string) (*QueryResponse, error) {
+		r.Offset = offset
+		return h.QueryBehaviorsContext(ctx, &r)
+	}))
+	return p
+}
+
+// GetBehaviors returns the behaviors with the given IDs
+func (h *Host) GetBehaviors(ids []string) (resp *BehaviorsResponse, err error) {
+	return h.GetBehaviorsContext(context.Background(), ids)
+}
+
+// GetBehaviorsContext is like GetBehaviors with a context for cancellation and deadlines
+func (h *Host) GetBehaviorsContext(ctx context.Context, ids []string) (resp *BehaviorsResponse, err error) {
+	if len(ids) == 0 {
+		return nil, ErrMissingParams
+	}
+	resp = &BehaviorsResponse{items: ids}
+	b, err := idsBody(ids)
+	if err != nil {
+		return
+	}
+	err = h.do(ctx, "POST", "incidents/entities/behaviors/GET/v1", nil, b, resp, h.authFunc())
+	if err == nil {
+		err = h.partial(resp.Err())
+	}
+	return
+}
+
+// UpdateIncidentsRequest updates incidents. Only the fields that are set are updated.
+type UpdateIncidentsRequest struct {
+	IDs            []string
+	Status         int      // One of the IncidentStatus constants
+	AssignedToUUID string   // The user to assign the incidents to
+	Unassign       bool     // Remove the assignment
+	AddTags        []string // Tags to add
+	RemoveTags     []string // Tags to remove
+	Comment        string   // Comment to add
+	Name           string   // New name
+	Description    string   // New description
+	UpdateDetects  bool     // Also update the status of the detections of the incidents
+}
+
+// parameters returns the action parameters of the request
+func (req *UpdateIncidentsRequest) parameters() []actionParameter {
+	var params []actionParameter
+	if req.Status != 0 {
+		params = append(params, actionParameter{Name: "update_status", Value: strconv.Itoa(req.Status)})
+	}
+	if req.AssignedToUUID != "" {
+		params = append(params, actionParameter{Name: "update_assigned_to_v2", Value: req.AssignedToUUID})
+	}
+	if req.Unassign {
+		params = append(params, actionParameter{Name: "unassign", Value: "true"})
+	}
+	for _, tag := range req.AddTags {
+		params = append(params, actionParameter{Name: "add_tag", Value: tag})
+	}
+	for _, tag := range req.RemoveTags {
+		params = append(params, actionParameter{Name: "delete_tag", Value: tag})
+	}
+	if req.Comment != "" {
+		params = append(params, actionParameter{Name: "add_comment", Value: req.Comment})
+	}
+	if req.Name != "" {
+		params = append(params, actionParameter{Name: "update_name", Value: req.Name})
+	}
+	if req.Description != "" {
+		params = append(params, actionParameter{Name: "update_description", Value: req.Description})
+	}
+	return params
+}
+
+func (req *UpdateIncidentsRequest) validate() error {
+	if len(req.IDs) == 0 || len(req.parameters()) == 0 {
+		return ErrMissingParams
+	}
+	if req.Unassign && req.AssignedToUUID != "" {
+		return &Error{Code: "bad_assignment", Message: "Cannot both assign and unassign incidents"}
+	}
+	switch req.Status {
+	case 0, IncidentStatusNew, IncidentStatusReopened, IncidentStatusInProgress, IncidentStatusClosed:
+		return nil
+	}
+	return &Error{Code: "bad_status", Message: fmt.Sprintf("Invalid incident status [%d]", req.Status)}
+}
+
+// UpdateIncidents updates the status, assignment, tags, comments, name and description of incidents
+func (h *Host) UpdateIncidents(req *UpdateIncidentsRequest) (resp *ResolveResponse, err error) {
+	return h.UpdateIncidentsContext(context.Background(), req)
+}
+
+// UpdateIncidentsContext is like UpdateIncidents with a context for cancellation and deadlines
+func (h *Host) UpdateIncidentsContext(ctx context.Context, req *UpdateIncidentsRequest) (resp *ResolveResponse, err error) {
+	if err = req.validate(); err != nil {
+		h.errorf("%v\n", err)
+		return nil, err
+	}
+	resp = &ResolveResponse{items: req.IDs}
+	b, err := actionBody(req.IDs, req.parameters()...)
+	if err != nil {
+		return
+	}
+	var params url.Values
+	if req.UpdateDetects && req.Status != 0 {
+		params = url.Values{"update_detects": {"true"}}
+	}
+	err = h.do(ctx, "POST", "incidents/entities/incident-actions/v1", params, b, resp, h.authFunc())
+	if err == nil {
+		err = h.partial(resp.Err())
+	}
+	return
+}
@@ -0,0 +1,138 @@
+package gocs
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestGetIncidents(t *testing.T) {
+	h := newTestHost(t, func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			IDs []string `json:"ids"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if r.Method != "POST" || r.URL.Path != "/incidents/entities/incidents/GET/v1" || len(body.IDs) != 2 {
+			t.Errorf("unexpected request %s %s %v", r.Method, r.URL, body.IDs)
+		}
+		w.Write([]byte(`{"resources":[{"incident_id":"inc:1","status":30,"fine_score":42,
+			"hosts":[{"device_id":"dev1","hostname":"host1"}],"start":"2020-03-01T10:00:00Z","tags":["apt"]}],
+			"errors":[{"code":404,"message":"incident inc:2 not found"}]}`))
+	})
+	resp, err := h.GetIncidents([]string{"inc:1", "inc:2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := resp.Resources[0]
+	if i.IncidentID != "inc:1" || i.Status != IncidentStatusInProgress || i.Hosts[0].Hostname != "host1" || i.Start.Year() != 2020 {
+		t.Fatalf("unexpected incident %+v", i)
+	}
+	pe, ok := resp.Err().(*PartialError)
+	if !ok || pe.Errors[0].Item != "inc:2" {
+		t.Fatalf("expected the error of inc:2, got %v", resp.Err())
+	}
+}
+
+func TestGetBehaviors(t *testing.T) {
+	h := newTestHost(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/incidents/entities/behaviors/GET/v1" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		w.Write([]byte(`{"resources":[{"behavior_id":"b1","incident_ids":["inc:1"],"cmdline":"cmd.exe","technique_id":"T1059"}]}`))
+	})
+	resp, err := h.GetBehaviors([]string{"b1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := resp.Resources[0]; b.BehaviorID != "b1" || b.CommandLine != "cmd.exe" || b.IncidentIDs[0] != "inc:1" {
+		t.Fatalf("unexpected behavior %+v", b)
+	}
+	if _, err = h.GetBehaviors(nil); err != ErrMissingParams {
+		t.Fatalf("expected ErrMissingParams, got %v", err)
+	}
+}
+
+func TestUpdateIncidents(t *testing.T) {
+	var body struct {
+		ActionParameters []actionParameter `json:"action_parameters"`
+		IDs              []string          `json:"ids"`
+	}
+	var updateDetects string
+	h := newTestHost(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/incidents/entities/incident-actions/v1" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+		updateDetects = r.URL.Query().Get("update_detects")
+		json.NewDecoder(r.Body).Decode(&body)
+		writeJSON(w, http.StatusOK, map[string]interface{}{})
+	})
+	_, err := h.UpdateIncidents(&UpdateIncidentsRequest{IDs: []string{"inc:1"}, Status: IncidentStatusClosed,
+		AddTags: []string{"a", "b"}, Comment: "done", UpdateDetects: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []actionParameter{
+		{Name: "update_status", Value: "40"},
+		{Name: "add_tag", Value: "a"},
+		{Name: "add_tag", Value: "b"},
+		{Name: "add_comment", Value: "done"},
+	}
+	if updateDetects != "true" || len(body.ActionParameters) != len(expected) || body.IDs[0] != "inc:1" {
+		t.Fatalf("unexpected update %s %+v", updateDetects, body)
+	}
+	for i := range expected {
+		if body.ActionParameters[i] != expected[i] {
+			t.Fatalf("unexpected parameter %+v", body.ActionParameters[i])
+		}
+	}
+	// The detections are only updated with the status
+	if _, err = h.UpdateIncidents(&UpdateIncidentsRequest{IDs: []string{"inc:1"}, Name: "renamed", UpdateDetects: true}); err != nil {
+		t.Fatal(err)
+	}
+	if updateDetects != "" || body.ActionParameters[0] != (actionParameter{Name: "update_name", Value: "renamed"}) {
+		t.Fatalf("unexpected update %s %+v", updateDetects, body)
+	}
+}
+
+func TestUpdateIncidentsValidation(t *testing.T) {
+	h := newTestHost(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request")
+	})
+	for _, req := range []*UpdateIncidentsRequest{
+		{IDs: []string{"inc:1"}},
+		{Status: IncidentStatusClosed},
+	} {
+		if _, err := h.UpdateIncidents(req); err != ErrMissingParams {
+			t.Fatalf("expected ErrMissingParams for %+v, got %v", req, err)
+		}
+	}
+	if _, err := h.UpdateIncidents(&UpdateIncidentsRequest{IDs: []string{"inc:1"}, Status: 35}); err == nil {
+		t.Fatal("expected an error for an invalid status")
+	}
+	if _, err := h.UpdateIncidents(&UpdateIncidentsRequest{IDs: []string{"inc:1"}, AssignedToUUID: "u1", Unassign: true}); err == nil {
+		t.Fatal("expected an error for both assigning and unassigning")
+	}
+}
+
+func TestQueryIncidentsAll(t *testing.T) {
+	var calls int32
+	h := newTestHost(t, idsHandler(t, "/incidents/queries/incidents/v1", 3, &calls))
+	ids := collectIDs(t, h.QueryIncidentsAll(&QueryRequest{Filter: "status:20", Paging: Paging{Limit: 2}}, 0))
+	if len(ids) != 3 || calls != 2 {
+		t.Fatalf("unexpected IDs %v in %d calls", ids, calls)
+	}
+}
+
+func TestIncidentTimestamps(t *testing.T) {
+	var i Incident
+	if err := json.Unmarshal([]byte(`{"incident_id":"inc:1","created":1583056800,"start":"","end":null,"modified_timestamp":"2020-03-01T10:00:00Z"}`), &i); err != nil {
+		t.Fatal(err)
+	}
+	if i.IncidentID != "inc:1" || i.Created.Unix() != 1583056800 || !i.Start.IsZero() || !i.End.IsZero() || i.ModifiedTimestamp.Year() != 2020 {
+		t.Fatalf("unexpected incident %+v", i)
+	}
+	var b IncidentBehavior
+	if err := json.Unmarshal([]byte(`{"behavior_id":"b1","timestamp":"1583056800"}`), &b); err != nil || b.Timestamp.Unix() != 1583056800 {
+		t.Fatalf("unexpected behavior %+v - %v", b, err)
+	}
+}
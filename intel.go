@@ -6,6 +6,8 @@ Written by Slavik Markovich at Demisto
 package gocs
 
 import (
+	"context"
+	"encoding/json"
 	"io"
 	"net/http"
 	"net/url"
@@ -106,11 +108,24 @@ type Resource struct {
 	Slug               string     `json:"slug"`
 }
 
-func (r *Resource) convertDates() {
-	r.CreatedDate = time.Unix(int64(r.CreatedEpoch), 0)
-	r.LastActivityDate = time.Unix(int64(r.LastActivityEpoch), 0)
-	r.FirstActivityDate = time.Unix(int64(r.FirstActivityEpoch), 0)
-	r.LastActivityDate = time.Unix(int64(r.LastActivityEpoch), 0)
+// UnmarshalJSON decodes the dates from epoch seconds or RFC3339 strings
+func (r *Resource) UnmarshalJSON(b []byte) error {
+	type resource Resource
+	aux := &struct {
+		*resource
+		Created       timestamp `json:"created_date"`
+		LastModified  timestamp `json:"last_modified_date"`
+		FirstActivity timestamp `json:"first_activity_date"`
+		LastActivity  timestamp `json:"last_activity_date"`
+	}{resource: (*resource)(r)}
+	if err := json.Unmarshal(b, aux); err != nil {
+		return err
+	}
+	r.CreatedDate, r.CreatedEpoch = aux.Created.Time, aux.Created.epoch()
+	r.LastModifiedDate, r.LastModifiedEpoch = aux.LastModified.Time, aux.LastModified.epoch()
+	r.FirstActivityDate, r.FirstActivityEpoch = aux.FirstActivity.Time, aux.FirstActivity.epoch()
+	r.LastActivityDate, r.LastActivityEpoch = aux.LastActivity.Time, aux.LastActivity.epoch()
+	return nil
 }
 
 // ActorResponse for the ActorRequest
@@ -146,9 +161,20 @@ type Relation struct {
 	LastValidDate      time.Time
 }
 
-func (r *Relation) convertDates() {
-	r.CreatedDate = time.Unix(int64(r.CreatedDateEpoch), 0)
-	r.LastValidDate = time.Unix(int64(r.LastValidDateEpoch), 0)
+// UnmarshalJSON decodes the dates from epoch seconds or RFC3339 strings
+func (r *Relation) UnmarshalJSON(b []byte) error {
+	type relation Relation
+	aux := &struct {
+		*relation
+		Created   timestamp `json:"created_date"`
+		LastValid timestamp `json:"last_valid_date"`
+	}{relation: (*relation)(r)}
+	if err := json.Unmarshal(b, aux); err != nil {
+		return err
+	}
+	r.CreatedDate, r.CreatedDateEpoch = aux.Created.Time, aux.Created.epoch()
+	r.LastValidDate, r.LastValidDateEpoch = aux.LastValid.Time, aux.LastValid.epoch()
+	return nil
 }
 
 // Label for an indicator
@@ -160,9 +186,20 @@ type Label struct {
 	LastValidOn      time.Time
 }
 
-func (l *Label) convertDates() {
-	l.CreatedOn = time.Unix(int64(l.CreatedOnEpoch), 0)
-	l.LastValidOn = time.Unix(int64(l.LastValidOnEpoch), 0)
+// UnmarshalJSON decodes the dates from epoch seconds or RFC3339 strings
+func (l *Label) UnmarshalJSON(b []byte) error {
+	type label Label
+	aux := &struct {
+		*label
+		CreatedOn   timestamp `json:"created_on"`
+		LastValidOn timestamp `json:"last_valid_on"`
+	}{label: (*label)(l)}
+	if err := json.Unmarshal(b, aux); err != nil {
+		return err
+	}
+	l.CreatedOn, l.CreatedOnEpoch = aux.CreatedOn.Time, aux.CreatedOn.epoch()
+	l.LastValidOn, l.LastValidOnEpoch = aux.LastValidOn.Time, aux.LastValidOn.epoch()
+	return nil
 }
 
 // IndicatorResponse for the request
@@ -184,15 +221,20 @@ type IndicatorResponse struct {
 	Labels              []Label    `json:"labels"`
 }
 
-func (ir *IndicatorResponse) convertDates() {
-	ir.LastUpdated = time.Unix(int64(ir.LastUpdatedEpoch), 0)
-	ir.PublishedDate = time.Unix(int64(ir.PublishedDateEpoch), 0)
-	for i := range ir.Relations {
-		ir.Relations[i].convertDates()
-	}
-	for i := range ir.Labels {
-		ir.Labels[i].convertDates()
+// UnmarshalJSON decodes the dates from epoch seconds or RFC3339 strings
+func (ir *IndicatorResponse) UnmarshalJSON(b []byte) error {
+	type indicatorResponse IndicatorResponse
+	aux := &struct {
+		*indicatorResponse
+		LastUpdated   timestamp `json:"last_updated"`
+		PublishedDate timestamp `json:"published_date"`
+	}{indicatorResponse: (*indicatorResponse)(ir)}
+	if err := json.Unmarshal(b, aux); err != nil {
+		return err
 	}
+	ir.LastUpdated, ir.LastUpdatedEpoch = aux.LastUpdated.Time, aux.LastUpdated.epoch()
+	ir.PublishedDate, ir.PublishedDateEpoch = aux.PublishedDate.Time, aux.PublishedDate.epoch()
+	return nil
 }
 
 func actorRequestToParams(req *ActorRequest) url.Values {
@@ -222,10 +264,14 @@ func actorRequestToParams(req *ActorRequest) url.Values {
 	return params
 }
 
-func (c *Intel) authFunc() func(*http.Request) {
-	return func(req *http.Request) {
+func (c *Intel) authFunc() func(*http.Request) error {
+	return func(req *http.Request) error {
+		if c.oauth != nil {
+			return c.bearerAuth(req)
+		}
 		req.Header.Set(AuthHeaderID, c.id)
 		req.Header.Set(AuthHeaderKey, c.key)
+		return nil
 	}
 }
 
@@ -233,21 +279,26 @@ func (c *Intel) authFunc() func(*http.Request) {
 
 // Actors will query the actors API
 func (c *Intel) Actors(req *ActorRequest) (resp *ActorResponse, err error) {
+	return c.ActorsContext(context.Background(), req)
+}
+
+// ActorsContext is like Actors with a context for cancellation and deadlines
+func (c *Intel) ActorsContext(ctx context.Context, req *ActorRequest) (resp *ActorResponse, err error) {
 	resp = &ActorResponse{}
 	params := actorRequestToParams(req)
-	err = c.do("GET", "actor/v1/queries/actors", params, nil, resp, c.authFunc())
-	if err == nil {
-		for i := range resp.Resources {
-			resp.Resources[i].convertDates()
-		}
-	}
+	err = c.do(ctx, "GET", "actor/v1/queries/actors", params, nil, resp, c.authFunc())
 	return
 }
 
 // ActorsJSON will write the response to the given writer
 func (c *Intel) ActorsJSON(req *ActorRequest, w io.Writer) (err error) {
+	return c.ActorsJSONContext(context.Background(), req, w)
+}
+
+// ActorsJSONContext is like ActorsJSON with a context for cancellation and deadlines
+func (c *Intel) ActorsJSONContext(ctx context.Context, req *ActorRequest, w io.Writer) (err error) {
 	params := actorRequestToParams(req)
-	err = c.do("GET", "actor/v1/queries/actors", params, nil, w, c.authFunc())
+	err = c.do(ctx, "GET", "actor/v1/queries/actors", params, nil, w, c.authFunc())
 	return
 }
 
@@ -274,26 +325,86 @@ func indicatorRequestToParams(req *IndicatorRequest) url.Values {
 
 // Indicators will query the indicators API
 func (c *Intel) Indicators(req *IndicatorRequest) (resp []IndicatorResponse, err error) {
+	return c.IndicatorsContext(context.Background(), req)
+}
+
+// IndicatorsContext is like Indicators with a context for cancellation and deadlines
+func (c *Intel) IndicatorsContext(ctx context.Context, req *IndicatorRequest) (resp []IndicatorResponse, err error) {
 	if req.Parameter == "" || req.Filter == "" || req.Value == "" {
 		return nil, ErrMissingParams
 	}
 	resp = []IndicatorResponse{}
 	params := indicatorRequestToParams(req)
-	err = c.do("GET", "indicator/v1/search/"+req.Parameter, params, nil, &resp, c.authFunc())
-	if err == nil {
-		for i := range resp {
-			resp[i].convertDates()
-		}
-	}
+	err = c.do(ctx, "GET", "indicator/v1/search/"+req.Parameter, params, nil, &resp, c.authFunc())
 	return
 }
 
 // IndicatorsJSON will write the response to the given writer
 func (c *Intel) IndicatorsJSON(req *IndicatorRequest, w io.Writer) (err error) {
+	return c.IndicatorsJSONContext(context.Background(), req, w)
+}
+
+// IndicatorsJSONContext is like IndicatorsJSON with a context for cancellation and deadlines
+func (c *Intel) IndicatorsJSONContext(ctx context.Context, req *IndicatorRequest, w io.Writer) (err error) {
 	if req.Parameter == "" || req.Filter == "" || req.Value == "" {
 		return ErrMissingParams
 	}
 	params := indicatorRequestToParams(req)
-	err = c.do("GET", "indicator/v1/search/"+req.Parameter, params, nil, w, c.authFunc())
+	err = c.do(ctx, "GET", "indicator/v1/search/"+req.Parameter, params, nil, w, c.authFunc())
 	return
 }
+
+// ActorsAll returns a pager over all the actors matching the request starting at its offset.
+// The request limit is used as the page size. If max is positive, at most max actors are returned.
+func (c *Intel) ActorsAll(req *ActorRequest, max int) *ActorPager {
+	return c.ActorsAllContext(context.Background(), req, max)
+}
+
+// ActorsAllContext is like ActorsAll with a context for cancellation and deadlines
+func (c *Intel) ActorsAllContext(ctx context.Context, req *ActorRequest, max int) *ActorPager {
+	r := *req
+	p := &ActorPager{}
+	p.pager = newPager(ctx, r.Offset, max, func(ctx context.Context, offset int, _ string) (int, int, string, error) {
+		r.Offset = offset
+		resp, err := c.ActorsContext(ctx, &r)
+		if err != nil {
+			return 0, 0, "", err
+		}
+		p.actors = resp.Resources
+		return len(resp.Resources), resp.Meta.Paging.Total, "", nil
+	})
+	return p
+}
+
+// IndicatorsAll returns a pager over all the indicators matching the request starting at its page.
+// The request PerPage is used as the page size. If max is positive, at most max indicators are returned.
+func (c *Intel) IndicatorsAll(req *IndicatorRequest, max int) *IndicatorPager {
+	return c.IndicatorsAllContext(context.Background(), req, max)
+}
+
+// IndicatorsAllContext is like IndicatorsAll with a context for cancellation and deadlines
+func (c *Intel) IndicatorsAllContext(ctx context.Context, req *IndicatorRequest, max int) *IndicatorPager {
+	r := *req
+	if r.Page == 0 {
+		r.Page = 1
+	}
+	if r.PerPage == 0 {
+		r.PerPage = 10
+	}
+	p := &IndicatorPager{}
+	p.pager = newPager(ctx, (r.Page-1)*r.PerPage, max, func(ctx context.Context, offset int, _ string) (int, int, string, error) {
+		r.Page = offset/r.PerPage + 1
+		resp, err := c.IndicatorsContext(ctx, &r)
+		if err != nil {
+			return 0, 0, "", err
+		}
+		p.indicators = resp
+		// The API does not report the total so a short page is the last one
+		total := -1
+		if len(resp) < r.PerPage {
+			total = offset + len(resp)
+		}
+		return len(resp), total, "", nil
+	})
+	return p
+}
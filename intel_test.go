@@ -0,0 +1,44 @@
+package gocs
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestResourceDates(t *testing.T) {
+	var r Resource
+	err := json.Unmarshal([]byte(`{"id":1,"created_date":1583056800,"last_modified_date":"2020-03-02T10:00:00Z",
+		"first_activity_date":0,"last_activity_date":"1583056800.5"}`), &r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.CreatedDate.Unix() != 1583056800 || r.CreatedEpoch != 1583056800 {
+		t.Fatalf("unexpected created date %v %v", r.CreatedDate, r.CreatedEpoch)
+	}
+	// The modification date is filled and its epoch is set from the RFC3339 string
+	if r.LastModifiedDate.Day() != 2 || r.LastModifiedEpoch != float64(r.LastModifiedDate.Unix()) {
+		t.Fatalf("unexpected modification date %v %v", r.LastModifiedDate, r.LastModifiedEpoch)
+	}
+	if !r.FirstActivityDate.IsZero() || !r.LastActivityDate.Equal(time.Unix(1583056800, 5e8)) {
+		t.Fatalf("unexpected activity dates %v %v", r.FirstActivityDate, r.LastActivityDate)
+	}
+}
+
+func TestIndicatorDates(t *testing.T) {
+	var ir IndicatorResponse
+	err := json.Unmarshal([]byte(`{"indicator":"evil.com","last_updated":1583056800,"published_date":null,
+		"labels":[{"name":"a","created_on":"1583056800"}],"relations":[{"created_date":"","last_valid_date":1583056800}]}`), &ir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ir.LastUpdated.Unix() != 1583056800 || !ir.PublishedDate.IsZero() || ir.PublishedDateEpoch != 0 {
+		t.Fatalf("unexpected indicator dates %v %v", ir.LastUpdated, ir.PublishedDate)
+	}
+	if ir.Labels[0].CreatedOn.Unix() != 1583056800 || !ir.Relations[0].CreatedDate.IsZero() || ir.Relations[0].LastValidDate.Unix() != 1583056800 {
+		t.Fatalf("unexpected label or relation %+v %+v", ir.Labels[0], ir.Relations[0])
+	}
+	if err = json.Unmarshal([]byte(`{"last_updated":"yesterday"}`), &ir); err == nil {
+		t.Fatal("expected an error for an invalid timestamp")
+	}
+}
@@ -0,0 +1,171 @@
+package gocs
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// tokenRefreshMargin is how long before the expiry we fetch a new token
+	tokenRefreshMargin = time.Minute
+	// closeTimeout bounds the time Close waits for the token revocation
+	closeTimeout = 10 * time.Second
+)
+
+// oauth2Token caches the OAuth2 access token. It is safe for concurrent use.
+type oauth2Token struct {
+	mu      sync.Mutex
+	token   string
+	refresh time.Time // When we should fetch a new token
+}
+
+// tokenResponse is returned from the token endpoint
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// SetOAuth2Credentials switches the client to OAuth2 client-credentials authentication.
+// An access token is fetched from the oauth2/token endpoint under the configured URL, cached and
+// refreshed before it expires. Call Close when done to revoke the token.
+//
+// The OAuth2 APIs are served from https://api.crowdstrike.com/ so use SetURL or SetCloud accordingly.
+func SetOAuth2Credentials(clientID, secret string) OptionFunc {
+	return func(c *client) error {
+		if clientID == "" || secret == "" {
+			c.errorf("%v\n", ErrMissingCredentials)
+			return ErrMissingCredentials
+		}
+		c.id, c.key = clientID, secret
+		c.oauth = &oauth2Token{}
+		return nil
+	}
+}
+
+// noAuth is used for requests that carry their own credentials
+func noAuth(*http.Request) error {
+	return nil
+}
+
+// formHeader is the header for url-encoded form bodies
+func formHeader() http.Header {
+	return http.Header{"Content-Type": {"application/x-www-form-urlencoded"}}
+}
+
+// bearerToken returns the bearer token the request was sent with
+func bearerToken(req *http.Request) string {
+	auth := req.Header.Get("Authorization")
+	if strings.HasPrefix(auth, "Bearer ") {
+		return auth[len("Bearer "):]
+	}
+	return ""
+}
+
+// bearerAuth sets the OAuth2 access token on the request, fetching a new one if needed
+func (c *client) bearerAuth(req *http.Request) error {
+	token, err := c.accessToken(req.Context())
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// accessToken returns a valid access token, fetching a new one if the cached one is about to expire.
+// Concurrent callers wait for a single fetch.
+func (c *client) accessToken(ctx context.Context) (string, error) {
+	t := c.oauth
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.token != "" && time.Now().Before(t.refresh) {
+		return t.token, nil
+	}
+	form := url.Values{"client_id": {c.id}, "client_secret": {c.key}}
+	httpResp, err := c.send(ctx, "POST", c.currentURL()+"oauth2/token", nil, strings.NewReader(form.Encode()), formHeader(), noAuth)
+	if err != nil {
+		return "", err
+	}
+	defer httpResp.Body.Close()
+	c.dumpResponse(httpResp)
+	if c.discover {
+		c.discoverCloud(httpResp)
+	}
+	resp := &tokenResponse{}
+	if err = json.NewDecoder(httpResp.Body).Decode(resp); err != nil {
+		return "", err
+	}
+	if resp.AccessToken == "" {
+		err := &Error{Code: "oauth2_error", Message: "The token endpoint did not return an access token"}
+		c.errorf("%v\n", err)
+		return "", err
+	}
+	lifetime := time.Duration(resp.ExpiresIn) * time.Second
+	margin := tokenRefreshMargin
+	if margin > lifetime/2 {
+		margin = lifetime / 2
+	}
+	t.token, t.refresh = resp.AccessToken, time.Now().Add(lifetime-margin)
+	c.tracef("Fetched OAuth2 token valid for %v\n", lifetime)
+	return t.token, nil
+}
+
+// invalidate drops the cached token if it is still the given one
+func (t *oauth2Token) invalidate(token string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.token == token {
+		t.token = ""
+	}
+}
+
+// currentURL returns the base URL without triggering cloud discovery
+func (c *client) currentURL() string {
+	c.urlMu.RLock()
+	defer c.urlMu.RUnlock()
+	return c.url
+}
+
+// doForm posts a url-encoded form
+func (c *client) doForm(ctx context.Context, rawurl string, form url.Values, result interface{}, authFunc func(*http.Request) error) error {
+	resp, err := c.send(ctx, "POST", rawurl, nil, strings.NewReader(form.Encode()), formHeader(), authFunc)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	c.dumpResponse(resp)
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// Close releases the resources held by the client. When using OAuth2, the access token is revoked.
+// The client can still be used after Close, in which case a new token is fetched.
+func (c *client) Close() error {
+	if c.oauth == nil {
+		return nil
+	}
+	c.oauth.mu.Lock()
+	token := c.oauth.token
+	c.oauth.token = ""
+	c.oauth.mu.Unlock()
+	if token == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+	err := c.doForm(ctx, c.currentURL()+"oauth2/revoke", url.Values{"token": {token}}, nil, func(req *http.Request) error {
+		req.SetBasicAuth(c.id, c.key)
+		return nil
+	})
+	if err != nil {
+		c.errorf("Failed to revoke the OAuth2 token - %v\n", err)
+	}
+	return err
+}
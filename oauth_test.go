@@ -0,0 +1,195 @@
+package gocs
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// tokenServer is a stand-in for the OAuth2 token endpoint and an API behind it
+type tokenServer struct {
+	fetches   int32         // Number of tokens handed out
+	expiresIn int           // Lifetime of the tokens in seconds
+	delay     time.Duration // Delay of the token endpoint
+	revoked   chan string   // Revoked tokens
+	api       http.HandlerFunc
+}
+
+func (s *tokenServer) handler(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/oauth2/token":
+			if r.Method != "POST" || r.FormValue("client_id") != "client" || r.FormValue("client_secret") != "secret" {
+				t.Errorf("unexpected token request %s %v", r.Method, r.Form)
+			}
+			time.Sleep(s.delay)
+			n := atomic.AddInt32(&s.fetches, 1)
+			writeJSON(w, http.StatusCreated, tokenResponse{AccessToken: fmt.Sprintf("token-%d", n), TokenType: "bearer", ExpiresIn: s.expiresIn})
+		case "/oauth2/revoke":
+			if id, secret, ok := r.BasicAuth(); !ok || id != "client" || secret != "secret" {
+				t.Errorf("revoke without client credentials")
+			}
+			s.revoked <- r.FormValue("token")
+			writeJSON(w, http.StatusOK, map[string]interface{}{})
+		default:
+			s.api(w, r)
+		}
+	}
+}
+
+// newTokenServer returns a token server with an API that accepts any token
+func newTokenServer() *tokenServer {
+	return &tokenServer{
+		expiresIn: 1799,
+		revoked:   make(chan string, 1),
+		api: func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, http.StatusOK, map[string]interface{}{"resources": []string{r.Header.Get("Authorization")}})
+		},
+	}
+}
+
+func newOAuthHost(t *testing.T, s *tokenServer) *Host {
+	return newTestHost(t, s.handler(t), SetOAuth2Credentials("client", "secret"))
+}
+
+func TestOAuth2TokenCached(t *testing.T) {
+	s := newTokenServer()
+	h := newOAuthHost(t, s)
+	for i := 0; i < 3; i++ {
+		resp, err := h.SearchIOCs(&SearchIOCsRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Resources[0] != "Bearer token-1" {
+			t.Fatalf("unexpected authorization %q", resp.Resources[0])
+		}
+	}
+	if s.fetches != 1 {
+		t.Fatalf("expected a single token fetch, got %d", s.fetches)
+	}
+}
+
+func TestOAuth2RefreshBeforeExpiry(t *testing.T) {
+	s := newTokenServer()
+	// Tokens are refreshed after half of their lifetime when it is shorter than the margin
+	s.expiresIn = 1
+	h := newOAuthHost(t, s)
+	if _, err := h.SearchIOCs(&SearchIOCsRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(600 * time.Millisecond)
+	resp, err := h.SearchIOCs(&SearchIOCsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.fetches != 2 || resp.Resources[0] != "Bearer token-2" {
+		t.Fatalf("expected a refreshed token, got %d fetches and %q", s.fetches, resp.Resources[0])
+	}
+}
+
+func TestOAuth2RetryOnUnauthorized(t *testing.T) {
+	s := newTokenServer()
+	var calls int32
+	s.api = func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		// The first token was revoked behind our back
+		if r.Header.Get("Authorization") == "Bearer token-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{})
+	}
+	h := newOAuthHost(t, s)
+	if _, err := h.SearchIOCs(&SearchIOCsRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 || s.fetches != 2 {
+		t.Fatalf("expected a single retry with a new token, got %d calls and %d fetches", calls, s.fetches)
+	}
+}
+
+func TestOAuth2RetryOnUnauthorizedOnce(t *testing.T) {
+	s := newTokenServer()
+	var calls int32
+	s.api = func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusUnauthorized)
+	}
+	h := newOAuthHost(t, s)
+	_, err := h.SearchIOCs(&SearchIOCsRequest{})
+	if apiErr, ok := err.(*APIError); !ok || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected a 401 APIError, got %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("expected the request to be retried once, got %d calls", calls)
+	}
+}
+
+func TestOAuth2ConcurrentFetch(t *testing.T) {
+	s := newTokenServer()
+	s.delay = 50 * time.Millisecond
+	h := newOAuthHost(t, s)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := h.SearchIOCs(&SearchIOCsRequest{}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if s.fetches != 1 {
+		t.Fatalf("expected the callers to share a single token fetch, got %d", s.fetches)
+	}
+}
+
+func TestOAuth2Close(t *testing.T) {
+	s := newTokenServer()
+	h := newOAuthHost(t, s)
+	// Nothing to revoke before the first request
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.SearchIOCs(&SearchIOCsRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case token := <-s.revoked:
+		if token != "token-1" {
+			t.Fatalf("unexpected revoked token %q", token)
+		}
+	default:
+		t.Fatal("the token was not revoked")
+	}
+	// A new token is fetched after Close
+	resp, err := h.SearchIOCs(&SearchIOCsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Resources[0] != "Bearer token-2" {
+		t.Fatalf("unexpected authorization %q", resp.Resources[0])
+	}
+}
+
+func TestOAuth2TokenError(t *testing.T) {
+	h := newTestHost(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/oauth2/") {
+			writeJSON(w, http.StatusForbidden, map[string]interface{}{"errors": []Error{{Code: "403", Message: "access denied"}}})
+			return
+		}
+		t.Errorf("unexpected API call without a token")
+	}, SetOAuth2Credentials("client", "secret"))
+	_, err := h.SearchIOCs(&SearchIOCsRequest{})
+	if apiErr, ok := err.(*APIError); !ok || apiErr.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected a 403 APIError, got %v", err)
+	}
+}
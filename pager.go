@@ -0,0 +1,198 @@
+package gocs
+
+import (
+	"context"
+)
+
+// pageFunc fetches a single page starting at offset, or at the after token if it is not empty.
+// Returns the number of items in the page, the total reported by the API (-1 if unknown) and the
+// token for the next page if the API uses them.
+type pageFunc func(ctx context.Context, offset int, after string) (n, total int, next string, err error)
+
+// pager is the shared logic of the auto-paginating iterators.
+// Pages are fetched lazily as the items are consumed.
+type pager struct {
+	ctx    context.Context
+	fetch  pageFunc
+	max    int    // Maximum number of items to return, 0 for no limit
+	offset int    // Offset of the next page
+	after  string // Token of the next page
+	count  int    // Number of items returned so far
+	pos    int    // Position in the current page
+	size   int    // Size of the current page
+	done   bool   // No more pages
+	err    error
+}
+
+func newPager(ctx context.Context, offset, max int, fetch pageFunc) pager {
+	return pager{ctx: ctx, fetch: fetch, max: max, offset: offset, pos: -1}
+}
+
+// next advances to the next item, fetching the next page if needed
+func (p *pager) next() bool {
+	if p.err != nil || (p.max > 0 && p.count >= p.max) {
+		return false
+	}
+	p.pos++
+	for p.pos >= p.size {
+		if p.done {
+			return false
+		}
+		n, total, next, err := p.fetch(p.ctx, p.offset, p.after)
+		if err != nil {
+			p.err = err
+			return false
+		}
+		p.offset += n
+		p.pos, p.size = 0, n
+		switch {
+		case n == 0:
+			p.done = true
+		case next != "":
+			p.after = next
+		case p.after != "":
+			// The API stopped handing out tokens
+			p.done = true
+		case total >= 0 && p.offset >= total:
+			p.done = true
+		}
+	}
+	p.count++
+	return true
+}
+
+// IDPager iterates over the IDs returned by a query API.
+//
+// Example:
+//
+//   p := h.SearchIOCsAll(req, 0)
+//   for p.Next() {
+//     fmt.Println(p.ID())
+//   }
+//   if err := p.Err(); err != nil {
+//     ...
+//   }
+type IDPager struct {
+	pager
+	ids []string
+}
+
+// Next advances to the next ID. It returns false when there are no more IDs or on error.
+func (p *IDPager) Next() bool {
+	return p.next()
+}
+
+// ID returns the current ID
+func (p *IDPager) ID() string {
+	return p.ids[p.pos]
+}
+
+// Err returns the error that stopped the iteration, if any
+func (p *IDPager) Err() error {
+	return p.err
+}
+
+// ActorPager iterates over the actors returned by the actors API
+type ActorPager struct {
+	pager
+	actors []Resource
+}
+
+// Next advances to the next actor. It returns false when there are no more actors or on error.
+func (p *ActorPager) Next() bool {
+	return p.next()
+}
+
+// Actor returns the current actor
+func (p *ActorPager) Actor() *Resource {
+	return &p.actors[p.pos]
+}
+
+// Err returns the error that stopped the iteration, if any
+func (p *ActorPager) Err() error {
+	return p.err
+}
+
+// IndicatorPager iterates over the indicators returned by the indicators API
+type IndicatorPager struct {
+	pager
+	indicators []IndicatorResponse
+}
+
+// Next advances to the next indicator. It returns false when there are no more indicators or on error.
+func (p *IndicatorPager) Next() bool {
+	return p.next()
+}
+
+// Indicator returns the current indicator
+func (p *IndicatorPager) Indicator() *IndicatorResponse {
+	return &p.indicators[p.pos]
+}
+
+// Err returns the error that stopped the iteration, if any
+func (p *IndicatorPager) Err() error {
+	return p.err
+}
+
+// HostGroupPager iterates over host groups
+type HostGroupPager struct {
+	pager
+	groups []HostGroup
+}
+
+// Next advances to the next host group. It returns false when there are no more groups or on error.
+func (p *HostGroupPager) Next() bool {
+	return p.next()
+}
+
+// HostGroup returns the current host group
+func (p *HostGroupPager) HostGroup() *HostGroup {
+	return &p.groups[p.pos]
+}
+
+// Err returns the error that stopped the iteration, if any
+func (p *HostGroupPager) Err() error {
+	return p.err
+}
+
+// VulnerabilityPager iterates over Spotlight vulnerabilities
+type VulnerabilityPager struct {
+	pager
+	vulns []Vulnerability
+}
+
+// Next advances to the next vulnerability. It returns false when there are no more vulnerabilities or on error.
+func (p *VulnerabilityPager) Next() bool {
+	return p.next()
+}
+
+// Vulnerability returns the current vulnerability
+func (p *VulnerabilityPager) Vulnerability() *Vulnerability {
+	return &p.vulns[p.pos]
+}
+
+// Err returns the error that stopped the iteration, if any
+func (p *VulnerabilityPager) Err() error {
+	return p.err
+}
+
+// ReportPager iterates over Falcon Intelligence reports
+type ReportPager struct {
+	pager
+	reports []Report
+}
+
+// Next advances to the next report. It returns false when there are no more reports or on error.
+func (p *ReportPager) Next() bool {
+	return p.next()
+}
+
+// Report returns the current report
+func (p *ReportPager) Report() *Report {
+	return &p.reports[p.pos]
+}
+
+// Err returns the error that stopped the iteration, if any
+func (p *ReportPager) Err() error {
+	return p.err
+}
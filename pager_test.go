@@ -0,0 +1,176 @@
+package gocs
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+// idsHandler serves total IDs by offset and limit in the QueryResponse format
+func idsHandler(t *testing.T, path string, total int, calls *int32) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		if r.URL.Path != path {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		ids := []string{}
+		for i := offset; i < total && i < offset+limit; i++ {
+			ids = append(ids, fmt.Sprintf("id-%d", i))
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"meta":      map[string]interface{}{"pagination": map[string]interface{}{"total": total, "offset": offset, "limit": limit}},
+			"resources": ids,
+		})
+	}
+}
+
+// collectIDs drains the pager
+func collectIDs(t *testing.T, p *IDPager) []string {
+	t.Helper()
+	var ids []string
+	for p.Next() {
+		ids = append(ids, p.ID())
+	}
+	if err := p.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return ids
+}
+
+func TestIDPager(t *testing.T) {
+	var calls int32
+	h := newTestHost(t, idsHandler(t, "/indicators/queries/iocs/v1", 5, &calls))
+	ids := collectIDs(t, h.SearchIOCsAll(&SearchIOCsRequest{Paging: Paging{Limit: 2}}, 0))
+	if len(ids) != 5 || ids[0] != "id-0" || ids[4] != "id-4" {
+		t.Fatalf("unexpected IDs %v", ids)
+	}
+	if calls != 3 {
+		t.Fatalf("expected 3 pages, got %d calls", calls)
+	}
+}
+
+func TestIDPagerMaxAndOffset(t *testing.T) {
+	var calls int32
+	h := newTestHost(t, idsHandler(t, "/indicators/queries/iocs/v1", 10, &calls))
+	ids := collectIDs(t, h.SearchIOCsAll(&SearchIOCsRequest{Paging: Paging{Limit: 2, Offset: 3}}, 3))
+	if len(ids) != 3 || ids[0] != "id-3" || ids[2] != "id-5" {
+		t.Fatalf("unexpected IDs %v", ids)
+	}
+	if calls != 2 {
+		t.Fatalf("expected 2 pages, got %d calls", calls)
+	}
+}
+
+func TestPagerError(t *testing.T) {
+	var calls int32
+	h := newTestHost(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) > 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		idsHandler(t, r.URL.Path, 10, new(int32))(w, r)
+	})
+	p := h.SearchIOCsAll(&SearchIOCsRequest{Paging: Paging{Limit: 2}}, 0)
+	n := 0
+	for p.Next() {
+		n++
+	}
+	if n != 2 || p.Err() == nil {
+		t.Fatalf("expected the error to stop the iteration after the first page, got %d IDs and %v", n, p.Err())
+	}
+	if p.Next() {
+		t.Fatal("expected the pager to stay stopped")
+	}
+}
+
+func TestDeviceSearchAllScroll(t *testing.T) {
+	const total = 5
+	var calls int32
+	h := newTestHost(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if r.URL.Path != "/devices/queries/devices-scroll/v1" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if r.URL.Query().Get("filter") != "platform_name:'Windows'" {
+			t.Errorf("unexpected filter %q", r.URL.Query().Get("filter"))
+		}
+		// The token is the position of the next page
+		start := 0
+		if token := r.URL.Query().Get("offset"); token != "" {
+			start, _ = strconv.Atoi(token[len("token-"):])
+		}
+		ids := []string{}
+		for i := start; i < total && i < start+2; i++ {
+			ids = append(ids, fmt.Sprintf("id-%d", i))
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"meta": map[string]interface{}{"pagination": map[string]interface{}{
+				"total": total, "offset": fmt.Sprintf("token-%d", start+len(ids)), "expires_at": 1600000000,
+			}},
+			"resources": ids,
+		})
+	})
+	ids := collectIDs(t, h.DeviceSearchAll(&DeviceSearchRequest{Filter: "platform_name:'Windows'", Paging: Paging{Limit: 2}}, 0))
+	if len(ids) != total || ids[4] != "id-4" {
+		t.Fatalf("unexpected IDs %v", ids)
+	}
+	if calls != 3 {
+		t.Fatalf("expected no request after the last page, got %d calls", calls)
+	}
+}
+
+func TestDeviceSearchAllQueryByOffset(t *testing.T) {
+	var calls int32
+	h := newTestHost(t, idsHandler(t, "/devices/queries/devices/v1", 3, &calls))
+	ids := collectIDs(t, h.DeviceSearchAll(&DeviceSearchRequest{Query: "host", Paging: Paging{Limit: 2}}, 0))
+	if len(ids) != 3 {
+		t.Fatalf("unexpected IDs %v", ids)
+	}
+}
+
+func TestActorPager(t *testing.T) {
+	c := newTestIntel(t, func(w http.ResponseWriter, r *http.Request) {
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		var actors []map[string]interface{}
+		for i := offset; i < 3 && i < offset+2; i++ {
+			actors = append(actors, map[string]interface{}{"id": i, "name": fmt.Sprintf("actor-%d", i)})
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"meta":      map[string]interface{}{"paging": map[string]interface{}{"total": 3}},
+			"resources": actors,
+		})
+	})
+	p := c.ActorsAll(&ActorRequest{Paging: Paging{Limit: 2}}, 0)
+	var names []string
+	for p.Next() {
+		names = append(names, p.Actor().Name)
+	}
+	if p.Err() != nil || len(names) != 3 || names[2] != "actor-2" {
+		t.Fatalf("unexpected actors %v - %v", names, p.Err())
+	}
+}
+
+func TestIndicatorPagerShortPage(t *testing.T) {
+	var calls int32
+	c := newTestIntel(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		indicators := []map[string]interface{}{{"indicator": "a"}, {"indicator": "b"}}
+		if page == 2 {
+			indicators = indicators[:1]
+		}
+		writeJSON(w, http.StatusOK, indicators)
+	})
+	p := c.IndicatorsAll(&IndicatorRequest{Parameter: "indicator", Filter: "match", Value: "x", PerPage: 2}, 0)
+	n := 0
+	for p.Next() {
+		n++
+	}
+	if p.Err() != nil || n != 3 || calls != 2 {
+		t.Fatalf("expected 3 indicators in 2 pages, got %d in %d - %v", n, calls, p.Err())
+	}
+}
@@ -0,0 +1,87 @@
+package gocs
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ItemError is the failure of a single item in a call that otherwise succeeded
+type ItemError struct {
+	Item string // The input item (IOC value or ID) that caused the error, empty if it cannot be determined
+	Err  Error  // The error as returned by the API
+}
+
+func (e *ItemError) Error() string {
+	if e.Item == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%s: %v", e.Item, &e.Err)
+}
+
+// Unwrap returns the underlying API error
+func (e *ItemError) Unwrap() error {
+	return &e.Err
+}
+
+// PartialError is returned when a successful response carries per-item errors
+type PartialError struct {
+	Errors []ItemError
+}
+
+func (e *PartialError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i := range e.Errors {
+		msgs[i] = e.Errors[i].Error()
+	}
+	return fmt.Sprintf("partial_failure: %d item(s) failed - %s", len(e.Errors), strings.Join(msgs, "; "))
+}
+
+// SetFailOnPartialErrors makes calls return a *PartialError when the response is successful
+// but carries per-item errors. By default, the errors are only available on the response.
+func SetFailOnPartialErrors(fail bool) OptionFunc {
+	return func(c *client) error {
+		c.strict = fail
+		return nil
+	}
+}
+
+// newPartialError maps the errors back to the input items. Returns nil if there are no errors.
+func newPartialError(items []string, errs []Error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	pe := &PartialError{Errors: make([]ItemError, len(errs))}
+	for i := range errs {
+		pe.Errors[i] = ItemError{Item: matchItem(items, &errs[i]), Err: errs[i]}
+	}
+	return pe
+}
+
+// matchItem finds the input item that the error refers to - either by ID or by being mentioned in the message
+func matchItem(items []string, e *Error) string {
+	match := ""
+	for _, item := range items {
+		if item == "" {
+			continue
+		}
+		if item == e.ID {
+			return item
+		}
+		if len(item) > len(match) && strings.Contains(e.Message, item) {
+			match = item
+		}
+	}
+	if match == "" && len(items) == 1 {
+		match = items[0]
+	}
+	return match
+}
+
+// partial returns the per-item error if the client is configured to fail on them
+func (c *client) partial(err error) error {
+	if c.strict && err != nil {
+		c.errorf("%v\n", err)
+		return err
+	}
+	return nil
+}
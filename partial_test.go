@@ -0,0 +1,68 @@
+package gocs
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+// partialHandler succeeds with a per-item error for one of the IOCs
+func partialHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"resources": []string{},
+		"errors": []map[string]interface{}{
+			{"code": 400, "message": "Duplicate type: 'domain' and value: 'evil.example.com' combination."},
+		},
+	})
+}
+
+func TestPartialErrorOnResponse(t *testing.T) {
+	h := newTestHost(t, partialHandler)
+	iocs := []IOC{{Type: "domain", Value: "example.com"}, {Type: "domain", Value: "evil.example.com"}}
+	resp, err := h.UploadIOCs(iocs)
+	if err != nil {
+		t.Fatalf("expected per-item errors to stay on the response by default, got %v", err)
+	}
+	var pe *PartialError
+	if !errors.As(resp.Err(), &pe) {
+		t.Fatalf("expected a PartialError, got %v", resp.Err())
+	}
+	// The longest mentioned item wins so evil.example.com is not attributed to example.com
+	if len(pe.Errors) != 1 || pe.Errors[0].Item != "evil.example.com" || pe.Errors[0].Err.Code != "400" {
+		t.Fatalf("unexpected errors %+v", pe.Errors)
+	}
+}
+
+func TestFailOnPartialErrors(t *testing.T) {
+	h := newTestHost(t, partialHandler, SetFailOnPartialErrors(true))
+	resp, err := h.UploadIOCs([]IOC{{Type: "domain", Value: "evil.example.com"}})
+	var pe *PartialError
+	if !errors.As(err, &pe) {
+		t.Fatalf("expected a PartialError, got %v", err)
+	}
+	if resp == nil {
+		t.Fatal("expected the response along with the error")
+	}
+	var apiErr *Error
+	if !errors.As(&pe.Errors[0], &apiErr) || apiErr.Code != "400" {
+		t.Fatalf("expected the item error to unwrap to the API error, got %v", apiErr)
+	}
+}
+
+func TestPartialErrorByID(t *testing.T) {
+	err := newPartialError([]string{"a", "b"}, []Error{{Code: "404", Message: "not found", ID: "b"}})
+	pe, ok := err.(*PartialError)
+	if !ok || pe.Errors[0].Item != "b" {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if newPartialError([]string{"a"}, nil) != nil {
+		t.Fatal("expected no error without per-item errors")
+	}
+}
+
+func TestPartialErrorSingleItem(t *testing.T) {
+	err := newPartialError([]string{"only"}, []Error{{Code: "500", Message: "internal"}})
+	if pe, ok := err.(*PartialError); !ok || pe.Errors[0].Item != "only" {
+		t.Fatalf("expected the error to be attributed to the single item, got %v", err)
+	}
+}
@@ -0,0 +1,652 @@
+package gocs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Policy kinds
+const (
+	PolicyPrevention    = "prevention"
+	PolicySensorUpdate  = "sensor-update"
+	PolicyDeviceControl = "device-control"
+)
+
+// Policy holds the fields common to all the policy kinds
+type Policy struct {
+	ID                string      `json:"id"`
+	CID               string      `json:"cid"`
+	Name              string      `json:"name"`
+	Description       string      `json:"description"`
+	PlatformName      string      `json:"platform_name"`
+	Enabled           bool        `json:"enabled"`
+	Groups            []HostGroup `json:"groups"`
+	CreatedBy         string      `json:"created_by"`
+	CreatedTimestamp  time.Time   `json:"created_timestamp"`
+	ModifiedBy        string      `json:"modified_by"`
+	ModifiedTimestamp time.Time   `json:"modified_timestamp"`
+}
+
+// UnmarshalJSON decodes the dates from epoch seconds or RFC3339 strings
+func (p *Policy) UnmarshalJSON(b []byte) error {
+	type policy Policy
+	aux := &struct {
+		*policy
+		CreatedTimestamp  timestamp `json:"created_timestamp"`
+		ModifiedTimestamp timestamp `json:"modified_timestamp"`
+	}{policy: (*policy)(p)}
+	if err := json.Unmarshal(b, aux); err != nil {
+		return err
+	}
+	p.CreatedTimestamp, p.ModifiedTimestamp = aux.CreatedTimestamp.Time, aux.ModifiedTimestamp.Time
+	return nil
+}
+
+// PreventionSetting is a single setting of a prevention policy.
+// The value depends on the type, e.g. {"enabled": true} for toggles and
+// {"detection": "MODERATE", "prevention": "CAUTIOUS"} for ML sliders.
+type PreventionSetting struct {
+	ID          string          `json:"id"`
+	Name        string          `json:"name"`
+	Type        string          `json:"type"`
+	Description string          `json:"description"`
+	Value       json.RawMessage `json:"value"`
+}
+
+// PreventionCategory groups related prevention settings
+type PreventionCategory struct {
+	Name     string              `json:"name"`
+	Settings []PreventionSetting `json:"settings"`
+}
+
+// PreventionPolicy controls which malicious activity is detected and prevented
+type PreventionPolicy struct {
+	Policy
+	PreventionSettings []PreventionCategory `json:"prevention_settings"`
+}
+
+// UnmarshalJSON decodes the common fields with the dates of Policy - the embedded Policy would
+// otherwise decode the whole PreventionPolicy
+func (p *PreventionPolicy) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &p.Policy); err != nil {
+		return err
+	}
+	aux := &struct {
+		PreventionSettings *[]PreventionCategory `json:"prevention_settings"`
+	}{&p.PreventionSettings}
+	return json.Unmarshal(b, aux)
+}
+
+// SettingValues returns the values of all the settings for use in a PreventionPolicyRequest
+func (p *PreventionPolicy) SettingValues() []PreventionSettingValue {
+	var values []PreventionSettingValue
+	for i := range p.PreventionSettings {
+		for _, s := range p.PreventionSettings[i].Settings {
+			values = append(values, PreventionSettingValue{ID: s.ID, Value: s.Value})
+		}
+	}
+	return values
+}
+
+// PreventionSettingValue sets the value of a prevention setting
+type PreventionSettingValue struct {
+	ID    string      `json:"id"`
+	Value interface{} `json:"value"`
+}
+
+// PreventionPolicyRequest creates or updates a prevention policy. Empty fields are left unchanged on update.
+type PreventionPolicyRequest struct {
+	ID           string                   `json:"id,omitempty"`            // Required for updates
+	Name         string                   `json:"name,omitempty"`          // Required for creation
+	Description  string                   `json:"description,omitempty"`   // Optional
+	PlatformName string                   `json:"platform_name,omitempty"` // Required for creation - Windows, Mac or Linux
+	Settings     []PreventionSettingValue `json:"settings,omitempty"`
+}
+
+// SensorUpdateVariant is the sensor build for a specific platform variant
+type SensorUpdateVariant struct {
+	Build         string `json:"build"`
+	Platform      string `json:"platform"`
+	SensorVersion string `json:"sensor_version"`
+}
+
+// SensorUpdateSettings controls which sensor build is installed
+type SensorUpdateSettings struct {
+	Build               string                `json:"build"`                          // Build to install, empty to disable updates
+	SensorVersion       string                `json:"sensor_version,omitempty"`       // Read only
+	UninstallProtection string                `json:"uninstall_protection,omitempty"` // ENABLED or DISABLED
+	Variants            []SensorUpdateVariant `json:"variants,omitempty"`
+}
+
+// SensorUpdatePolicy controls the sensor version installed on hosts
+type SensorUpdatePolicy struct {
+	Policy
+	Settings SensorUpdateSettings `json:"settings"`
+}
+
+// UnmarshalJSON decodes the common fields with the dates of Policy - the embedded Policy would
+// otherwise decode the whole SensorUpdatePolicy
+func (p *SensorUpdatePolicy) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &p.Policy); err != nil {
+		return err
+	}
+	aux := &struct {
+		Settings *SensorUpdateSettings `json:"settings"`
+	}{&p.Settings}
+	return json.Unmarshal(b, aux)
+}
+
+// SensorUpdatePolicyRequest creates or updates a sensor update policy. Empty fields are left unchanged on update.
+type SensorUpdatePolicyRequest struct {
+	ID           string                `json:"id,omitempty"`
+	Name         string                `json:"name,omitempty"`
+	Description  string                `json:"description,omitempty"`
+	PlatformName string                `json:"platform_name,omitempty"`
+	Settings     *SensorUpdateSettings `json:"settings,omitempty"`
+}
+
+// DeviceControlException allows or blocks a specific USB device
+type DeviceControlException struct {
+	ID               string `json:"id,omitempty"`
+	Class            string `json:"class,omitempty"`
+	VendorID         string `json:"vendor_id,omitempty"`
+	VendorIDDecimal  string `json:"vendor_id_decimal,omitempty"`
+	VendorName       string `json:"vendor_name,omitempty"`
+	ProductID        string `json:"product_id,omitempty"`
+	ProductIDDecimal string `json:"product_id_decimal,omitempty"`
+	ProductName      string `json:"product_name,omitempty"`
+	SerialNumber     string `json:"serial_number,omitempty"`
+	CombinedID       string `json:"combined_id,omitempty"`
+	MatchMethod      string `json:"match_method,omitempty"`
+	Action           string `json:"action,omitempty"`
+	Description      string `json:"description,omitempty"`
+}
+
+// DeviceControlClass sets the action for a class of USB devices
+type DeviceControlClass struct {
+	ID         string                   `json:"id"`
+	Action     string                   `json:"action"` // FULL_ACCESS, FULL_BLOCK, BLOCK_EXECUTE or READ_ONLY
+	Exceptions []DeviceControlException `json:"exceptions,omitempty"`
+}
+
+// DeviceControlSettings controls the access to USB devices
+type DeviceControlSettings struct {
+	EnforcementMode     string               `json:"enforcement_mode"`      // MONITOR_ONLY or MONITOR_ENFORCE
+	EndUserNotification string               `json:"end_user_notification"` // SILENT or NOTIFY_USER
+	Classes             []DeviceControlClass `json:"classes"`
+}
+
+// DeviceControlPolicy controls the access of hosts to USB devices
+type DeviceControlPolicy struct {
+	Policy
+	Settings DeviceControlSettings `json:"settings"`
+}
+
+// UnmarshalJSON decodes the common fields with the dates of Policy - the embedded Policy would
+// otherwise decode the whole DeviceControlPolicy
+func (p *DeviceControlPolicy) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &p.Policy); err != nil {
+		return err
+	}
+	aux := &struct {
+		Settings *DeviceControlSettings `json:"settings"`
+	}{&p.Settings}
+	return json.Unmarshal(b, aux)
+}
+
+// DeviceControlPolicyRequest creates or updates a device control policy. Empty fields are left unchanged on update.
+type DeviceControlPolicyRequest struct {
+	ID           string                 `json:"id,omitempty"`
+	Name         string                 `json:"name,omitempty"`
+	Description  string                 `json:"description,omitempty"`
+	PlatformName string                 `json:"platform_name,omitempty"`
+	Settings     *DeviceControlSettings `json:"settings,omitempty"`
+}
+
+// PoliciesResponse is returned from the policy actions
+type PoliciesResponse struct {
+	Meta struct {
+		QueryTime float64 `json:"query_time"`
+		TraceID   string  `json:"trace_id"`
+	} `json:"meta"`
+	Resources []Policy `json:"resources"`
+	Errors    []Error  `json:"errors"`
+	items     []string
+}
+
+// Err returns a *PartialError if the response carries per-item errors, nil otherwise
+func (r *PoliciesResponse) Err() error {
+	return newPartialError(r.items, r.Errors)
+}
+
+// PreventionPoliciesResponse is returned from the prevention policy APIs
+type PreventionPoliciesResponse struct {
+	Meta struct {
+		QueryTime float64 `json:"query_time"`
+		TraceID   string  `json:"trace_id"`
+	} `json:"meta"`
+	Resources []PreventionPolicy `json:"resources"`
+	Errors    []Error            `json:"errors"`
+	items     []string
+}
+
+// Err returns a *PartialError if the response carries per-item errors, nil otherwise
+func (r *PreventionPoliciesResponse) Err() error {
+	return newPartialError(r.items, r.Errors)
+}
+
+// SensorUpdatePoliciesResponse is returned from the sensor update policy APIs
+type SensorUpdatePoliciesResponse struct {
+	Meta struct {
+		QueryTime float64 `json:"query_time"`
+		TraceID   string  `json:"trace_id"`
+	} `json:"meta"`
+	Resources []SensorUpdatePolicy `json:"resources"`
+	Errors    []Error              `json:"errors"`
+	items     []string
+}
+
+// Err returns a *PartialError if the response carries per-item errors, nil otherwise
+func (r *SensorUpdatePoliciesResponse) Err() error {
+	return newPartialError(r.items, r.Errors)
+}
+
+// DeviceControlPoliciesResponse is returned from the device control policy APIs
+type DeviceControlPoliciesResponse struct {
+	Meta struct {
+		QueryTime float64 `json:"query_time"`
+		TraceID   string  `json:"trace_id"`
+	} `json:"meta"`
+	Resources []DeviceControlPolicy `json:"resources"`
+	Errors    []Error               `json:"errors"`
+	items     []string
+}
+
+// Err returns a *PartialError if the response carries per-item errors, nil otherwise
+func (r *DeviceControlPoliciesResponse) Err() error {
+	return newPartialError(r.items, r.Errors)
+}
+
+// partialErr is implemented by all the responses carrying per-item errors
+type partialErr interface {
+	Err() error
+}
+
+// policyEntityPath returns the entities API path of the policy kind
+func policyEntityPath(kind string) (string, error) {
+	switch kind {
+	case PolicyPrevention, PolicyDeviceControl:
+		return "policy/entities/" + kind + "/v1", nil
+	case PolicySensorUpdate:
+		return "policy/entities/" + kind + "/v2", nil
+	}
+	return "", &Error{Code: "bad_policy_kind", Message: fmt.Sprintf("Unknown policy kind [%s]", kind)}
+}
+
+// getPolicies fetches the policies of the given kind into resp
+func (h *Host) getPolicies(ctx context.Context, kind string, ids []string, resp partialErr) error {
+	path, err := policyEntityPath(kind)
+	if err != nil {
+		return err
+	}
+	params := url.Values{}
+	addStringArr("ids", ids, params)
+	if err = h.do(ctx, "GET", path, params, nil, resp, h.authFunc()); err != nil {
+		return err
+	}
+	return h.partial(resp.Err())
+}
+
+// writePolicies creates or updates the policies of the given kind and decodes the result into resp
+func (h *Host) writePolicies(ctx context.Context, method, kind string, resources interface{}, resp partialErr) error {
+	path, err := policyEntityPath(kind)
+	if err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	err = json.NewEncoder(&b).Encode(struct {
+		Resources interface{} `json:"resources"`
+	}{Resources: resources})
+	if err != nil {
+		return err
+	}
+	if err = h.do(ctx, method, path, nil, &b, resp, h.authFunc()); err != nil {
+		return err
+	}
+	return h.partial(resp.Err())
+}
+
+// QueryPolicies returns a page of the IDs of the policies of the given kind matching the request
+func (h *Host) QueryPolicies(kind string, req *QueryRequest) (resp *QueryResponse, err error) {
+	return h.QueryPoliciesContext(context.Background(), kind, req)
+}
+
+// QueryPoliciesContext is like QueryPolicies with a context for cancellation and deadlines
+func (h *Host) QueryPoliciesContext(ctx context.Context, kind string, req *QueryRequest) (resp *QueryResponse, err error) {
+	if _, err = policyEntityPath(kind); err != nil {
+		return nil, err
+	}
+	resp = &QueryResponse{}
+	err = h.do(ctx, "GET", "policy/queries/"+kind+"/v1", queryRequestToParams(req), nil, resp, h.authFunc())
+	if err == nil {
+		err = h.partial(resp.Err())
+	}
+	return
+}
+
+// QueryPoliciesAll returns a pager over the IDs of all the policies of the given kind matching the request
+func (h *Host) QueryPoliciesAll(kind string, req *QueryRequest, max int) *IDPager {
+	return h.QueryPoliciesAllContext(context.Background(), kind, req, max)
+}
+
+// QueryPoliciesAllContext is like QueryPoliciesAll with a context for cancellation and deadlines
+func (h *Host) QueryPoliciesAllContext(ctx context.Context, kind string, req *QueryRequest, max int) *IDPager {
+	r := *req
+	p := &IDPager{}
+	p.pager = newPager(ctx, r.Offset, max, queryPage(&p.ids, func(ctx context.Context, offset int, _ string) (*QueryResponse, error) {
+		r.Offset = offset
+		return h.QueryPoliciesContext(ctx, kind, &r)
+	}))
+	return p
+}
+
+// DeletePolicies deletes the policies of the given kind. Policies must be disabled before they are deleted.
+func (h *Host) DeletePolicies(kind string, ids []string) (resp *QueryResponse, err error) {
+	return h.DeletePoliciesContext(context.Background(), kind, ids)
+}
+
+// DeletePoliciesContext is like DeletePolicies with a context for cancellation and deadlines
+func (h *Host) DeletePoliciesContext(ctx context.Context, kind string, ids []string) (resp *QueryResponse, err error) {
+	if _, err = policyEntityPath(kind); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, ErrMissingParams
+	}
+	resp = &QueryResponse{items: ids}
+	params := url.Values{}
+	addStringArr("ids", ids, params)
+	// Deletion is still on v1 for all the kinds
+	err = h.do(ctx, "DELETE", "policy/entities/"+kind+"/v1", params, nil, resp, h.authFunc())
+	if err == nil {
+		err = h.partial(resp.Err())
+	}
+	return
+}
+
+// policyAction performs an action on policies of the given kind
+func (h *Host) policyAction(ctx context.Context, kind, action string, ids []string, params ...actionParameter) (resp *PoliciesResponse, err error) {
+	if _, err = policyEntityPath(kind); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, ErrMissingParams
+	}
+	resp = &PoliciesResponse{items: ids}
+	b, err := actionBody(ids, params...)
+	if err != nil {
+		return
+	}
+	err = h.do(ctx, "POST", "policy/entities/"+kind+"-actions/v1", url.Values{"action_name": {action}}, b, resp, h.authFunc())
+	if err == nil {
+		err = h.partial(resp.Err())
+	}
+	return
+}
+
+// EnablePolicies enables the policies of the given kind
+func (h *Host) EnablePolicies(kind string, ids []string) (resp *PoliciesResponse, err error) {
+	return h.policyAction(context.Background(), kind, "enable", ids)
+}
+
+// EnablePoliciesContext is like EnablePolicies with a context for cancellation and deadlines
+func (h *Host) EnablePoliciesContext(ctx context.Context, kind string, ids []string) (resp *PoliciesResponse, err error) {
+	return h.policyAction(ctx, kind, "enable", ids)
+}
+
+// DisablePolicies disables the policies of the given kind
+func (h *Host) DisablePolicies(kind string, ids []string) (resp *PoliciesResponse, err error) {
+	return h.policyAction(context.Background(), kind, "disable", ids)
+}
+
+// DisablePoliciesContext is like DisablePolicies with a context for cancellation and deadlines
+func (h *Host) DisablePoliciesContext(ctx context.Context, kind string, ids []string) (resp *PoliciesResponse, err error) {
+	return h.policyAction(ctx, kind, "disable", ids)
+}
+
+// AddPolicyHostGroup attaches the host group to the policy of the given kind
+func (h *Host) AddPolicyHostGroup(kind, policyID, groupID string) (resp *PoliciesResponse, err error) {
+	return h.AddPolicyHostGroupContext(context.Background(), kind, policyID, groupID)
+}
+
+// AddPolicyHostGroupContext is like AddPolicyHostGroup with a context for cancellation and deadlines
+func (h *Host) AddPolicyHostGroupContext(ctx context.Context, kind, policyID, groupID string) (resp *PoliciesResponse, err error) {
+	if policyID == "" || groupID == "" {
+		return nil, ErrMissingParams
+	}
+	return h.policyAction(ctx, kind, "add-host-group", []string{policyID}, actionParameter{Name: "group_id", Value: groupID})
+}
+
+// RemovePolicyHostGroup detaches the host group from the policy of the given kind
+func (h *Host) RemovePolicyHostGroup(kind, policyID, groupID string) (resp *PoliciesResponse, err error) {
+	return h.RemovePolicyHostGroupContext(context.Background(), kind, policyID, groupID)
+}
+
+// RemovePolicyHostGroupContext is like RemovePolicyHostGroup with a context for cancellation and deadlines
+func (h *Host) RemovePolicyHostGroupContext(ctx context.Context, kind, policyID, groupID string) (resp *PoliciesResponse, err error) {
+	if policyID == "" || groupID == "" {
+		return nil, ErrMissingParams
+	}
+	return h.policyAction(ctx, kind, "remove-host-group", []string{policyID}, actionParameter{Name: "group_id", Value: groupID})
+}
+
+// SetPolicyPrecedence sets the precedence of the policies of the given kind on the platform.
+// The IDs must contain all the policies of the platform, highest precedence first.
+func (h *Host) SetPolicyPrecedence(kind, platform string, ids []string) (resp *QueryResponse, err error) {
+	return h.SetPolicyPrecedenceContext(context.Background(), kind, platform, ids)
+}
+
+// SetPolicyPrecedenceContext is like SetPolicyPrecedence with a context for cancellation and deadlines
+func (h *Host) SetPolicyPrecedenceContext(ctx context.Context, kind, platform string, ids []string) (resp *QueryResponse, err error) {
+	if _, err = policyEntityPath(kind); err != nil {
+		return nil, err
+	}
+	if platform == "" || len(ids) == 0 {
+		return nil, ErrMissingParams
+	}
+	resp = &QueryResponse{items: ids}
+	var b bytes.Buffer
+	err = json.NewEncoder(&b).Encode(struct {
+		IDs          []string `json:"ids"`
+		PlatformName string   `json:"platform_name"`
+	}{IDs: ids, PlatformName: platform})
+	if err != nil {
+		return
+	}
+	err = h.do(ctx, "POST", "policy/entities/"+kind+"-precedence/v1", nil, &b, resp, h.authFunc())
+	if err == nil {
+		err = h.partial(resp.Err())
+	}
+	return
+}
+
+// GetPreventionPolicies returns the prevention policies with the given IDs
+func (h *Host) GetPreventionPolicies(ids []string) (resp *PreventionPoliciesResponse, err error) {
+	return h.GetPreventionPoliciesContext(context.Background(), ids)
+}
+
+// GetPreventionPoliciesContext is like GetPreventionPolicies with a context for cancellation and deadlines
+func (h *Host) GetPreventionPoliciesContext(ctx context.Context, ids []string) (resp *PreventionPoliciesResponse, err error) {
+	if len(ids) == 0 {
+		return nil, ErrMissingParams
+	}
+	resp = &PreventionPoliciesResponse{items: ids}
+	err = h.getPolicies(ctx, PolicyPrevention, ids, resp)
+	return
+}
+
+// CreatePreventionPolicies creates the prevention policies
+func (h *Host) CreatePreventionPolicies(policies []PreventionPolicyRequest) (resp *PreventionPoliciesResponse, err error) {
+	return h.CreatePreventionPoliciesContext(context.Background(), policies)
+}
+
+// CreatePreventionPoliciesContext is like CreatePreventionPolicies with a context for cancellation and deadlines
+func (h *Host) CreatePreventionPoliciesContext(ctx context.Context, policies []PreventionPolicyRequest) (resp *PreventionPoliciesResponse, err error) {
+	names := make([]string, len(policies))
+	for i := range policies {
+		if policies[i].Name == "" || policies[i].PlatformName == "" {
+			return nil, ErrMissingParams
+		}
+		names[i] = policies[i].Name
+	}
+	if len(names) == 0 {
+		return nil, ErrMissingParams
+	}
+	resp = &PreventionPoliciesResponse{items: names}
+	err = h.writePolicies(ctx, "POST", PolicyPrevention, policies, resp)
+	return
+}
+
+// UpdatePreventionPolicies updates the prevention policies
+func (h *Host) UpdatePreventionPolicies(policies []PreventionPolicyRequest) (resp *PreventionPoliciesResponse, err error) {
+	return h.UpdatePreventionPoliciesContext(context.Background(), policies)
+}
+
+// UpdatePreventionPoliciesContext is like UpdatePreventionPolicies with a context for cancellation and deadlines
+func (h *Host) UpdatePreventionPoliciesContext(ctx context.Context, policies []PreventionPolicyRequest) (resp *PreventionPoliciesResponse, err error) {
+	ids := make([]string, len(policies))
+	for i := range policies {
+		if policies[i].ID == "" {
+			return nil, ErrMissingParams
+		}
+		ids[i] = policies[i].ID
+	}
+	if len(ids) == 0 {
+		return nil, ErrMissingParams
+	}
+	resp = &PreventionPoliciesResponse{items: ids}
+	err = h.writePolicies(ctx, "PATCH", PolicyPrevention, policies, resp)
+	return
+}
+
+// GetSensorUpdatePolicies returns the sensor update policies with the given IDs
+func (h *Host) GetSensorUpdatePolicies(ids []string) (resp *SensorUpdatePoliciesResponse, err error) {
+	return h.GetSensorUpdatePoliciesContext(context.Background(), ids)
+}
+
+// GetSensorUpdatePoliciesContext is like GetSensorUpdatePolicies with a context for cancellation and deadlines
+func (h *Host) GetSensorUpdatePoliciesContext(ctx context.Context, ids []string) (resp *SensorUpdatePoliciesResponse, err error) {
+	if len(ids) == 0 {
+		return nil, ErrMissingParams
+	}
+	resp = &SensorUpdatePoliciesResponse{items: ids}
+	err = h.getPolicies(ctx, PolicySensorUpdate, ids, resp)
+	return
+}
+
+// CreateSensorUpdatePolicies creates the sensor update policies
+func (h *Host) CreateSensorUpdatePolicies(policies []SensorUpdatePolicyRequest) (resp *SensorUpdatePoliciesResponse, err error) {
+	return h.CreateSensorUpdatePoliciesContext(context.Background(), policies)
+}
+
+// CreateSensorUpdatePoliciesContext is like CreateSensorUpdatePolicies with a context for cancellation and deadlines
+func (h *Host) CreateSensorUpdatePoliciesContext(ctx context.Context, policies []SensorUpdatePolicyRequest) (resp *SensorUpdatePoliciesResponse, err error) {
+	names := make([]string, len(policies))
+	for i := range policies {
+		if policies[i].Name == "" || policies[i].PlatformName == "" {
+			return nil, ErrMissingParams
+		}
+		names[i] = policies[i].Name
+	}
+	if len(names) == 0 {
+		return nil, ErrMissingParams
+	}
+	resp = &SensorUpdatePoliciesResponse{items: names}
+	err = h.writePolicies(ctx, "POST", PolicySensorUpdate, policies, resp)
+	return
+}
+
+// UpdateSensorUpdatePolicies updates the sensor update policies
+func (h *Host) UpdateSensorUpdatePolicies(policies []SensorUpdatePolicyRequest) (resp *SensorUpdatePoliciesResponse, err error) {
+	return h.UpdateSensorUpdatePoliciesContext(context.Background(), policies)
+}
+
+// UpdateSensorUpdatePoliciesContext is like UpdateSensorUpdatePolicies with a context for cancellation and deadlines
+func (h *Host) UpdateSensorUpdatePoliciesContext(ctx context.Context, policies []SensorUpdatePolicyRequest) (resp *SensorUpdatePoliciesResponse, err error) {
+	ids := make([]string, len(policies))
+	for i := range policies {
+		if policies[i].ID == "" {
+			return nil, ErrMissingParams
+		}
+		ids[i] = policies[i].ID
+	}
+	if len(ids) == 0 {
+		return nil, ErrMissingParams
+	}
+	resp = &SensorUpdatePoliciesResponse{items: ids}
+	err = h.writePolicies(ctx, "PATCH", PolicySensorUpdate, policies, resp)
+	return
+}
+
+// GetDeviceControlPolicies returns the device control policies with the given IDs
+func (h *Host) GetDeviceControlPolicies(ids []string) (resp *DeviceControlPoliciesResponse, err error) {
+	return h.GetDeviceControlPoliciesContext(context.Background(), ids)
+}
+
+// GetDeviceControlPoliciesContext is like GetDeviceControlPolicies with a context for cancellation and deadlines
+func (h *Host) GetDeviceControlPoliciesContext(ctx context.Context, ids []string) (resp *DeviceControlPoliciesResponse, err error) {
+	if len(ids) == 0 {
+		return nil, ErrMissingParams
+	}
+	resp = &DeviceControlPoliciesResponse{items: ids}
+	err = h.getPolicies(ctx, PolicyDeviceControl, ids, resp)
+	return
+}
+
+// CreateDeviceControlPolicies creates the device control policies
+func (h *Host) CreateDeviceControlPolicies(policies []DeviceControlPolicyRequest) (resp *DeviceControlPoliciesResponse, err error) {
+	return h.CreateDeviceControlPoliciesContext(context.Background(), policies)
+}
+
+// CreateDeviceControlPoliciesContext is like CreateDeviceControlPolicies with a context for cancellation and deadlines
+func (h *Host) CreateDeviceControlPoliciesContext(ctx context.Context, policies []DeviceControlPolicyRequest) (resp *DeviceControlPoliciesResponse, err error) {
+	names := make([]string, len(policies))
+	for i := range policies {
+		if policies[i].Name == "" || policies[i].PlatformName == "" {
+			return nil, ErrMissingParams
+		}
+		names[i] = policies[i].Name
+	}
+	if len(names) == 0 {
+		return nil, ErrMissingParams
+	}
+	resp = &DeviceControlPoliciesResponse{items: names}
+	err = h.writePolicies(ctx, "POST", PolicyDeviceControl, policies, resp)
+	return
+}
+
+// UpdateDeviceControlPolicies updates the device control policies
+func (h *Host) UpdateDeviceControlPolicies(policies []DeviceControlPolicyRequest) (resp *DeviceControlPoliciesResponse, err error) {
+	return h.UpdateDeviceControlPoliciesContext(context.Background(), policies)
+}
+
+// UpdateDeviceControlPoliciesContext is like UpdateDeviceControlPolicies with a context for cancellation and deadlines
+func (h *Host) UpdateDeviceControlPoliciesContext(ctx context.Context, policies []DeviceControlPolicyRequest) (resp *DeviceControlPoliciesResponse, err error) {
+	ids := make([]string, len(policies))
+	for i := range policies {
+		if policies[i].ID == "" {
+			return nil, ErrMissingParams
+		}
+		ids[i] = policies[i].ID
+	}
+	if len(ids) == 0 {
+		return nil, ErrMissingParams
+	}
+	resp = &DeviceControlPoliciesResponse{items: ids}
+	err = h.writePolicies(ctx, "PATCH", PolicyDeviceControl, policies, resp)
+	return
+}
@@ -0,0 +1,152 @@
+package gocs
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestPolicyKinds(t *testing.T) {
+	h := newTestHost(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request %s", r.URL)
+	})
+	if _, err := h.QueryPolicies("firewall", &QueryRequest{}); err == nil {
+		t.Fatal("expected an error for an unknown kind")
+	}
+	if _, err := h.EnablePolicies("firewall", []string{"p1"}); err == nil {
+		t.Fatal("expected an error for an unknown kind")
+	}
+	if _, err := h.UpdatePreventionPolicies([]PreventionPolicyRequest{{Name: "no id"}}); err != ErrMissingParams {
+		t.Fatalf("expected ErrMissingParams, got %v", err)
+	}
+	if _, err := h.CreateSensorUpdatePolicies([]SensorUpdatePolicyRequest{{Name: "no platform"}}); err != ErrMissingParams {
+		t.Fatalf("expected ErrMissingParams, got %v", err)
+	}
+}
+
+func TestGetPreventionPolicies(t *testing.T) {
+	h := newTestHost(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/policy/entities/prevention/v1" || r.URL.Query().Get("ids") != "p1" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		w.Write([]byte(`{"resources":[{"id":"p1","name":"default","platform_name":"Windows","enabled":true,
+			"groups":[{"id":"g1","name":"servers"}],
+			"prevention_settings":[{"name":"Cloud ML","settings":[
+				{"id":"CloudAntiMalware","type":"mlslider","value":{"detection":"MODERATE","prevention":"CAUTIOUS"}},
+				{"id":"NextGenAV","type":"toggle","value":{"enabled":true}}]}]}]}`))
+	})
+	resp, err := h.GetPreventionPolicies([]string{"p1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := resp.Resources[0]
+	if p.ID != "p1" || !p.Enabled || p.Groups[0].Name != "servers" {
+		t.Fatalf("unexpected policy %+v", p.Policy)
+	}
+	values := p.SettingValues()
+	if len(values) != 2 || values[1].ID != "NextGenAV" || string(values[1].Value.(json.RawMessage)) != `{"enabled":true}` {
+		t.Fatalf("unexpected values %+v", values)
+	}
+}
+
+func TestWritePolicyPaths(t *testing.T) {
+	var paths []string
+	var bodies []map[string]interface{}
+	h := newTestHost(t, func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.Method+" "+r.URL.Path)
+		if r.Body != nil && r.Method != "DELETE" {
+			var body map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&body)
+			bodies = append(bodies, body)
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"resources": []interface{}{}})
+	})
+	if _, err := h.UpdateSensorUpdatePolicies([]SensorUpdatePolicyRequest{{ID: "s1", Settings: &SensorUpdateSettings{Build: "1234"}}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.DeletePolicies(PolicySensorUpdate, []string{"s1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.CreateDeviceControlPolicies([]DeviceControlPolicyRequest{{Name: "usb", PlatformName: "Windows"}}); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"PATCH /policy/entities/sensor-update/v2",
+		"DELETE /policy/entities/sensor-update/v1",
+		"POST /policy/entities/device-control/v1",
+	}
+	for i := range expected {
+		if paths[i] != expected[i] {
+			t.Fatalf("unexpected requests %v", paths)
+		}
+	}
+	update := bodies[0]["resources"].([]interface{})[0].(map[string]interface{})
+	if update["id"] != "s1" || update["name"] != nil || update["settings"].(map[string]interface{})["build"] != "1234" {
+		t.Fatalf("expected only the set fields, got %v", update)
+	}
+}
+
+func TestPolicyActions(t *testing.T) {
+	var body struct {
+		ActionParameters []actionParameter `json:"action_parameters"`
+		IDs              []string          `json:"ids"`
+	}
+	var action string
+	h := newTestHost(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/policy/entities/prevention-actions/v1" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		action = r.URL.Query().Get("action_name")
+		json.NewDecoder(r.Body).Decode(&body)
+		writeJSON(w, http.StatusOK, map[string]interface{}{"resources": []Policy{{ID: "p1"}}})
+	})
+	if _, err := h.AddPolicyHostGroup(PolicyPrevention, "p1", "g1"); err != nil {
+		t.Fatal(err)
+	}
+	if action != "add-host-group" || body.IDs[0] != "p1" || body.ActionParameters[0] != (actionParameter{Name: "group_id", Value: "g1"}) {
+		t.Fatalf("unexpected action %s %+v", action, body)
+	}
+	if _, err := h.DisablePolicies(PolicyPrevention, []string{"p1", "p2"}); err != nil {
+		t.Fatal(err)
+	}
+	if action != "disable" || len(body.IDs) != 2 {
+		t.Fatalf("unexpected action %s %+v", action, body)
+	}
+}
+
+func TestSetPolicyPrecedence(t *testing.T) {
+	h := newTestHost(t, func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			IDs          []string `json:"ids"`
+			PlatformName string   `json:"platform_name"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if r.URL.Path != "/policy/entities/device-control-precedence/v1" || body.PlatformName != "Windows" || len(body.IDs) != 2 {
+			t.Errorf("unexpected request %s %+v", r.URL, body)
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{})
+	})
+	if _, err := h.SetPolicyPrecedence(PolicyDeviceControl, "Windows", []string{"a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPolicyTimestamps(t *testing.T) {
+	var p SensorUpdatePolicy
+	err := json.Unmarshal([]byte(`{"id":"s1","created_timestamp":"","modified_timestamp":1583056800,
+		"groups":[{"id":"g1","created_timestamp":""}],"settings":{"build":"1234"}}`), &p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The embedded Policy must not swallow the fields of the kind
+	if p.ID != "s1" || p.Settings.Build != "1234" || !p.CreatedTimestamp.IsZero() || p.ModifiedTimestamp.Unix() != 1583056800 || p.Groups[0].ID != "g1" {
+		t.Fatalf("unexpected policy %+v", p)
+	}
+	var d DeviceControlPolicy
+	if err = json.Unmarshal([]byte(`{"id":"d1","created_timestamp":1583056800,"settings":{"enforcement_mode":"MONITOR_ONLY"}}`), &d); err != nil {
+		t.Fatal(err)
+	}
+	if d.ID != "d1" || d.Settings.EnforcementMode != "MONITOR_ONLY" || d.CreatedTimestamp.Unix() != 1583056800 {
+		t.Fatalf("unexpected policy %+v", d)
+	}
+}
@@ -0,0 +1,144 @@
+package gocs
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxRateLimitWaits is the number of times a request is resent after a 429 response when throttling is enabled
+const maxRateLimitWaits = 3
+
+// rateLimitWindow is the documented sliding window of the request budget. The API reports when the
+// window resets only on 429 responses, so otherwise we assume it resets a window after we first saw it.
+var rateLimitWindow = time.Minute
+
+// RateLimit is the request budget as reported by the API in the X-RateLimit headers
+type RateLimit struct {
+	Limit     int       // Number of requests allowed in the current window
+	Remaining int       // Number of requests remaining in the current window
+	Reset     time.Time // When the current window ends and the budget is replenished, estimated if not reported
+}
+
+// rateLimiter tracks the request budget. It is shared by all the goroutines using the same client.
+type rateLimiter struct {
+	mu           sync.Mutex
+	rl           RateLimit
+	known        bool // Did we see the headers at all
+	throttle     bool // Should we wait proactively when the budget is exhausted
+	minRemaining int  // Wait when the remaining budget reaches this number
+}
+
+// SetRateLimitThrottle enables proactive throttling. When the remaining request budget reported by the
+// API drops to minRemaining, requests wait until the window resets. Requests that receive a 429 response
+// wait for the reset and are resent. The budget is shared by all goroutines using the same Host or Intel.
+func SetRateLimitThrottle(minRemaining int) OptionFunc {
+	return func(c *client) error {
+		if minRemaining < 0 {
+			err := &Error{Code: "bad_throttle", Message: fmt.Sprintf("Invalid minimum remaining requests %d", minRemaining)}
+			c.errorf("%v\n", err)
+			return err
+		}
+		c.limiter.throttle = true
+		c.limiter.minRemaining = minRemaining
+		return nil
+	}
+}
+
+// RateLimit returns the last request budget reported by the API.
+// The zero value is returned if no response carried rate limit headers yet.
+func (c *client) RateLimit() RateLimit {
+	c.limiter.mu.Lock()
+	defer c.limiter.mu.Unlock()
+	return c.limiter.rl
+}
+
+// update records the rate limit headers from the response
+func (l *rateLimiter) update(resp *http.Response) {
+	limit, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Limit"))
+	if err != nil {
+		return
+	}
+	remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.known = true
+	l.rl.Limit, l.rl.Remaining = limit, remaining
+	if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-RetryAfter"), 10, 64); err == nil {
+		l.rl.Reset = time.Unix(reset, 0)
+	} else if now := time.Now(); !l.rl.Reset.After(now) {
+		l.rl.Reset = now.Add(rateLimitWindow)
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		l.rl.Remaining = 0
+	}
+}
+
+// exhausted marks the budget as used up after a 429 response.
+// If the response did not tell us when the window resets, we wait for a full window.
+func (l *rateLimiter) exhausted(resp *http.Response) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.known = true
+	l.rl.Remaining = 0
+	if d, ok := rateLimitWait(resp); ok {
+		// Do not hammer the API if the window is about to reset
+		if d < time.Second {
+			d = time.Second
+		}
+		l.rl.Reset = time.Now().Add(d)
+	} else if !l.rl.Reset.After(time.Now()) {
+		l.rl.Reset = time.Now().Add(rateLimitWindow)
+	}
+}
+
+// rateLimitWait returns the time until the rate limit window resets according to the response headers
+func rateLimitWait(resp *http.Response) (time.Duration, bool) {
+	if wait, ok := retryAfter(resp); ok {
+		return wait, true
+	}
+	if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-RetryAfter"), 10, 64); err == nil {
+		wait := time.Until(time.Unix(reset, 0))
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
+
+// wait blocks until there is budget for one more request and reserves it
+func (l *rateLimiter) wait(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		if !l.throttle || !l.known {
+			l.mu.Unlock()
+			return nil
+		}
+		now := time.Now()
+		if !now.Before(l.rl.Reset) {
+			// The window has reset - start from the full budget until we hear otherwise
+			l.rl.Remaining = l.rl.Limit
+			l.rl.Reset = time.Time{}
+			l.known = false
+			l.mu.Unlock()
+			return nil
+		}
+		if l.rl.Remaining > l.minRemaining {
+			l.rl.Remaining--
+			l.mu.Unlock()
+			return nil
+		}
+		wait := l.rl.Reset.Sub(now)
+		l.mu.Unlock()
+		if err := sleepContext(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package gocs
+
+import (
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// exhaustedHandler reports an exhausted budget without telling when the window resets, like successful Falcon responses
+func exhaustedHandler(calls *int32) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		w.Header().Set("X-RateLimit-Limit", "10")
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		writeJSON(w, http.StatusOK, map[string]interface{}{})
+	}
+}
+
+// setRateLimitWindow shortens the assumed window for the duration of the test
+func setRateLimitWindow(t *testing.T, d time.Duration) {
+	old := rateLimitWindow
+	rateLimitWindow = d
+	t.Cleanup(func() { rateLimitWindow = old })
+}
+
+func TestThrottleWithoutReset(t *testing.T) {
+	setRateLimitWindow(t, 200*time.Millisecond)
+	var calls int32
+	h := newTestHost(t, exhaustedHandler(&calls), SetRateLimitThrottle(0))
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := h.SearchIOCs(&SearchIOCsRequest{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The first call goes out at once and each of the next ones waits for a window
+	if d := time.Since(start); d < 400*time.Millisecond {
+		t.Fatalf("expected the calls to be throttled, took %v", d)
+	}
+	if calls != 3 {
+		t.Fatalf("expected 3 calls, got %d", calls)
+	}
+}
+
+func TestNoThrottleByDefault(t *testing.T) {
+	setRateLimitWindow(t, time.Second)
+	var calls int32
+	h := newTestHost(t, exhaustedHandler(&calls))
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := h.SearchIOCs(&SearchIOCsRequest{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d := time.Since(start); d >= time.Second {
+		t.Fatalf("expected no throttling, took %v", d)
+	}
+}
+
+func TestThrottleReportedReset(t *testing.T) {
+	setRateLimitWindow(t, time.Hour)
+	var calls int32
+	h := newTestHost(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("X-RateLimit-Limit", "10")
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-RetryAfter", strconv.FormatInt(time.Now().Add(time.Second).Unix(), 10))
+		writeJSON(w, http.StatusOK, map[string]interface{}{})
+	}, SetRateLimitThrottle(0))
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		if _, err := h.SearchIOCs(&SearchIOCsRequest{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The reported reset is used instead of the assumed window
+	if d := time.Since(start); d > 3*time.Second {
+		t.Fatalf("expected the reported reset to be used, took %v", d)
+	}
+}
+
+func TestRateLimitReported(t *testing.T) {
+	h := newTestHost(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "6000")
+		w.Header().Set("X-RateLimit-Remaining", "5999")
+		writeJSON(w, http.StatusOK, map[string]interface{}{})
+	})
+	if rl := h.RateLimit(); rl.Limit != 0 {
+		t.Fatalf("expected the zero value before any request, got %+v", rl)
+	}
+	if _, err := h.SearchIOCs(&SearchIOCsRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if rl := h.RateLimit(); rl.Limit != 6000 || rl.Remaining != 5999 || rl.Reset.IsZero() {
+		t.Fatalf("unexpected rate limit %+v", rl)
+	}
+}
+
+func TestThrottleResendsRateLimited(t *testing.T) {
+	var calls int32
+	h := newTestHost(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("X-RateLimit-Limit", "10")
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{})
+	}, SetRateLimitThrottle(0))
+	start := time.Now()
+	if _, err := h.SearchIOCs(&SearchIOCsRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Fatalf("expected the request to be resent once, got %d calls", calls)
+	}
+	if d := time.Since(start); d < time.Second {
+		t.Fatalf("expected to wait for the reset, took %v", d)
+	}
+}
+
+func TestRateLimitedWithoutThrottle(t *testing.T) {
+	var calls int32
+	h := newTestHost(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+	_, err := h.SearchIOCs(&SearchIOCsRequest{})
+	if apiErr, ok := err.(*APIError); !ok || apiErr.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected a 429 APIError, got %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected a single call, got %d", calls)
+	}
+}
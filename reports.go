@@ -0,0 +1,245 @@
+package gocs
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/demisto/gocs/fql"
+)
+
+// ReportRequest searches for Falcon Intelligence reports. The filters are AND'ed.
+//
+// The reports APIs are served from the OAuth2 API endpoint so the Intel client must be created with
+// SetOAuth2Credentials and SetURL or SetCloud.
+type ReportRequest struct {
+	Query            string     // Free text search across all the fields
+	Actors           []string   // Actor slugs, e.g. fancy-bear
+	TargetIndustries []string   // Target industry slugs, e.g. financial-services
+	Types            []string   // Report type slugs, e.g. intelligence-report or periodic-report
+	From             *time.Time // Reports created at or after this time
+	To               *time.Time // Reports created at or before this time
+	Filter           string     // Additional FQL filter
+	Fields           []string   // Fields requested in the reply. Can receive gocs.AllFields and gocs.BasicFields
+	Sort             *SortField // e.g. created_date
+	Paging
+}
+
+// filter builds the FQL filter of the request
+func (req *ReportRequest) filter() (string, error) {
+	var filters []fql.Filter
+	if len(req.Actors) > 0 {
+		filters = append(filters, fql.In("actors.slug", stringValues(req.Actors)...))
+	}
+	if len(req.TargetIndustries) > 0 {
+		filters = append(filters, fql.In("target_industries.slug", stringValues(req.TargetIndustries)...))
+	}
+	if len(req.Types) > 0 {
+		filters = append(filters, fql.In("type.slug", stringValues(req.Types)...))
+	}
+	// Dates are filtered as epoch seconds
+	if req.From != nil {
+		filters = append(filters, fql.Gte("created_date", req.From.Unix()))
+	}
+	if req.To != nil {
+		filters = append(filters, fql.Lte("created_date", req.To.Unix()))
+	}
+	if req.Filter != "" {
+		f, err := fql.Parse(req.Filter)
+		if err != nil {
+			return "", err
+		}
+		filters = append(filters, f)
+	}
+	f := fql.And(filters...)
+	return f.String(), f.Err()
+}
+
+func stringValues(vals []string) []interface{} {
+	res := make([]interface{}, len(vals))
+	for i := range vals {
+		res[i] = vals[i]
+	}
+	return res
+}
+
+func reportRequestToParams(req *ReportRequest) (url.Values, error) {
+	filter, err := req.filter()
+	if err != nil {
+		return nil, err
+	}
+	params := url.Values{}
+	addString("filter", filter, params)
+	addString("q", req.Query, params)
+	addStringArr("fields", req.Fields, params)
+	addPipeSort("sort", req.Sort, params)
+	if req.Limit != 0 {
+		addInt("limit", req.Limit, params)
+	}
+	if req.Offset != 0 {
+		addInt("offset", req.Offset, params)
+	}
+	return params, nil
+}
+
+// ReportActor is an actor a report is about
+type ReportActor struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+// Report is a Falcon Intelligence report
+type Report struct {
+	ID               int           `json:"id"`
+	Name             string        `json:"name"`
+	Slug             string        `json:"slug"`
+	ShortDescription string        `json:"short_description"`
+	Description      string        `json:"description"` // Only with AllFields
+	URL              string        `json:"url"`
+	Type             Slugable      `json:"type"`
+	SubType          Slugable      `json:"sub_type"`
+	Actors           []ReportActor `json:"actors"`
+	TargetIndustries []Slugable    `json:"target_industries"`
+	TargetCountries  []Slugable    `json:"target_countries"`
+	Motivations      []Slugable    `json:"motivations"`
+	Tags             []Slugable    `json:"tags"`
+	CreatedDate      time.Time     `json:"created_date"`
+	LastModifiedDate time.Time     `json:"last_modified_date"`
+}
+
+// UnmarshalJSON decodes the dates from epoch seconds or RFC3339 strings
+func (r *Report) UnmarshalJSON(b []byte) error {
+	type report Report
+	aux := &struct {
+		*report
+		Created      timestamp `json:"created_date"`
+		LastModified timestamp `json:"last_modified_date"`
+	}{report: (*report)(r)}
+	if err := json.Unmarshal(b, aux); err != nil {
+		return err
+	}
+	r.CreatedDate, r.LastModifiedDate = aux.Created.Time, aux.LastModified.Time
+	return nil
+}
+
+// ReportsResponse is returned from GetReports and SearchReports
+type ReportsResponse struct {
+	Meta struct {
+		QueryTime  float64 `json:"query_time"`
+		Pagination struct {
+			Total  int `json:"total"`
+			Offset int `json:"offset"`
+			Limit  int `json:"limit"`
+		} `json:"pagination"`
+		TraceID string `json:"trace_id"`
+	} `json:"meta"`
+	Resources []Report `json:"resources"`
+	Errors    []Error  `json:"errors"`
+	items     []string
+}
+
+// Err returns a *PartialError if the response carries per-item errors, nil otherwise
+func (r *ReportsResponse) Err() error {
+	return newPartialError(r.items, r.Errors)
+}
+
+// QueryReports returns a page of report IDs matching the request
+func (c *Intel) QueryReports(req *ReportRequest) (resp *QueryResponse, err error) {
+	return c.QueryReportsContext(context.Background(), req)
+}
+
+// QueryReportsContext is like QueryReports with a context for cancellation and deadlines
+func (c *Intel) QueryReportsContext(ctx context.Context, req *ReportRequest) (resp *QueryResponse, err error) {
+	params, err := reportRequestToParams(req)
+	if err != nil {
+		c.errorf("%v\n", err)
+		return nil, err
+	}
+	resp = &QueryResponse{}
+	err = c.do(ctx, "GET", "intel/queries/reports/v1", params, nil, resp, c.authFunc())
+	if err == nil {
+		err = c.partial(resp.Err())
+	}
+	return
+}
+
+// GetReports returns the reports with the given IDs. Pass AllFields in fields for the full description.
+func (c *Intel) GetReports(ids []string, fields ...string) (resp *ReportsResponse, err error) {
+	return c.GetReportsContext(context.Background(), ids, fields...)
+}
+
+// GetReportsContext is like GetReports with a context for cancellation and deadlines
+func (c *Intel) GetReportsContext(ctx context.Context, ids []string, fields ...string) (resp *ReportsResponse, err error) {
+	if len(ids) == 0 {
+		return nil, ErrMissingParams
+	}
+	resp = &ReportsResponse{items: ids}
+	params := url.Values{}
+	addStringArr("ids", ids, params)
+	addStringArr("fields", fields, params)
+	err = c.do(ctx, "GET", "intel/entities/reports/v1", params, nil, resp, c.authFunc())
+	if err == nil {
+		err = c.partial(resp.Err())
+	}
+	return
+}
+
+// SearchReports returns a page of the reports matching the request in a single call
+func (c *Intel) SearchReports(req *ReportRequest) (resp *ReportsResponse, err error) {
+	return c.SearchReportsContext(context.Background(), req)
+}
+
+// SearchReportsContext is like SearchReports with a context for cancellation and deadlines
+func (c *Intel) SearchReportsContext(ctx context.Context, req *ReportRequest) (resp *ReportsResponse, err error) {
+	params, err := reportRequestToParams(req)
+	if err != nil {
+		c.errorf("%v\n", err)
+		return nil, err
+	}
+	resp = &ReportsResponse{}
+	err = c.do(ctx, "GET", "intel/combined/reports/v1", params, nil, resp, c.authFunc())
+	if err == nil {
+		err = c.partial(resp.Err())
+	}
+	return
+}
+
+// ReportsAll returns a pager over all the reports matching the request starting at its offset.
+// The request limit is used as the page size. If max is positive, at most max reports are returned.
+func (c *Intel) ReportsAll(req *ReportRequest, max int) *ReportPager {
+	return c.ReportsAllContext(context.Background(), req, max)
+}
+
+// ReportsAllContext is like ReportsAll with a context for cancellation and deadlines
+func (c *Intel) ReportsAllContext(ctx context.Context, req *ReportRequest, max int) *ReportPager {
+	r := *req
+	p := &ReportPager{}
+	p.pager = newPager(ctx, r.Offset, max, func(ctx context.Context, offset int, _ string) (int, int, string, error) {
+		r.Offset = offset
+		resp, err := c.SearchReportsContext(ctx, &r)
+		if err != nil {
+			return 0, 0, "", err
+		}
+		p.reports = resp.Resources
+		return len(resp.Resources), resp.Meta.Pagination.Total, "", nil
+	})
+	return p
+}
+
+// DownloadReportPDF streams the PDF of the report to w
+func (c *Intel) DownloadReportPDF(id string, w io.Writer) error {
+	return c.DownloadReportPDFContext(context.Background(), id, w)
+}
+
+// DownloadReportPDFContext is like DownloadReportPDF with a context for cancellation and deadlines
+func (c *Intel) DownloadReportPDFContext(ctx context.Context, id string, w io.Writer) error {
+	if id == "" || w == nil {
+		return ErrMissingParams
+	}
+	header := http.Header{"Accept": {"application/pdf"}}
+	return c.stream(ctx, "GET", "intel/entities/report-files/v1", url.Values{"id": {id}}, nil, header, w, c.authFunc())
+}
@@ -0,0 +1,166 @@
+package gocs
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/demisto/gocs/fql"
+)
+
+func TestReportRequestFilter(t *testing.T) {
+	from := time.Unix(1583056800, 0)
+	to := time.Unix(1583143200, 0)
+	req := &ReportRequest{
+		Actors:           []string{"fancy-bear"},
+		TargetIndustries: []string{"financial-services", "energy"},
+		Types:            []string{"periodic-report"},
+		From:             &from,
+		To:               &to,
+		Filter:           "name:~'apt',name:~'bear'",
+	}
+	filter, err := req.filter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `actors.slug:['fancy-bear']+target_industries.slug:['financial-services','energy']+type.slug:['periodic-report']+` +
+		`created_date:>=1583056800+created_date:<=1583143200+(name:~'apt',name:~'bear')`
+	if filter != expected {
+		t.Fatalf("unexpected filter %s", filter)
+	}
+	if filter, err = (&ReportRequest{}).filter(); filter != "" || err != nil {
+		t.Fatalf("expected no filter, got %s %v", filter, err)
+	}
+	// Quotes in the slugs cannot change the filter
+	filter, _ = (&ReportRequest{Actors: []string{"x']+name:'y"}}).filter()
+	if err = fql.Validate(filter); err != nil || filter != `actors.slug:['x\']+name:\'y']` {
+		t.Fatalf("unexpected filter %s - %v", filter, err)
+	}
+}
+
+func TestSearchReports(t *testing.T) {
+	var query map[string][]string
+	c := newTestIntel(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/intel/combined/reports/v1" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		query = r.URL.Query()
+		w.Write([]byte(`{"meta":{"pagination":{"total":1}},"resources":[{"id":7,"name":"Report","type":{"id":1,"slug":"periodic-report"},
+			"actors":[{"id":2,"name":"FANCY BEAR","slug":"fancy-bear"}],"created_date":1583056800,"last_modified_date":""}]}`))
+	})
+	resp, err := c.SearchReports(&ReportRequest{Query: "bear", Types: []string{"periodic-report"}, Fields: []string{AllFields},
+		Sort: &SortField{Name: "created_date"}, Paging: Paging{Limit: 10, Offset: 20}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"q": "bear", "filter": "type.slug:['periodic-report']", "fields": AllFields,
+		"sort": "created_date|desc", "limit": "10", "offset": "20"}
+	for k, v := range expected {
+		if len(query[k]) != 1 || query[k][0] != v {
+			t.Fatalf("unexpected %s %v", k, query[k])
+		}
+	}
+	r := resp.Resources[0]
+	if r.ID != 7 || r.Type.Slug != "periodic-report" || r.Actors[0].Slug != "fancy-bear" ||
+		r.CreatedDate.Unix() != 1583056800 || !r.LastModifiedDate.IsZero() {
+		t.Fatalf("unexpected report %+v", r)
+	}
+}
+
+func TestQueryReportsBadFilter(t *testing.T) {
+	c := newTestIntel(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request %s", r.URL)
+	})
+	if _, err := c.QueryReports(&ReportRequest{Filter: "name:'apt"}); err == nil {
+		t.Fatal("expected an error for an invalid filter")
+	} else if _, ok := err.(*fql.SyntaxError); !ok {
+		t.Fatalf("expected a syntax error, got %v", err)
+	}
+	if _, err := c.SearchReports(&ReportRequest{Filter: "(name:'apt'"}); err == nil {
+		t.Fatal("expected an error for an invalid filter")
+	}
+}
+
+func TestGetReports(t *testing.T) {
+	c := newTestIntel(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/intel/entities/reports/v1" || len(r.URL.Query()["ids"]) != 2 || r.URL.Query().Get("fields") != AllFields {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		w.Write([]byte(`{"resources":[{"id":1,"created_date":"2020-03-01T10:00:00Z","last_modified_date":1583143200}],
+			"errors":[{"code":404,"message":"report 2 not found"}]}`))
+	})
+	resp, err := c.GetReports([]string{"1", "2"}, AllFields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := resp.Resources[0]; r.CreatedDate.Year() != 2020 || r.LastModifiedDate.Unix() != 1583143200 {
+		t.Fatalf("unexpected report %+v", r)
+	}
+	if pe, ok := resp.Err().(*PartialError); !ok || pe.Errors[0].Item != "2" {
+		t.Fatalf("expected the error of report 2, got %v", resp.Err())
+	}
+	if _, err = c.GetReports(nil); err != ErrMissingParams {
+		t.Fatalf("expected ErrMissingParams, got %v", err)
+	}
+}
+
+func TestReportsAll(t *testing.T) {
+	var calls int32
+	c := newTestIntel(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		var reports []map[string]interface{}
+		for i := offset; i < offset+2 && i < 3; i++ {
+			reports = append(reports, map[string]interface{}{"id": i})
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"meta":      map[string]interface{}{"pagination": map[string]interface{}{"total": 3}},
+			"resources": reports,
+		})
+	})
+	p := c.ReportsAll(&ReportRequest{Paging: Paging{Limit: 2}}, 0)
+	var ids []int
+	for p.Next() {
+		ids = append(ids, p.Report().ID)
+	}
+	if err := p.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 3 || ids[2] != 2 || calls != 2 {
+		t.Fatalf("unexpected reports %v in %d calls", ids, calls)
+	}
+}
+
+func TestDownloadReportPDF(t *testing.T) {
+	c := newTestIntel(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/intel/entities/report-files/v1" || r.URL.Query().Get("id") != "7" || r.Header.Get("Accept") != "application/pdf" {
+			t.Errorf("unexpected request %s %v", r.URL, r.Header)
+		}
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write([]byte("%PDF-1.4"))
+	})
+	var buf bytes.Buffer
+	if err := c.DownloadReportPDF("7", &buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "%PDF-1.4" {
+		t.Fatalf("unexpected PDF %q", buf.String())
+	}
+	if err := c.DownloadReportPDF("", &buf); err != ErrMissingParams {
+		t.Fatalf("expected ErrMissingParams, got %v", err)
+	}
+}
+
+func TestReportTimestamps(t *testing.T) {
+	var r Report
+	if err := json.Unmarshal([]byte(`{"id":1,"created_date":null,"last_modified_date":"1583143200"}`), &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.ID != 1 || !r.CreatedDate.IsZero() || r.LastModifiedDate.Unix() != 1583143200 {
+		t.Fatalf("unexpected report %+v", r)
+	}
+}
@@ -0,0 +1,149 @@
+package gocs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how requests that failed with a transient error are retried.
+//
+// Connection errors and 502, 503 and 504 responses are retried with jittered exponential backoff.
+// If the response carries a Retry-After header, it is honored instead of the computed backoff.
+// 429 responses are retried for all methods after the rate limit window resets.
+type RetryPolicy struct {
+	MaxRetries int           // Maximum number of retries after the initial attempt
+	MinBackoff time.Duration // Backoff before the first retry. Doubled on every retry.
+	MaxBackoff time.Duration // Upper bound for the computed backoff
+	// RetryNonIdempotent allows retrying POST and PATCH requests as well. The request body is rewound
+	// before each retry, and requests whose body cannot be rewound are never retried.
+	RetryNonIdempotent bool
+}
+
+// DefaultRetryPolicy is a reasonable policy for most uses
+var DefaultRetryPolicy = RetryPolicy{MaxRetries: 3, MinBackoff: 500 * time.Millisecond, MaxBackoff: 30 * time.Second}
+
+// SetRetryPolicy enables retrying of failed requests according to the given policy. By default, requests are not retried.
+func SetRetryPolicy(policy RetryPolicy) OptionFunc {
+	return func(c *client) error {
+		if policy.MaxRetries < 0 || policy.MinBackoff < 0 || policy.MaxBackoff < policy.MinBackoff {
+			err := &Error{Code: "bad_retry_policy", Message: fmt.Sprintf("Invalid retry policy %+v", policy)}
+			c.errorf("%v\n", err)
+			return err
+		}
+		c.retry = &policy
+		return nil
+	}
+}
+
+// isIdempotent returns true if the method can be safely repeated
+func isIdempotent(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "PUT", "DELETE":
+		return true
+	}
+	return false
+}
+
+// canResend returns true if the request body can be sent again - there is none or it can be rewound
+func canResend(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// retryWait decides if the request should be retried after the given attempt (0 based) resulted in resp.
+// A nil resp means that the request failed on the transport level.
+// Returns the time to wait before the retry.
+func (c *client) retryWait(req *http.Request, resp *http.Response, attempt int) (time.Duration, bool) {
+	p := c.retry
+	if p == nil || attempt >= p.MaxRetries {
+		return 0, false
+	}
+	if !canResend(req) {
+		return 0, false
+	}
+	// A rate limited request was not processed so it is always safe to resend
+	if resp != nil && resp.StatusCode == http.StatusTooManyRequests {
+		if wait, ok := rateLimitWait(resp); ok {
+			return wait, true
+		}
+		return p.backoff(attempt), true
+	}
+	if !isIdempotent(req.Method) && !p.RetryNonIdempotent {
+		return 0, false
+	}
+	if resp != nil {
+		switch resp.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		default:
+			return 0, false
+		}
+		if wait, ok := retryAfter(resp); ok {
+			return wait, true
+		}
+	}
+	return p.backoff(attempt), true
+}
+
+// backoff returns the jittered exponential backoff for the given attempt
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.MinBackoff
+	for i := 0; i < attempt && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	// Equal jitter - half fixed, half random
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(d-half)+1))
+}
+
+// retryAfter parses the Retry-After header which can be either seconds or an HTTP date
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		wait := time.Until(t)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
+
+// sleepContext waits for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// drainBody reads the rest of the body and closes it so the connection can be reused
+func drainBody(resp *http.Response) {
+	if resp.Body != nil {
+		io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 1<<16))
+		resp.Body.Close()
+	}
+}
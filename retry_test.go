@@ -0,0 +1,214 @@
+package gocs
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fastRetries retries quickly so the tests do not wait for the default backoff
+var fastRetries = RetryPolicy{MaxRetries: 3, MinBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+
+// failingHandler fails the first n requests with status and then succeeds
+func failingHandler(n int32, status int, calls *int32) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(calls, 1) <= n {
+			writeJSON(w, status, map[string]interface{}{"errors": []Error{{Code: "500", Message: "failed"}}})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"resources": []string{"ok"}})
+	}
+}
+
+// onceReader is a body that cannot be rewound
+type onceReader struct {
+	io.Reader
+}
+
+func TestRetryRecovers(t *testing.T) {
+	var calls int32
+	h := newTestHost(t, failingHandler(3, http.StatusServiceUnavailable, &calls), SetRetryPolicy(fastRetries))
+	resp, err := h.SearchIOCs(&SearchIOCsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 4 || len(resp.Resources) != 1 {
+		t.Fatalf("expected 4 calls and a result, got %d calls and %v", calls, resp.Resources)
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	var calls int32
+	h := newTestHost(t, failingHandler(10, http.StatusBadGateway, &calls), SetRetryPolicy(fastRetries))
+	_, err := h.SearchIOCs(&SearchIOCsRequest{})
+	apiErr, ok := err.(*APIError)
+	if !ok || apiErr.StatusCode != http.StatusBadGateway {
+		t.Fatalf("expected a 502 APIError, got %v", err)
+	}
+	if calls != 4 {
+		t.Fatalf("expected 1 attempt and 3 retries, got %d calls", calls)
+	}
+}
+
+func TestNoRetryByDefault(t *testing.T) {
+	var calls int32
+	h := newTestHost(t, failingHandler(1, http.StatusServiceUnavailable, &calls))
+	if _, err := h.SearchIOCs(&SearchIOCsRequest{}); err == nil {
+		t.Fatal("expected an error")
+	}
+	if calls != 1 {
+		t.Fatalf("expected a single call, got %d", calls)
+	}
+}
+
+func TestNoRetryOnClientError(t *testing.T) {
+	var calls int32
+	h := newTestHost(t, failingHandler(1, http.StatusBadRequest, &calls), SetRetryPolicy(fastRetries))
+	if _, err := h.SearchIOCs(&SearchIOCsRequest{}); err == nil {
+		t.Fatal("expected an error")
+	}
+	if calls != 1 {
+		t.Fatalf("expected a single call, got %d", calls)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	var calls int32
+	h := newTestHost(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{})
+	}, SetRetryPolicy(fastRetries))
+	start := time.Now()
+	if _, err := h.SearchIOCs(&SearchIOCsRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < time.Second {
+		t.Fatalf("Retry-After was not honored - retried after %v", d)
+	}
+}
+
+func TestRetryContextCancel(t *testing.T) {
+	var calls int32
+	policy := RetryPolicy{MaxRetries: 3, MinBackoff: time.Minute, MaxBackoff: time.Minute}
+	h := newTestHost(t, failingHandler(10, http.StatusServiceUnavailable, &calls), SetRetryPolicy(policy))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := h.SearchIOCsContext(ctx, &SearchIOCsRequest{}); err != context.DeadlineExceeded {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestRetryNonIdempotentPolicy(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	var calls int32
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, string(b))
+		mu.Unlock()
+		failingHandler(1, http.StatusServiceUnavailable, &calls)(w, r)
+	}
+
+	h := newTestHost(t, handler, SetRetryPolicy(fastRetries))
+	if err := h.do(context.Background(), "POST", "x", nil, strings.NewReader("payload"), nil, h.authFunc()); err == nil {
+		t.Fatal("expected POST not to be retried")
+	}
+	if calls != 1 {
+		t.Fatalf("expected a single call, got %d", calls)
+	}
+
+	calls, bodies = 0, nil
+	policy := fastRetries
+	policy.RetryNonIdempotent = true
+	h = newTestHost(t, handler, SetRetryPolicy(policy))
+	if err := h.do(context.Background(), "POST", "x", nil, strings.NewReader("payload"), nil, h.authFunc()); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 || bodies[0] != "payload" || bodies[1] != "payload" {
+		t.Fatalf("expected the body to be rewound for the retry, got %q", bodies)
+	}
+}
+
+func TestRetryUnrewindableBody(t *testing.T) {
+	var calls int32
+	policy := fastRetries
+	policy.RetryNonIdempotent = true
+	h := newTestHost(t, failingHandler(1, http.StatusServiceUnavailable, &calls), SetRetryPolicy(policy))
+	body := onceReader{strings.NewReader("payload")}
+	if err := h.do(context.Background(), "POST", "x", nil, body, nil, h.authFunc()); err == nil {
+		t.Fatal("expected an error")
+	}
+	if calls != 1 {
+		t.Fatalf("expected a body that cannot be rewound not to be resent, got %d calls", calls)
+	}
+}
+
+func TestThrottledResendRewindsBody(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	var calls int32
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, string(b))
+		mu.Unlock()
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("X-RateLimit-Limit", "10")
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{})
+	}
+
+	h := newTestHost(t, handler, SetRateLimitThrottle(0))
+	if err := h.do(context.Background(), "POST", "x", nil, onceReader{strings.NewReader("payload")}, nil, h.authFunc()); err == nil {
+		t.Fatal("expected the 429 to be returned for a body that cannot be rewound")
+	}
+	if calls != 1 {
+		t.Fatalf("expected a single call, got %d", calls)
+	}
+
+	calls, bodies = 0, nil
+	h = newTestHost(t, handler, SetRateLimitThrottle(0))
+	if err := h.do(context.Background(), "POST", "x", nil, strings.NewReader("payload"), nil, h.authFunc()); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 || bodies[1] != "payload" {
+		t.Fatalf("expected the body to be rewound for the resend, got %q", bodies)
+	}
+}
+
+func TestBackoffBounds(t *testing.T) {
+	p := RetryPolicy{MaxRetries: 10, MinBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	for attempt := 0; attempt < 10; attempt++ {
+		full := p.MinBackoff << uint(attempt)
+		if full > p.MaxBackoff {
+			full = p.MaxBackoff
+		}
+		for i := 0; i < 20; i++ {
+			if d := p.backoff(attempt); d < full/2 || d > full {
+				t.Fatalf("attempt %d: backoff %v out of [%v, %v]", attempt, d, full/2, full)
+			}
+		}
+	}
+}
+
+func TestInvalidRetryPolicy(t *testing.T) {
+	_, err := NewHost(SetCredentials("id", "key"), SetRetryPolicy(RetryPolicy{MinBackoff: time.Second, MaxBackoff: time.Millisecond}))
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+}
@@ -0,0 +1,523 @@
+package gocs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RTR command levels. Each level allows the commands of the previous levels.
+const (
+	RTRReadOnly        = "command"                  // ls, ps, netstat, reg query ...
+	RTRActiveResponder = "active-responder-command" // get, kill, rm, reg set ...
+	RTRAdmin           = "admin-command"            // put, run, runscript ...
+)
+
+const (
+	// DefaultRTRRefreshInterval keeps the sessions alive as they expire after 10 minutes of inactivity
+	DefaultRTRRefreshInterval = 5 * time.Minute
+	// DefaultRTRPollInterval is how often the status of a command is checked
+	DefaultRTRPollInterval = time.Second
+	// rtrCleanupTimeout bounds the time for deleting a session
+	rtrCleanupTimeout = 30 * time.Second
+)
+
+// RTRSessionOptions control the creation and lifetime of RTR sessions
+type RTRSessionOptions struct {
+	QueueOffline    bool          // Queue the commands if the host is offline
+	Origin          string        // Optional origin for the audit log
+	RefreshInterval time.Duration // How often to keep the session alive. Defaults to DefaultRTRRefreshInterval, negative disables.
+	PollInterval    time.Duration // How often to check the command status. Defaults to DefaultRTRPollInterval.
+}
+
+// RTRCommandResult is the result of an RTR command
+type RTRCommandResult struct {
+	SessionID   string  `json:"session_id"`
+	TaskID      string  `json:"task_id"`
+	AgentID     string  `json:"aid"` // Only for batch commands
+	BaseCommand string  `json:"base_command"`
+	SequenceID  int     `json:"sequence_id"`
+	Complete    bool    `json:"complete"`
+	Stdout      string  `json:"stdout"`
+	Stderr      string  `json:"stderr"`
+	Errors      []Error `json:"errors"`
+}
+
+// rtrRegistry tracks the open sessions of a Host so they are cleaned up on Close
+type rtrRegistry struct {
+	mu       sync.Mutex
+	sessions map[rtrCloser]struct{}
+}
+
+// rtrCloser is implemented by sessions and batches
+type rtrCloser interface {
+	Close() error
+}
+
+func newRTRRegistry() *rtrRegistry {
+	return &rtrRegistry{sessions: make(map[rtrCloser]struct{})}
+}
+
+func (r *rtrRegistry) add(s rtrCloser) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sessions[s] = struct{}{}
+}
+
+func (r *rtrRegistry) remove(s rtrCloser) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.sessions, s)
+}
+
+func (r *rtrRegistry) closeAll() {
+	r.mu.Lock()
+	open := make([]rtrCloser, 0, len(r.sessions))
+	for s := range r.sessions {
+		open = append(open, s)
+	}
+	r.mu.Unlock()
+	for _, s := range open {
+		s.Close()
+	}
+}
+
+// keepAlive calls refresh every interval until stop is closed
+type keepAlive struct {
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+func startKeepAlive(interval time.Duration, refresh func(ctx context.Context) error, errorf func(string, ...interface{})) *keepAlive {
+	k := &keepAlive{stop: make(chan struct{}), done: make(chan struct{})}
+	if interval < 0 {
+		close(k.done)
+		return k
+	}
+	go func() {
+		defer close(k.done)
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-k.stop:
+				return
+			case <-t.C:
+				ctx, cancel := context.WithTimeout(context.Background(), rtrCleanupTimeout)
+				if err := refresh(ctx); err != nil {
+					errorf("Failed to refresh session - %v\n", err)
+				}
+				cancel()
+			}
+		}
+	}()
+	return k
+}
+
+// close stops the refresh and waits for it to finish. It returns true on the first call.
+func (k *keepAlive) close() bool {
+	first := false
+	k.once.Do(func() {
+		first = true
+		close(k.stop)
+	})
+	<-k.done
+	return first
+}
+
+// RTRSession is a Real Time Response session with a single host.
+// The session is kept alive in the background until it is closed.
+type RTRSession struct {
+	ID       string    // The session ID
+	DeviceID string    // The host of the session
+	PWD      string    // Working directory when the session was created
+	Created  time.Time // When the session was created
+	Offline  bool      // Commands are queued until the host is online
+	h        *Host
+	opts     RTRSessionOptions
+	keep     *keepAlive
+}
+
+// rtrSessionResponse is returned when creating or refreshing a session
+type rtrSessionResponse struct {
+	Meta struct {
+		QueryTime float64 `json:"query_time"`
+		TraceID   string  `json:"trace_id"`
+	} `json:"meta"`
+	Resources []struct {
+		SessionID     string    `json:"session_id"`
+		PWD           string    `json:"pwd"`
+		CreatedAt     timestamp `json:"created_at"`
+		OfflineQueued bool      `json:"offline_queued"`
+	} `json:"resources"`
+	Errors []Error `json:"errors"`
+}
+
+// jsonBody encodes v as a JSON request body
+func jsonBody(v interface{}) (*bytes.Buffer, error) {
+	var b bytes.Buffer
+	err := json.NewEncoder(&b).Encode(v)
+	return &b, err
+}
+
+// rtrDefaults fills the zero options with the defaults
+func rtrDefaults(opts *RTRSessionOptions) RTRSessionOptions {
+	o := RTRSessionOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.RefreshInterval == 0 {
+		o.RefreshInterval = DefaultRTRRefreshInterval
+	}
+	if o.PollInterval <= 0 {
+		o.PollInterval = DefaultRTRPollInterval
+	}
+	return o
+}
+
+// firstError returns the first of the errors in an otherwise successful response
+func firstError(errs []Error) error {
+	if len(errs) > 0 {
+		return &errs[0]
+	}
+	return nil
+}
+
+// InitSession opens an RTR session with the host. Close the session when done, or close the Host
+// to close all of its sessions.
+func (h *Host) InitSession(deviceID string, opts *RTRSessionOptions) (*RTRSession, error) {
+	return h.InitSessionContext(context.Background(), deviceID, opts)
+}
+
+// InitSessionContext is like InitSession with a context for cancellation and deadlines
+func (h *Host) InitSessionContext(ctx context.Context, deviceID string, opts *RTRSessionOptions) (*RTRSession, error) {
+	if deviceID == "" {
+		return nil, ErrMissingParams
+	}
+	o := rtrDefaults(opts)
+	b, err := jsonBody(struct {
+		DeviceID     string `json:"device_id"`
+		Origin       string `json:"origin,omitempty"`
+		QueueOffline bool   `json:"queue_offline"`
+	}{DeviceID: deviceID, Origin: o.Origin, QueueOffline: o.QueueOffline})
+	if err != nil {
+		return nil, err
+	}
+	resp := &rtrSessionResponse{}
+	if err = h.do(ctx, "POST", "real-time-response/entities/sessions/v1", nil, b, resp, h.authFunc()); err != nil {
+		return nil, err
+	}
+	if err = firstError(resp.Errors); err != nil {
+		return nil, err
+	}
+	if len(resp.Resources) == 0 || resp.Resources[0].SessionID == "" {
+		return nil, &Error{Code: "rtr_error", Message: fmt.Sprintf("No session was created for device [%s]", deviceID)}
+	}
+	r := &resp.Resources[0]
+	s := &RTRSession{ID: r.SessionID, DeviceID: deviceID, PWD: r.PWD, Created: r.CreatedAt.Time, Offline: r.OfflineQueued, h: h, opts: o}
+	s.keep = startKeepAlive(o.RefreshInterval, s.RefreshContext, h.errorf)
+	h.sessions.add(s)
+	return s, nil
+}
+
+// Refresh keeps the session alive. It is called automatically in the background.
+func (s *RTRSession) Refresh() error {
+	return s.RefreshContext(context.Background())
+}
+
+// RefreshContext is like Refresh with a context for cancellation and deadlines
+func (s *RTRSession) RefreshContext(ctx context.Context) error {
+	b, err := jsonBody(struct {
+		DeviceID     string `json:"device_id"`
+		QueueOffline bool   `json:"queue_offline"`
+	}{DeviceID: s.DeviceID, QueueOffline: s.opts.QueueOffline})
+	if err != nil {
+		return err
+	}
+	resp := &rtrSessionResponse{}
+	if err = s.h.do(ctx, "POST", "real-time-response/entities/refresh-session/v1", nil, b, resp, s.h.authFunc()); err != nil {
+		return err
+	}
+	return firstError(resp.Errors)
+}
+
+// Close stops refreshing the session and deletes it
+func (s *RTRSession) Close() error {
+	if !s.keep.close() {
+		return nil
+	}
+	s.h.sessions.remove(s)
+	ctx, cancel := context.WithTimeout(context.Background(), rtrCleanupTimeout)
+	defer cancel()
+	return s.h.DeleteSessionContext(ctx, s.ID)
+}
+
+// DeleteSession deletes the RTR session with the given ID
+func (h *Host) DeleteSession(sessionID string) error {
+	return h.DeleteSessionContext(context.Background(), sessionID)
+}
+
+// DeleteSessionContext is like DeleteSession with a context for cancellation and deadlines
+func (h *Host) DeleteSessionContext(ctx context.Context, sessionID string) error {
+	if sessionID == "" {
+		return ErrMissingParams
+	}
+	return h.do(ctx, "DELETE", "real-time-response/entities/sessions/v1", url.Values{"session_id": {sessionID}}, nil, nil, h.authFunc())
+}
+
+// rtrCommandResponse is returned when submitting a command and when checking its status
+type rtrCommandResponse struct {
+	Meta struct {
+		QueryTime float64 `json:"query_time"`
+		TraceID   string  `json:"trace_id"`
+	} `json:"meta"`
+	Resources []struct {
+		RTRCommandResult
+		CloudRequestID string `json:"cloud_request_id"`
+	} `json:"resources"`
+	Errors []Error `json:"errors"`
+}
+
+// baseCommand returns the first word of the command
+func baseCommand(command string) string {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+// Submit sends the command with the given level (see the RTR constants) and returns the cloud
+// request ID for checking its status with CommandStatus
+func (s *RTRSession) Submit(level, command string) (string, error) {
+	return s.SubmitContext(context.Background(), level, command)
+}
+
+// SubmitContext is like Submit with a context for cancellation and deadlines
+func (s *RTRSession) SubmitContext(ctx context.Context, level, command string) (string, error) {
+	base := baseCommand(command)
+	if base == "" || level == "" {
+		return "", ErrMissingParams
+	}
+	b, err := jsonBody(struct {
+		BaseCommand   string `json:"base_command"`
+		CommandString string `json:"command_string"`
+		SessionID     string `json:"session_id"`
+	}{BaseCommand: base, CommandString: command, SessionID: s.ID})
+	if err != nil {
+		return "", err
+	}
+	resp := &rtrCommandResponse{}
+	if err = s.h.do(ctx, "POST", "real-time-response/entities/"+level+"/v1", nil, b, resp, s.h.authFunc()); err != nil {
+		return "", err
+	}
+	if err = firstError(resp.Errors); err != nil {
+		return "", err
+	}
+	if len(resp.Resources) == 0 || resp.Resources[0].CloudRequestID == "" {
+		return "", &Error{Code: "rtr_error", Message: fmt.Sprintf("Command [%s] was not accepted", base)}
+	}
+	return resp.Resources[0].CloudRequestID, nil
+}
+
+// CommandStatus returns the status of a command submitted with the given level
+func (h *Host) CommandStatus(level, cloudRequestID string, sequenceID int) (*RTRCommandResult, error) {
+	return h.CommandStatusContext(context.Background(), level, cloudRequestID, sequenceID)
+}
+
+// CommandStatusContext is like CommandStatus with a context for cancellation and deadlines
+func (h *Host) CommandStatusContext(ctx context.Context, level, cloudRequestID string, sequenceID int) (*RTRCommandResult, error) {
+	if level == "" || cloudRequestID == "" {
+		return nil, ErrMissingParams
+	}
+	params := url.Values{"cloud_request_id": {cloudRequestID}}
+	addInt("sequence_id", sequenceID, params)
+	resp := &rtrCommandResponse{}
+	if err := h.do(ctx, "GET", "real-time-response/entities/"+level+"/v1", params, nil, resp, h.authFunc()); err != nil {
+		return nil, err
+	}
+	if err := firstError(resp.Errors); err != nil {
+		return nil, err
+	}
+	if len(resp.Resources) == 0 {
+		return nil, &Error{Code: "rtr_error", Message: fmt.Sprintf("No status for command [%s]", cloudRequestID)}
+	}
+	return &resp.Resources[0].RTRCommandResult, nil
+}
+
+// Run submits the command with the given level and waits for it to complete. Large outputs are
+// returned in several chunks by the API - the result has the output of all of them.
+func (s *RTRSession) Run(level, command string) (*RTRCommandResult, error) {
+	return s.RunContext(context.Background(), level, command)
+}
+
+// RunContext is like Run with a context for cancellation and deadlines
+func (s *RTRSession) RunContext(ctx context.Context, level, command string) (*RTRCommandResult, error) {
+	id, err := s.SubmitContext(ctx, level, command)
+	if err != nil {
+		return nil, err
+	}
+	var stdout, stderr strings.Builder
+	seq := 0
+	for {
+		res, err := s.h.CommandStatusContext(ctx, level, id, seq)
+		if err != nil {
+			return nil, err
+		}
+		stdout.WriteString(res.Stdout)
+		stderr.WriteString(res.Stderr)
+		if res.Complete {
+			res.Stdout, res.Stderr = stdout.String(), stderr.String()
+			return res, nil
+		}
+		// A chunk with output is done - move on to the next one. Otherwise, it is not ready yet.
+		if res.Stdout != "" || res.Stderr != "" {
+			seq++
+			continue
+		}
+		if err = sleepContext(ctx, s.opts.PollInterval); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// RTRBatch is a Real Time Response session with several hosts.
+// The batch is kept alive in the background until it is closed.
+type RTRBatch struct {
+	ID       string                      // The batch ID
+	Sessions map[string]RTRCommandResult // The session of each host by device ID
+	h        *Host
+	opts     RTRSessionOptions
+	keep     *keepAlive
+}
+
+// rtrBatchResponse is returned from the batch APIs
+type rtrBatchResponse struct {
+	Meta struct {
+		QueryTime float64 `json:"query_time"`
+		TraceID   string  `json:"trace_id"`
+	} `json:"meta"`
+	BatchID   string                      `json:"batch_id"`
+	Resources map[string]RTRCommandResult `json:"resources"`
+	Combined  struct {
+		Resources map[string]RTRCommandResult `json:"resources"`
+	} `json:"combined"`
+	Errors []Error `json:"errors"`
+}
+
+// InitBatchSession opens an RTR session with several hosts. Hosts that failed to connect have
+// errors in their Sessions entry.
+func (h *Host) InitBatchSession(deviceIDs []string, opts *RTRSessionOptions) (*RTRBatch, error) {
+	return h.InitBatchSessionContext(context.Background(), deviceIDs, opts)
+}
+
+// InitBatchSessionContext is like InitBatchSession with a context for cancellation and deadlines
+func (h *Host) InitBatchSessionContext(ctx context.Context, deviceIDs []string, opts *RTRSessionOptions) (*RTRBatch, error) {
+	if len(deviceIDs) == 0 {
+		return nil, ErrMissingParams
+	}
+	o := rtrDefaults(opts)
+	b, err := jsonBody(struct {
+		HostIDs      []string `json:"host_ids"`
+		QueueOffline bool     `json:"queue_offline"`
+	}{HostIDs: deviceIDs, QueueOffline: o.QueueOffline})
+	if err != nil {
+		return nil, err
+	}
+	resp := &rtrBatchResponse{}
+	if err = h.do(ctx, "POST", "real-time-response/combined/batch-init-session/v1", nil, b, resp, h.authFunc()); err != nil {
+		return nil, err
+	}
+	if resp.BatchID == "" {
+		if err = firstError(resp.Errors); err != nil {
+			return nil, err
+		}
+		return nil, &Error{Code: "rtr_error", Message: "No batch session was created"}
+	}
+	batch := &RTRBatch{ID: resp.BatchID, Sessions: resp.Resources, h: h, opts: o}
+	batch.keep = startKeepAlive(o.RefreshInterval, batch.RefreshContext, h.errorf)
+	h.sessions.add(batch)
+	return batch, nil
+}
+
+// Refresh keeps the batch alive. It is called automatically in the background.
+func (b *RTRBatch) Refresh() error {
+	return b.RefreshContext(context.Background())
+}
+
+// RefreshContext is like Refresh with a context for cancellation and deadlines
+func (b *RTRBatch) RefreshContext(ctx context.Context) error {
+	body, err := jsonBody(struct {
+		BatchID string `json:"batch_id"`
+	}{BatchID: b.ID})
+	if err != nil {
+		return err
+	}
+	resp := &rtrBatchResponse{}
+	if err = b.h.do(ctx, "POST", "real-time-response/combined/batch-refresh-session/v1", nil, body, resp, b.h.authFunc()); err != nil {
+		return err
+	}
+	return firstError(resp.Errors)
+}
+
+// Run runs the command with the given level on all the hosts of the batch and returns the
+// result of each host by device ID. timeout bounds how long the API waits for the hosts.
+func (b *RTRBatch) Run(level, command string, timeout time.Duration) (map[string]RTRCommandResult, error) {
+	return b.RunContext(context.Background(), level, command, timeout)
+}
+
+// RunContext is like Run with a context for cancellation and deadlines
+func (b *RTRBatch) RunContext(ctx context.Context, level, command string, timeout time.Duration) (map[string]RTRCommandResult, error) {
+	base := baseCommand(command)
+	if base == "" || level == "" {
+		return nil, ErrMissingParams
+	}
+	body, err := jsonBody(struct {
+		BaseCommand   string `json:"base_command"`
+		CommandString string `json:"command_string"`
+		BatchID       string `json:"batch_id"`
+	}{BaseCommand: base, CommandString: command, BatchID: b.ID})
+	if err != nil {
+		return nil, err
+	}
+	params := url.Values{}
+	if timeout > 0 {
+		addInt("timeout", int(timeout/time.Second), params)
+	}
+	resp := &rtrBatchResponse{}
+	if err = b.h.do(ctx, "POST", "real-time-response/combined/batch-"+level+"/v1", params, body, resp, b.h.authFunc()); err != nil {
+		return nil, err
+	}
+	if len(resp.Combined.Resources) == 0 {
+		if err = firstError(resp.Errors); err != nil {
+			return nil, err
+		}
+	}
+	return resp.Combined.Resources, nil
+}
+
+// Close stops refreshing the batch and deletes the sessions of its hosts
+func (b *RTRBatch) Close() error {
+	if !b.keep.close() {
+		return nil
+	}
+	b.h.sessions.remove(b)
+	ctx, cancel := context.WithTimeout(context.Background(), rtrCleanupTimeout)
+	defer cancel()
+	var firstErr error
+	for _, s := range b.Sessions {
+		if s.SessionID == "" {
+			continue
+		}
+		if err := b.h.DeleteSessionContext(ctx, s.SessionID); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
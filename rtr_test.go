@@ -0,0 +1,144 @@
+package gocs
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// rtrHandler serves the session creation and deletion and passes the other requests to commands
+func rtrHandler(t *testing.T, commands http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/real-time-response/entities/sessions/v1":
+			if r.Method == "DELETE" {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			writeJSON(w, http.StatusCreated, map[string]interface{}{"resources": []map[string]interface{}{
+				{"session_id": "s1", "pwd": `C:\`, "created_at": "2020-03-01T10:00:00Z"},
+			}})
+		default:
+			commands(w, r)
+		}
+	}
+}
+
+// newTestSession opens a session without background refresh on a test host
+func newTestSession(t *testing.T, commands http.HandlerFunc) *RTRSession {
+	t.Helper()
+	h := newTestHost(t, rtrHandler(t, commands))
+	s, err := h.InitSession("dev1", &RTRSessionOptions{RefreshInterval: -1, PollInterval: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestInitSession(t *testing.T) {
+	s := newTestSession(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request %s", r.URL)
+	})
+	if s.ID != "s1" || s.DeviceID != "dev1" || s.PWD != `C:\` || s.Created.Year() != 2020 {
+		t.Fatalf("unexpected session %+v", s)
+	}
+}
+
+func TestRunConcatenatesChunks(t *testing.T) {
+	chunks := []RTRCommandResult{
+		{SequenceID: 0, Stdout: "first "},
+		{SequenceID: 1, Stdout: "second ", Stderr: "warning"},
+		{SequenceID: 2, Stdout: "third", Complete: true},
+	}
+	var sequences []int
+	polls := 0
+	s := newTestSession(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/real-time-response/entities/command/v1" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if r.Method == "POST" {
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			if body["base_command"] != "ls" || body["command_string"] != "ls -l" || body["session_id"] != "s1" {
+				t.Errorf("unexpected command %v", body)
+			}
+			writeJSON(w, http.StatusCreated, map[string]interface{}{"resources": []map[string]string{{"cloud_request_id": "c1"}}})
+			return
+		}
+		seq, _ := strconv.Atoi(r.URL.Query().Get("sequence_id"))
+		sequences = append(sequences, seq)
+		// The first poll is before the command has any output
+		polls++
+		res := RTRCommandResult{SequenceID: seq}
+		if polls > 1 {
+			res = chunks[seq]
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"resources": []RTRCommandResult{res}})
+	})
+	res, err := s.Run(RTRReadOnly, "ls -l")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Stdout != "first second third" || res.Stderr != "warning" || !res.Complete {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	if len(sequences) != 4 || sequences[0] != 0 || sequences[1] != 0 || sequences[3] != 2 {
+		t.Fatalf("unexpected sequences %v", sequences)
+	}
+}
+
+func TestRunCommandErrors(t *testing.T) {
+	s := newTestSession(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"errors": []Error{{Code: "40007", Message: "Command not found"}}})
+	})
+	_, err := s.Run(RTRReadOnly, "nosuch")
+	if e, ok := err.(*Error); !ok || e.Code != "40007" {
+		t.Fatalf("expected the command error, got %v", err)
+	}
+	if _, err = s.Run(RTRReadOnly, " "); err != ErrMissingParams {
+		t.Fatalf("expected ErrMissingParams, got %v", err)
+	}
+}
+
+func TestCloseSessions(t *testing.T) {
+	deleted := 0
+	h := newTestHost(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "DELETE" {
+			deleted++
+			if r.URL.Query().Get("session_id") != "s1" {
+				t.Errorf("unexpected session %s", r.URL)
+			}
+		}
+		rtrHandler(t, func(w http.ResponseWriter, r *http.Request) {
+			t.Errorf("unexpected request %s", r.URL)
+		})(w, r)
+	})
+	s, err := h.InitSession("dev1", &RTRSessionOptions{RefreshInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Close()
+	if err = s.Close(); err != nil || deleted != 1 {
+		t.Fatalf("expected the session to be deleted once, got %d - %v", deleted, err)
+	}
+}
+
+func TestInitSessionEpoch(t *testing.T) {
+	h := newTestHost(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "DELETE" {
+			return
+		}
+		w.Write([]byte(`{"resources":[{"session_id":"s1","created_at":1583056800}]}`))
+	})
+	s, err := h.InitSession("dev1", &RTRSessionOptions{RefreshInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if s.Created.Unix() != 1583056800 {
+		t.Fatalf("unexpected creation time %v", s.Created)
+	}
+}
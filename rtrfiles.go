@@ -0,0 +1,252 @@
+package gocs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// RTRArchivePassword is the password of the archives holding the retrieved files
+const RTRArchivePassword = "infected"
+
+// DefaultRTRFileTimeout is how long to wait for the upload of a file from the host
+const DefaultRTRFileTimeout = 10 * time.Minute
+
+// ErrFileTimeout is returned when the file was not uploaded from the host in time
+var ErrFileTimeout = &Error{Code: "file_timeout", Message: "The file was not uploaded from the host in time"}
+
+// RTRFile is a file retrieved from a host with the get command
+type RTRFile struct {
+	ID             string    `json:"id"`
+	CloudRequestID string    `json:"cloud_request_id"`
+	SessionID      string    `json:"session_id"`
+	Name           string    `json:"name"` // Full path of the file on the host
+	SHA256         string    `json:"sha256"`
+	Size           int64     `json:"size"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// UnmarshalJSON decodes the dates from epoch seconds or RFC3339 strings
+func (f *RTRFile) UnmarshalJSON(b []byte) error {
+	type rtrFile RTRFile
+	aux := &struct {
+		*rtrFile
+		CreatedAt timestamp `json:"created_at"`
+		UpdatedAt timestamp `json:"updated_at"`
+	}{rtrFile: (*rtrFile)(f)}
+	if err := json.Unmarshal(b, aux); err != nil {
+		return err
+	}
+	f.CreatedAt, f.UpdatedAt = aux.CreatedAt.Time, aux.UpdatedAt.Time
+	return nil
+}
+
+// rtrFilesResponse is returned from the file listing
+type rtrFilesResponse struct {
+	Meta struct {
+		QueryTime float64 `json:"query_time"`
+		TraceID   string  `json:"trace_id"`
+	} `json:"meta"`
+	Resources []RTRFile `json:"resources"`
+	Errors    []Error   `json:"errors"`
+}
+
+// ArchiveExtractor writes the single file in the password protected archive to w
+type ArchiveExtractor func(archive io.Reader, password string, w io.Writer) error
+
+// SevenZipExtractor extracts the archive using the 7z command which must be in the PATH.
+// The archive is spooled to a temporary file as 7z cannot read it from a stream.
+func SevenZipExtractor(archive io.Reader, password string, w io.Writer) error {
+	f, err := ioutil.TempFile("", "gocs-*.7z")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	_, err = io.Copy(f, archive)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	var stderr bytes.Buffer
+	cmd := exec.Command("7z", "e", "-so", "-p"+password, f.Name())
+	cmd.Stdout, cmd.Stderr = w, &stderr
+	if err = cmd.Run(); err != nil {
+		return &Error{Code: "extract_error", Message: fmt.Sprintf("7z failed - %v %s", err, stderr.String())}
+	}
+	return nil
+}
+
+// RTRGetFileOptions control the retrieval of a file
+type RTRGetFileOptions struct {
+	Extract   bool             // Unwrap the archive and write the file itself
+	Extractor ArchiveExtractor // Used when Extract is set. Defaults to SevenZipExtractor.
+	Timeout   time.Duration    // How long to wait for the upload from the host. Defaults to DefaultRTRFileTimeout, negative waits until the context is done.
+}
+
+// ListFiles returns the files retrieved in the session
+func (s *RTRSession) ListFiles() ([]RTRFile, error) {
+	return s.ListFilesContext(context.Background())
+}
+
+// ListFilesContext is like ListFiles with a context for cancellation and deadlines
+func (s *RTRSession) ListFilesContext(ctx context.Context) ([]RTRFile, error) {
+	resp := &rtrFilesResponse{}
+	err := s.h.do(ctx, "GET", "real-time-response/entities/file/v2", url.Values{"session_id": {s.ID}}, nil, resp, s.h.authFunc())
+	if err != nil {
+		return nil, err
+	}
+	if err = firstError(resp.Errors); err != nil {
+		return nil, err
+	}
+	return resp.Resources, nil
+}
+
+// DeleteFile deletes the retrieved file from the session
+func (s *RTRSession) DeleteFile(fileID string) error {
+	return s.DeleteFileContext(context.Background(), fileID)
+}
+
+// DeleteFileContext is like DeleteFile with a context for cancellation and deadlines
+func (s *RTRSession) DeleteFileContext(ctx context.Context, fileID string) error {
+	if fileID == "" {
+		return ErrMissingParams
+	}
+	params := url.Values{"ids": {fileID}, "session_id": {s.ID}}
+	return s.h.do(ctx, "DELETE", "real-time-response/entities/file/v1", params, nil, nil, s.h.authFunc())
+}
+
+// DownloadFile streams the password protected 7z archive holding the retrieved file to w
+func (h *Host) DownloadFile(sessionID, sha256, filename string, w io.Writer) error {
+	return h.DownloadFileContext(context.Background(), sessionID, sha256, filename, w)
+}
+
+// DownloadFileContext is like DownloadFile with a context for cancellation and deadlines
+func (h *Host) DownloadFileContext(ctx context.Context, sessionID, sha256, filename string, w io.Writer) error {
+	if sessionID == "" || sha256 == "" {
+		return ErrMissingParams
+	}
+	params := url.Values{"session_id": {sessionID}, "sha256": {sha256}}
+	addString("filename", filename, params)
+	header := http.Header{"Accept": {"application/x-7z-compressed"}}
+	return h.stream(ctx, "GET", "real-time-response/entities/extracted-file-contents/v1", params, nil, header, w, h.authFunc())
+}
+
+// GetFile retrieves the file at path from the host and streams it to w. It runs the get command,
+// waits for the upload from the host to complete and downloads the file. The 7z archive
+// is written as is unless opts.Extract is set.
+func (s *RTRSession) GetFile(path string, w io.Writer, opts *RTRGetFileOptions) (*RTRFile, error) {
+	return s.GetFileContext(context.Background(), path, w, opts)
+}
+
+// GetFileContext is like GetFile with a context for cancellation and deadlines
+func (s *RTRSession) GetFileContext(ctx context.Context, path string, w io.Writer, opts *RTRGetFileOptions) (*RTRFile, error) {
+	if path == "" || w == nil {
+		return nil, ErrMissingParams
+	}
+	if opts == nil {
+		opts = &RTRGetFileOptions{}
+	}
+	arg, err := quoteRTRArg(path)
+	if err != nil {
+		return nil, err
+	}
+	id, err := s.SubmitContext(ctx, RTRActiveResponder, "get "+arg)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		res, err := s.h.CommandStatusContext(ctx, RTRActiveResponder, id, 0)
+		if err != nil {
+			return nil, err
+		}
+		if res.Complete {
+			if res.Stderr != "" {
+				return nil, &Error{Code: "rtr_error", Message: res.Stderr}
+			}
+			break
+		}
+		if err = sleepContext(ctx, s.opts.PollInterval); err != nil {
+			return nil, err
+		}
+	}
+	// The command completes before the file is uploaded from the host
+	timeout := opts.Timeout
+	if timeout == 0 {
+		timeout = DefaultRTRFileTimeout
+	}
+	file, err := s.waitForFile(ctx, id, timeout)
+	if err != nil {
+		return nil, err
+	}
+	if !opts.Extract {
+		return file, s.h.DownloadFileContext(ctx, s.ID, file.SHA256, "", w)
+	}
+	extract := opts.Extractor
+	if extract == nil {
+		extract = SevenZipExtractor
+	}
+	pr, pw := io.Pipe()
+	downloaded := make(chan error, 1)
+	go func() {
+		err := s.h.DownloadFileContext(ctx, s.ID, file.SHA256, "", pw)
+		pw.CloseWithError(err)
+		downloaded <- err
+	}()
+	err = extract(pr, RTRArchivePassword, w)
+	// Unblock the download if the extractor did not read everything
+	pr.CloseWithError(err)
+	if downloadErr := <-downloaded; err == nil && downloadErr != io.ErrClosedPipe {
+		err = downloadErr
+	}
+	return file, err
+}
+
+// waitForFile polls the session files until the file of the get command is uploaded. It returns
+// ErrFileTimeout if the file is not there after timeout.
+func (s *RTRSession) waitForFile(ctx context.Context, cloudRequestID string, timeout time.Duration) (*RTRFile, error) {
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	for {
+		files, err := s.ListFilesContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for i := range files {
+			if files[i].CloudRequestID == cloudRequestID && files[i].SHA256 != "" {
+				return &files[i], nil
+			}
+		}
+		if !deadline.IsZero() && time.Now().Add(s.opts.PollInterval).After(deadline) {
+			return nil, ErrFileTimeout
+		}
+		if err = sleepContext(ctx, s.opts.PollInterval); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// quoteRTRArg quotes an RTR command argument that contains spaces. RTR has no way to escape
+// quotes, so arguments with quotes are rejected.
+func quoteRTRArg(arg string) (string, error) {
+	if strings.ContainsRune(arg, '"') {
+		return "", &Error{Code: "invalid_argument", Message: fmt.Sprintf("RTR arguments cannot contain quotes [%s]", arg)}
+	}
+	if strings.ContainsAny(arg, " \t") {
+		return "\"" + arg + "\"", nil
+	}
+	return arg, nil
+}
@@ -0,0 +1,129 @@
+package gocs
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// getFileHandler runs the get command and lists the file after polls listings
+func getFileHandler(t *testing.T, polls int, command *string) http.HandlerFunc {
+	listed := 0
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/real-time-response/entities/active-responder-command/v1":
+			if r.Method == "POST" {
+				var body map[string]string
+				json.NewDecoder(r.Body).Decode(&body)
+				*command = body["command_string"]
+				writeJSON(w, http.StatusCreated, map[string]interface{}{"resources": []map[string]string{{"cloud_request_id": "c1"}}})
+				return
+			}
+			writeJSON(w, http.StatusOK, map[string]interface{}{"resources": []RTRCommandResult{{Complete: true}}})
+		case "/real-time-response/entities/file/v2":
+			listed++
+			files := []map[string]interface{}{{"id": "other", "cloud_request_id": "c0", "sha256": "old"}}
+			if listed > polls {
+				files = append(files, map[string]interface{}{"id": "f1", "cloud_request_id": "c1", "sha256": "abc", "name": `C:\a b.txt`, "created_at": "2020-03-01T10:00:00Z"})
+			}
+			writeJSON(w, http.StatusOK, map[string]interface{}{"resources": files})
+		case "/real-time-response/entities/extracted-file-contents/v1":
+			if r.URL.Query().Get("sha256") != "abc" || r.URL.Query().Get("session_id") != "s1" {
+				t.Errorf("unexpected download %s", r.URL)
+			}
+			w.Write([]byte("archive"))
+		default:
+			t.Errorf("unexpected request %s", r.URL)
+		}
+	}
+}
+
+func TestGetFile(t *testing.T) {
+	var command string
+	s := newTestSession(t, getFileHandler(t, 2, &command))
+	var b bytes.Buffer
+	file, err := s.GetFile(`C:\a b.txt`, &b, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if command != `get "C:\a b.txt"` {
+		t.Fatalf("unexpected command %s", command)
+	}
+	if file.ID != "f1" || file.CreatedAt.Year() != 2020 || b.String() != "archive" {
+		t.Fatalf("unexpected file %+v %q", file, b.String())
+	}
+}
+
+func TestGetFileExtract(t *testing.T) {
+	var command string
+	s := newTestSession(t, getFileHandler(t, 0, &command))
+	extractor := func(archive io.Reader, password string, w io.Writer) error {
+		b, err := ioutil.ReadAll(archive)
+		if err != nil || string(b) != "archive" || password != RTRArchivePassword {
+			t.Errorf("unexpected archive %q %s - %v", b, password, err)
+		}
+		_, err = w.Write([]byte("content"))
+		return err
+	}
+	var b bytes.Buffer
+	if _, err := s.GetFile(`C:\a.txt`, &b, &RTRGetFileOptions{Extract: true, Extractor: extractor}); err != nil {
+		t.Fatal(err)
+	}
+	if command != `get C:\a.txt` || b.String() != "content" {
+		t.Fatalf("unexpected command %s or content %q", command, b.String())
+	}
+}
+
+func TestGetFileExtractError(t *testing.T) {
+	var command string
+	s := newTestSession(t, getFileHandler(t, 0, &command))
+	// The extractor fails without reading the archive
+	failed := &Error{Code: "extract_error", Message: "bad archive"}
+	extractor := func(io.Reader, string, io.Writer) error { return failed }
+	if _, err := s.GetFile(`C:\a.txt`, ioutil.Discard, &RTRGetFileOptions{Extract: true, Extractor: extractor}); err != failed {
+		t.Fatalf("expected the extractor error, got %v", err)
+	}
+}
+
+func TestGetFileTimeout(t *testing.T) {
+	var command string
+	s := newTestSession(t, getFileHandler(t, 1000, &command))
+	start := time.Now()
+	_, err := s.GetFile(`C:\a.txt`, ioutil.Discard, &RTRGetFileOptions{Timeout: 20 * time.Millisecond})
+	if err != ErrFileTimeout {
+		t.Fatalf("expected ErrFileTimeout, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Fatalf("the wait was not bounded - %v", time.Since(start))
+	}
+}
+
+func TestQuoteRTRArg(t *testing.T) {
+	for arg, expected := range map[string]string{
+		`C:\a.txt`:     `C:\a.txt`,
+		`C:\a b.txt`:   `"C:\a b.txt"`,
+		"C:\\a\tb.txt": "\"C:\\a\tb.txt\"",
+	} {
+		if quoted, err := quoteRTRArg(arg); err != nil || quoted != expected {
+			t.Fatalf("unexpected quoting of %s - %s %v", arg, quoted, err)
+		}
+	}
+	if _, err := quoteRTRArg(`C:\a" & rm x`); err == nil || !strings.Contains(err.Error(), "quotes") {
+		t.Fatalf("expected quotes to be rejected, got %v", err)
+	}
+}
+
+func TestRTRFileTimestamps(t *testing.T) {
+	var f RTRFile
+	if err := json.Unmarshal([]byte(`{"id":"f1","created_at":1583056800,"updated_at":""}`), &f); err != nil {
+		t.Fatal(err)
+	}
+	if f.ID != "f1" || f.CreatedAt.Unix() != 1583056800 || !f.UpdatedAt.IsZero() {
+		t.Fatalf("unexpected file %+v", f)
+	}
+}
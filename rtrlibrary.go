@@ -0,0 +1,282 @@
+package gocs
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Script permission types
+const (
+	ScriptPermissionPrivate = "private" // Only the creator can use the script
+	ScriptPermissionGroup   = "group"   // RTR admins can use the script
+	ScriptPermissionPublic  = "public"  // RTR admins and active responders can use the script
+)
+
+// RTRLibraryFile is a put-file or a custom script in the RTR library
+type RTRLibraryFile struct {
+	ID                  string    `json:"id"`
+	Name                string    `json:"name"`
+	Description         string    `json:"description"`
+	CommentsForAuditLog string    `json:"comments_for_audit_log"`
+	Content             string    `json:"content"` // Only for scripts
+	FileType            string    `json:"file_type"`
+	SHA256              string    `json:"sha256"`
+	Size                int64     `json:"size"`
+	PermissionType      string    `json:"permission_type"` // Only for scripts
+	Platform            []string  `json:"platform"`        // Only for scripts
+	WriteAccess         bool      `json:"write_access"`
+	CreatedBy           string    `json:"created_by"`
+	CreatedTimestamp    time.Time `json:"created_timestamp"`
+	ModifiedBy          string    `json:"modified_by"`
+	ModifiedTimestamp   time.Time `json:"modified_timestamp"`
+}
+
+// UnmarshalJSON decodes the dates from epoch seconds or RFC3339 strings
+func (f *RTRLibraryFile) UnmarshalJSON(b []byte) error {
+	type rtrLibraryFile RTRLibraryFile
+	aux := &struct {
+		*rtrLibraryFile
+		CreatedTimestamp  timestamp `json:"created_timestamp"`
+		ModifiedTimestamp timestamp `json:"modified_timestamp"`
+	}{rtrLibraryFile: (*rtrLibraryFile)(f)}
+	if err := json.Unmarshal(b, aux); err != nil {
+		return err
+	}
+	f.CreatedTimestamp, f.ModifiedTimestamp = aux.CreatedTimestamp.Time, aux.ModifiedTimestamp.Time
+	return nil
+}
+
+// RTRLibraryResponse is returned from the put-files and scripts APIs
+type RTRLibraryResponse struct {
+	Meta struct {
+		QueryTime float64 `json:"query_time"`
+		TraceID   string  `json:"trace_id"`
+	} `json:"meta"`
+	Resources []RTRLibraryFile `json:"resources"`
+	Errors    []Error          `json:"errors"`
+	items     []string
+}
+
+// Err returns a *PartialError if the response carries per-item errors, nil otherwise
+func (r *RTRLibraryResponse) Err() error {
+	return newPartialError(r.items, r.Errors)
+}
+
+// PutFileRequest uploads a file to the put-files library
+type PutFileRequest struct {
+	Name        string    // Name of the file in the library, defaults to FileName
+	Description string    // Required
+	Comment     string    // Optional comment for the audit log
+	FileName    string    // Name of the uploaded file
+	File        io.Reader // Content of the file
+}
+
+// ScriptRequest creates or updates a custom script. Set either Content or File.
+type ScriptRequest struct {
+	ID             string    // Required for updates
+	Name           string    // Required for creation
+	Description    string    // Required for creation
+	Comment        string    // Optional comment for the audit log
+	PermissionType string    // One of the ScriptPermission constants
+	Platforms      []string  // windows, mac or linux
+	Content        string    // Content of the script
+	File           io.Reader // Content of the script as a file - used if Content is empty
+}
+
+// queryLibrary returns a page of the IDs in the library of the given kind
+func (h *Host) queryLibrary(ctx context.Context, kind string, req *QueryRequest) (resp *QueryResponse, err error) {
+	resp = &QueryResponse{}
+	err = h.do(ctx, "GET", "real-time-response/queries/"+kind+"/v1", queryRequestToParams(req), nil, resp, h.authFunc())
+	if err == nil {
+		err = h.partial(resp.Err())
+	}
+	return
+}
+
+// getLibrary returns the library files of the given kind
+func (h *Host) getLibrary(ctx context.Context, kind string, ids []string) (resp *RTRLibraryResponse, err error) {
+	if len(ids) == 0 {
+		return nil, ErrMissingParams
+	}
+	resp = &RTRLibraryResponse{items: ids}
+	params := url.Values{}
+	addStringArr("ids", ids, params)
+	err = h.do(ctx, "GET", "real-time-response/entities/"+kind+"/v1", params, nil, resp, h.authFunc())
+	if err == nil {
+		err = h.partial(resp.Err())
+	}
+	return
+}
+
+// deleteLibrary deletes the library file of the given kind
+func (h *Host) deleteLibrary(ctx context.Context, kind, id string) (resp *RTRLibraryResponse, err error) {
+	if id == "" {
+		return nil, ErrMissingParams
+	}
+	resp = &RTRLibraryResponse{items: []string{id}}
+	err = h.do(ctx, "DELETE", "real-time-response/entities/"+kind+"/v1", url.Values{"ids": {id}}, nil, resp, h.authFunc())
+	if err == nil {
+		err = h.partial(resp.Err())
+	}
+	return
+}
+
+// QueryPutFiles returns a page of the IDs of the put-files matching the request
+func (h *Host) QueryPutFiles(req *QueryRequest) (resp *QueryResponse, err error) {
+	return h.queryLibrary(context.Background(), "put-files", req)
+}
+
+// QueryPutFilesContext is like QueryPutFiles with a context for cancellation and deadlines
+func (h *Host) QueryPutFilesContext(ctx context.Context, req *QueryRequest) (resp *QueryResponse, err error) {
+	return h.queryLibrary(ctx, "put-files", req)
+}
+
+// GetPutFiles returns the put-files with the given IDs
+func (h *Host) GetPutFiles(ids []string) (resp *RTRLibraryResponse, err error) {
+	return h.getLibrary(context.Background(), "put-files", ids)
+}
+
+// GetPutFilesContext is like GetPutFiles with a context for cancellation and deadlines
+func (h *Host) GetPutFilesContext(ctx context.Context, ids []string) (resp *RTRLibraryResponse, err error) {
+	return h.getLibrary(ctx, "put-files", ids)
+}
+
+// DeletePutFile deletes the put-file with the given ID
+func (h *Host) DeletePutFile(id string) (resp *RTRLibraryResponse, err error) {
+	return h.deleteLibrary(context.Background(), "put-files", id)
+}
+
+// DeletePutFileContext is like DeletePutFile with a context for cancellation and deadlines
+func (h *Host) DeletePutFileContext(ctx context.Context, id string) (resp *RTRLibraryResponse, err error) {
+	return h.deleteLibrary(ctx, "put-files", id)
+}
+
+// UploadPutFile uploads a file to the put-files library
+func (h *Host) UploadPutFile(req *PutFileRequest) (resp *RTRLibraryResponse, err error) {
+	return h.UploadPutFileContext(context.Background(), req)
+}
+
+// UploadPutFileContext is like UploadPutFile with a context for cancellation and deadlines
+func (h *Host) UploadPutFileContext(ctx context.Context, req *PutFileRequest) (resp *RTRLibraryResponse, err error) {
+	if req.File == nil || req.FileName == "" || req.Description == "" {
+		return nil, ErrMissingParams
+	}
+	fields := url.Values{"description": {req.Description}}
+	addString("name", req.Name, fields)
+	addString("comments_for_audit_log", req.Comment, fields)
+	body, err := multipartBody(fields, &multipartFile{field: "file", filename: req.FileName, r: req.File})
+	if err != nil {
+		return nil, err
+	}
+	name := req.Name
+	if name == "" {
+		name = req.FileName
+	}
+	resp = &RTRLibraryResponse{items: []string{name}}
+	err = h.do(ctx, "POST", "real-time-response/entities/put-files/v1", nil, body, resp, h.authFunc())
+	if err == nil {
+		err = h.partial(resp.Err())
+	}
+	return
+}
+
+// QueryScripts returns a page of the IDs of the custom scripts matching the request
+func (h *Host) QueryScripts(req *QueryRequest) (resp *QueryResponse, err error) {
+	return h.queryLibrary(context.Background(), "scripts", req)
+}
+
+// QueryScriptsContext is like QueryScripts with a context for cancellation and deadlines
+func (h *Host) QueryScriptsContext(ctx context.Context, req *QueryRequest) (resp *QueryResponse, err error) {
+	return h.queryLibrary(ctx, "scripts", req)
+}
+
+// GetScripts returns the custom scripts with the given IDs, including their content
+func (h *Host) GetScripts(ids []string) (resp *RTRLibraryResponse, err error) {
+	return h.getLibrary(context.Background(), "scripts", ids)
+}
+
+// GetScriptsContext is like GetScripts with a context for cancellation and deadlines
+func (h *Host) GetScriptsContext(ctx context.Context, ids []string) (resp *RTRLibraryResponse, err error) {
+	return h.getLibrary(ctx, "scripts", ids)
+}
+
+// DeleteScript deletes the custom script with the given ID
+func (h *Host) DeleteScript(id string) (resp *RTRLibraryResponse, err error) {
+	return h.deleteLibrary(context.Background(), "scripts", id)
+}
+
+// DeleteScriptContext is like DeleteScript with a context for cancellation and deadlines
+func (h *Host) DeleteScriptContext(ctx context.Context, id string) (resp *RTRLibraryResponse, err error) {
+	return h.deleteLibrary(ctx, "scripts", id)
+}
+
+// scriptBody encodes the script request as a multipart body
+func scriptBody(req *ScriptRequest) (*typedBody, error) {
+	fields := url.Values{}
+	addString("id", req.ID, fields)
+	addString("name", req.Name, fields)
+	addString("description", req.Description, fields)
+	addString("comments_for_audit_log", req.Comment, fields)
+	addString("permission_type", req.PermissionType, fields)
+	if len(req.Platforms) > 0 {
+		fields.Set("platform", strings.Join(req.Platforms, ","))
+	}
+	if req.Content != "" || req.File == nil {
+		addString("content", req.Content, fields)
+		return multipartBody(fields, nil)
+	}
+	// The API only reads the file if it has a name
+	filename := req.Name
+	if filename == "" {
+		filename = req.ID
+	}
+	return multipartBody(fields, &multipartFile{field: "file", filename: filename, r: req.File})
+}
+
+// CreateScript uploads a custom script to the library
+func (h *Host) CreateScript(req *ScriptRequest) (resp *RTRLibraryResponse, err error) {
+	return h.CreateScriptContext(context.Background(), req)
+}
+
+// CreateScriptContext is like CreateScript with a context for cancellation and deadlines
+func (h *Host) CreateScriptContext(ctx context.Context, req *ScriptRequest) (resp *RTRLibraryResponse, err error) {
+	if req.Name == "" || req.Description == "" || req.PermissionType == "" || (req.Content == "" && req.File == nil) {
+		return nil, ErrMissingParams
+	}
+	body, err := scriptBody(req)
+	if err != nil {
+		return nil, err
+	}
+	resp = &RTRLibraryResponse{items: []string{req.Name}}
+	err = h.do(ctx, "POST", "real-time-response/entities/scripts/v1", nil, body, resp, h.authFunc())
+	if err == nil {
+		err = h.partial(resp.Err())
+	}
+	return
+}
+
+// UpdateScript updates the custom script with the ID of the request. Empty fields are left unchanged.
+func (h *Host) UpdateScript(req *ScriptRequest) (resp *RTRLibraryResponse, err error) {
+	return h.UpdateScriptContext(context.Background(), req)
+}
+
+// UpdateScriptContext is like UpdateScript with a context for cancellation and deadlines
+func (h *Host) UpdateScriptContext(ctx context.Context, req *ScriptRequest) (resp *RTRLibraryResponse, err error) {
+	if req.ID == "" {
+		return nil, ErrMissingParams
+	}
+	body, err := scriptBody(req)
+	if err != nil {
+		return nil, err
+	}
+	resp = &RTRLibraryResponse{items: []string{req.ID}}
+	err = h.do(ctx, "PATCH", "real-time-response/entities/scripts/v1", nil, body, resp, h.authFunc())
+	if err == nil {
+		err = h.partial(resp.Err())
+	}
+	return
+}
@@ -0,0 +1,133 @@
+package gocs
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// parseForm parses the multipart body of the request
+func parseForm(t *testing.T, r *http.Request) {
+	t.Helper()
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		t.Errorf("unexpected content type %s", r.Header.Get("Content-Type"))
+	}
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		t.Errorf("bad form: %v", err)
+	}
+}
+
+func TestUploadPutFile(t *testing.T) {
+	h := newTestHost(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/real-time-response/entities/put-files/v1" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+		parseForm(t, r)
+		if r.FormValue("description") != "tool" || r.FormValue("comments_for_audit_log") != "ticket 1" {
+			t.Errorf("unexpected fields %v", r.MultipartForm.Value)
+		}
+		if _, ok := r.MultipartForm.Value["name"]; ok {
+			t.Errorf("the empty name must not be sent")
+		}
+		f, header, err := r.FormFile("file")
+		if err != nil {
+			t.Errorf("expected the file - %v", err)
+			return
+		}
+		b, _ := ioutil.ReadAll(f)
+		if header.Filename != "tool.exe" || string(b) != "MZ" {
+			t.Errorf("unexpected file %s %q", header.Filename, b)
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"errors": []Error{{Code: "409", Message: "file already exists"}}})
+	})
+	resp, err := h.UploadPutFile(&PutFileRequest{Description: "tool", Comment: "ticket 1", FileName: "tool.exe", File: strings.NewReader("MZ")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pe, ok := resp.Err().(*PartialError)
+	if !ok || pe.Errors[0].Item != "tool.exe" {
+		t.Fatalf("expected the error of the file name, got %v", resp.Err())
+	}
+	if _, err = h.UploadPutFile(&PutFileRequest{FileName: "tool.exe", File: strings.NewReader("MZ")}); err != ErrMissingParams {
+		t.Fatalf("expected ErrMissingParams without a description, got %v", err)
+	}
+}
+
+func TestCreateScript(t *testing.T) {
+	h := newTestHost(t, func(w http.ResponseWriter, r *http.Request) {
+		parseForm(t, r)
+		if r.FormValue("name") != "collect" || r.FormValue("content") != "Get-Process" || r.FormValue("platform") != "windows,linux" ||
+			r.FormValue("permission_type") != ScriptPermissionGroup {
+			t.Errorf("unexpected fields %v", r.MultipartForm.Value)
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"resources": []map[string]interface{}{
+			{"id": "sc1", "name": "collect", "created_timestamp": "2020-03-01T10:00:00Z"},
+		}})
+	})
+	resp, err := h.CreateScript(&ScriptRequest{Name: "collect", Description: "collect", PermissionType: ScriptPermissionGroup,
+		Platforms: []string{"windows", "linux"}, Content: "Get-Process"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Resources[0].ID != "sc1" || resp.Resources[0].CreatedTimestamp.Year() != 2020 {
+		t.Fatalf("unexpected script %+v", resp.Resources[0])
+	}
+}
+
+func TestUpdateScriptFile(t *testing.T) {
+	h := newTestHost(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PATCH" {
+			t.Errorf("unexpected method %s", r.Method)
+		}
+		parseForm(t, r)
+		if r.FormValue("id") != "sc1" || len(r.MultipartForm.Value["content"]) != 0 || len(r.MultipartForm.Value["name"]) != 0 {
+			t.Errorf("unexpected fields %v", r.MultipartForm.Value)
+		}
+		if _, _, err := r.FormFile("file"); err != nil {
+			t.Errorf("expected the file - %v", err)
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{})
+	})
+	if _, err := h.UpdateScript(&ScriptRequest{ID: "sc1", File: strings.NewReader("ls")}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.UpdateScript(&ScriptRequest{Name: "no id"}); err != ErrMissingParams {
+		t.Fatalf("expected ErrMissingParams, got %v", err)
+	}
+}
+
+func TestLibraryPaths(t *testing.T) {
+	var paths []string
+	h := newTestHost(t, func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.Method+" "+r.URL.Path+"?"+r.URL.RawQuery)
+		writeJSON(w, http.StatusOK, map[string]interface{}{})
+	})
+	h.QueryScripts(&QueryRequest{Filter: "name:'collect'"})
+	h.GetPutFiles([]string{"p1", "p2"})
+	h.DeleteScript("sc1")
+	expected := []string{
+		"GET /real-time-response/queries/scripts/v1?filter=name%3A%27collect%27",
+		"GET /real-time-response/entities/put-files/v1?ids=p1&ids=p2",
+		"DELETE /real-time-response/entities/scripts/v1?ids=sc1",
+	}
+	if len(paths) != len(expected) {
+		t.Fatalf("unexpected requests %v", paths)
+	}
+	for i := range expected {
+		if paths[i] != expected[i] {
+			t.Fatalf("unexpected request %s", paths[i])
+		}
+	}
+}
+
+func TestLibraryFileTimestamps(t *testing.T) {
+	var f RTRLibraryFile
+	if err := json.Unmarshal([]byte(`{"id":"p1","created_timestamp":"","modified_timestamp":1583056800}`), &f); err != nil {
+		t.Fatal(err)
+	}
+	if f.ID != "p1" || !f.CreatedTimestamp.IsZero() || f.ModifiedTimestamp.Unix() != 1583056800 {
+		t.Fatalf("unexpected file %+v", f)
+	}
+}
@@ -0,0 +1,363 @@
+package gocs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Rule set types
+const (
+	RulesYaraMaster             = "yara-master"
+	RulesYaraUpdate             = "yara-update"
+	RulesYaraChangelog          = "yara-changelog"
+	RulesSnortSuricataMaster    = "snort-suricata-master"
+	RulesSnortSuricataUpdate    = "snort-suricata-update"
+	RulesSnortSuricataChangelog = "snort-suricata-changelog"
+	RulesCommonEventFormat      = "common-event-format"
+	RulesNetwitness             = "netwitness"
+)
+
+// Rule set archive formats
+const (
+	RulesFormatZip  = "zip"
+	RulesFormatGzip = "gzip" // A gzipped tar archive
+)
+
+// RulesRequest searches for rule sets of a type. The filters are AND'ed.
+//
+// The rules APIs are served from the OAuth2 API endpoint so the Intel client must be created with
+// SetOAuth2Credentials and SetURL or SetCloud.
+type RulesRequest struct {
+	Type       string     // Required - one of the Rules constants
+	Query      string     // Free text search across all the fields
+	Name       []string   // Rule set names
+	Tags       []string   // Tags of the rule sets
+	MinCreated *time.Time // Rule sets created at or after this time
+	MaxCreated *time.Time // Rule sets created at or before this time
+	Sort       *SortField // e.g. created_date
+	Paging
+}
+
+func rulesRequestToParams(req *RulesRequest) url.Values {
+	params := url.Values{"type": {req.Type}}
+	addString("q", req.Query, params)
+	addStringArr("name", req.Name, params)
+	addStringArr("tags", req.Tags, params)
+	if req.MinCreated != nil {
+		addInt("min_created_date", int(req.MinCreated.Unix()), params)
+	}
+	if req.MaxCreated != nil {
+		addInt("max_created_date", int(req.MaxCreated.Unix()), params)
+	}
+	addPipeSort("sort", req.Sort, params)
+	if req.Limit != 0 {
+		addInt("limit", req.Limit, params)
+	}
+	if req.Offset != 0 {
+		addInt("offset", req.Offset, params)
+	}
+	return params
+}
+
+// RuleSet is a published set of detection rules
+type RuleSet struct {
+	ID               int       `json:"id"`
+	Name             string    `json:"name"`
+	Type             string    `json:"type"`
+	Description      string    `json:"description"`
+	ShortDescription string    `json:"short_description"`
+	Tags             []string  `json:"tags"`
+	Actors           []string  `json:"actors"`
+	MalwareFamilies  []string  `json:"malware_families"`
+	Reports          []string  `json:"reports"`
+	CreatedDate      time.Time `json:"created_date"`
+	LastModifiedDate time.Time `json:"last_modified_date"`
+}
+
+// UnmarshalJSON decodes the dates from epoch seconds or RFC3339 strings
+func (r *RuleSet) UnmarshalJSON(b []byte) error {
+	type ruleSet RuleSet
+	aux := &struct {
+		*ruleSet
+		Created      timestamp `json:"created_date"`
+		LastModified timestamp `json:"last_modified_date"`
+	}{ruleSet: (*ruleSet)(r)}
+	if err := json.Unmarshal(b, aux); err != nil {
+		return err
+	}
+	r.CreatedDate, r.LastModifiedDate = aux.Created.Time, aux.LastModified.Time
+	return nil
+}
+
+// RuleSetsResponse is returned from GetRuleSets
+type RuleSetsResponse struct {
+	Meta struct {
+		QueryTime float64 `json:"query_time"`
+		TraceID   string  `json:"trace_id"`
+	} `json:"meta"`
+	Resources []RuleSet `json:"resources"`
+	Errors    []Error   `json:"errors"`
+	items     []string
+}
+
+// Err returns a *PartialError if the response carries per-item errors, nil otherwise
+func (r *RuleSetsResponse) Err() error {
+	return newPartialError(r.items, r.Errors)
+}
+
+// QueryRuleSets returns a page of rule set IDs matching the request
+func (c *Intel) QueryRuleSets(req *RulesRequest) (resp *QueryResponse, err error) {
+	return c.QueryRuleSetsContext(context.Background(), req)
+}
+
+// QueryRuleSetsContext is like QueryRuleSets with a context for cancellation and deadlines
+func (c *Intel) QueryRuleSetsContext(ctx context.Context, req *RulesRequest) (resp *QueryResponse, err error) {
+	if req.Type == "" {
+		return nil, ErrMissingParams
+	}
+	resp = &QueryResponse{}
+	err = c.do(ctx, "GET", "intel/queries/rules/v1", rulesRequestToParams(req), nil, resp, c.authFunc())
+	if err == nil {
+		err = c.partial(resp.Err())
+	}
+	return
+}
+
+// QueryRuleSetsAll returns a pager over all the rule set IDs matching the request starting at its offset.
+// The request limit is used as the page size. If max is positive, at most max IDs are returned.
+func (c *Intel) QueryRuleSetsAll(req *RulesRequest, max int) *IDPager {
+	return c.QueryRuleSetsAllContext(context.Background(), req, max)
+}
+
+// QueryRuleSetsAllContext is like QueryRuleSetsAll with a context for cancellation and deadlines
+func (c *Intel) QueryRuleSetsAllContext(ctx context.Context, req *RulesRequest, max int) *IDPager {
+	r := *req
+	p := &IDPager{}
+	p.pager = newPager(ctx, r.Offset, max, queryPage(&p.ids, func(ctx context.Context, offset int, _ string) (*QueryResponse, error) {
+		r.Offset = offset
+		return c.QueryRuleSetsContext(ctx, &r)
+	}))
+	return p
+}
+
+// GetRuleSets returns the rule sets with the given IDs
+func (c *Intel) GetRuleSets(ids []string) (resp *RuleSetsResponse, err error) {
+	return c.GetRuleSetsContext(context.Background(), ids)
+}
+
+// GetRuleSetsContext is like GetRuleSets with a context for cancellation and deadlines
+func (c *Intel) GetRuleSetsContext(ctx context.Context, ids []string) (resp *RuleSetsResponse, err error) {
+	if len(ids) == 0 {
+		return nil, ErrMissingParams
+	}
+	resp = &RuleSetsResponse{items: ids}
+	params := url.Values{}
+	addStringArr("ids", ids, params)
+	err = c.do(ctx, "GET", "intel/entities/rules/v1", params, nil, resp, c.authFunc())
+	if err == nil {
+		err = c.partial(resp.Err())
+	}
+	return
+}
+
+// RulesDownloadOptions control the download of rule set archives
+type RulesDownloadOptions struct {
+	Format string // RulesFormatZip or RulesFormatGzip. Defaults to RulesFormatZip.
+	// ETag of a previous download. If the archive did not change, nothing is written and NotModified is set.
+	ETag string
+	// LastModified of a previous download. If the archive did not change since, nothing is written and NotModified is set.
+	LastModified time.Time
+}
+
+// RulesDownload describes a downloaded archive. Keep ETag and LastModified for the next conditional download.
+type RulesDownload struct {
+	NotModified  bool      // The archive did not change since the previous download and was not written
+	ETag         string    // ETag of the archive, if provided by the API
+	LastModified time.Time // When the archive was last modified, if provided by the API
+}
+
+// DownloadRuleSet streams the archive of the rule set with the given ID to w
+func (c *Intel) DownloadRuleSet(id string, w io.Writer, opts *RulesDownloadOptions) (*RulesDownload, error) {
+	return c.DownloadRuleSetContext(context.Background(), id, w, opts)
+}
+
+// DownloadRuleSetContext is like DownloadRuleSet with a context for cancellation and deadlines
+func (c *Intel) DownloadRuleSetContext(ctx context.Context, id string, w io.Writer, opts *RulesDownloadOptions) (*RulesDownload, error) {
+	if id == "" {
+		return nil, ErrMissingParams
+	}
+	return c.downloadRules(ctx, "intel/entities/rules-files/v1", url.Values{"id": {id}}, w, opts)
+}
+
+// DownloadLatestRules streams the archive of the latest rule set of the type to w.
+// Pass the ETag and LastModified of the previous download in opts to skip unchanged rule sets.
+func (c *Intel) DownloadLatestRules(ruleType string, w io.Writer, opts *RulesDownloadOptions) (*RulesDownload, error) {
+	return c.DownloadLatestRulesContext(context.Background(), ruleType, w, opts)
+}
+
+// DownloadLatestRulesContext is like DownloadLatestRules with a context for cancellation and deadlines
+func (c *Intel) DownloadLatestRulesContext(ctx context.Context, ruleType string, w io.Writer, opts *RulesDownloadOptions) (*RulesDownload, error) {
+	if ruleType == "" {
+		return nil, ErrMissingParams
+	}
+	return c.downloadRules(ctx, "intel/entities/rules-latest-files/v1", url.Values{"type": {ruleType}}, w, opts)
+}
+
+// downloadRules streams the archive to w unless it was not modified
+func (c *Intel) downloadRules(ctx context.Context, rawurl string, params url.Values, w io.Writer, opts *RulesDownloadOptions) (*RulesDownload, error) {
+	if w == nil {
+		return nil, ErrMissingParams
+	}
+	if opts == nil {
+		opts = &RulesDownloadOptions{}
+	}
+	format, accept := opts.Format, "application/zip"
+	switch format {
+	case "":
+		format = RulesFormatZip
+	case RulesFormatZip:
+	case RulesFormatGzip:
+		accept = "application/gzip"
+	default:
+		err := &Error{Code: "bad_format", Message: fmt.Sprintf("Invalid rules archive format [%s]", format)}
+		c.errorf("%v\n", err)
+		return nil, err
+	}
+	params.Set("format", format)
+	header := http.Header{"Accept": {accept}}
+	if opts.ETag != "" {
+		header.Set("If-None-Match", opts.ETag)
+	}
+	if !opts.LastModified.IsZero() {
+		header.Set("If-Modified-Since", opts.LastModified.UTC().Format(http.TimeFormat))
+	}
+	resp, err := c.send(ctx, "GET", rawurl, params, nil, header, c.authFunc())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	c.dumpResponseBody(resp, false)
+	d := &RulesDownload{ETag: resp.Header.Get("ETag")}
+	if t, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		d.LastModified = t
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		d.NotModified = true
+		if d.ETag == "" {
+			d.ETag = opts.ETag
+		}
+		if d.LastModified.IsZero() {
+			d.LastModified = opts.LastModified
+		}
+		drainBody(resp)
+		return d, nil
+	}
+	if _, err = io.Copy(w, resp.Body); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, err
+	}
+	return d, nil
+}
+
+// ExtractRules reads the rule set archive in the given format and calls fn with every regular file in it.
+// Zip archives are read to memory as they cannot be read from a stream.
+func ExtractRules(archive io.Reader, format string, fn func(name string, r io.Reader) error) error {
+	switch format {
+	case RulesFormatZip, "":
+		data, err := ioutil.ReadAll(archive)
+		if err != nil {
+			return err
+		}
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return err
+		}
+		for _, f := range zr.File {
+			if f.FileInfo().IsDir() {
+				continue
+			}
+			r, err := f.Open()
+			if err != nil {
+				return err
+			}
+			err = fn(f.Name, r)
+			r.Close()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case RulesFormatGzip:
+		gr, err := gzip.NewReader(archive)
+		if err != nil {
+			return err
+		}
+		defer gr.Close()
+		tr := tar.NewReader(gr)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if hdr.Typeflag != tar.TypeReg {
+				continue
+			}
+			if err = fn(hdr.Name, tr); err != nil {
+				return err
+			}
+		}
+	}
+	return &Error{Code: "bad_format", Message: fmt.Sprintf("Invalid rules archive format [%s]", format)}
+}
+
+// ExtractRulesToDir extracts the rule set archive in the given format into dir, creating it if needed.
+// Returns the paths of the extracted files. Entries with absolute or parent paths are confined to dir.
+func ExtractRulesToDir(archive io.Reader, format, dir string) ([]string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	var files []string
+	err := ExtractRules(archive, format, func(name string, r io.Reader) error {
+		clean := path.Clean("/" + strings.Replace(name, "\\", "/", -1))
+		if clean == "/" {
+			return &Error{Code: "bad_archive", Message: fmt.Sprintf("Invalid file name [%s] in rules archive", name)}
+		}
+		target := filepath.Join(dir, filepath.FromSlash(clean))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		f, err := os.Create(target)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(f, r)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+		files = append(files, target)
+		return nil
+	})
+	return files, err
+}
@@ -0,0 +1,217 @@
+package gocs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQueryRuleSets(t *testing.T) {
+	var query map[string][]string
+	c := newTestIntel(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/intel/queries/rules/v1" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		query = r.URL.Query()
+		writeJSON(w, http.StatusOK, map[string]interface{}{"resources": []string{"1"}})
+	})
+	created := time.Unix(1583056800, 0)
+	_, err := c.QueryRuleSets(&RulesRequest{Type: RulesYaraMaster, Tags: []string{"apt"}, MinCreated: &created,
+		Sort: &SortField{Name: "created_date", Ascending: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query["type"][0] != RulesYaraMaster || query["tags"][0] != "apt" || query["min_created_date"][0] != "1583056800" ||
+		query["sort"][0] != "created_date|asc" || query["max_created_date"] != nil {
+		t.Fatalf("unexpected query %v", query)
+	}
+	if _, err = c.QueryRuleSets(&RulesRequest{}); err != ErrMissingParams {
+		t.Fatalf("expected ErrMissingParams, got %v", err)
+	}
+}
+
+func TestGetRuleSets(t *testing.T) {
+	c := newTestIntel(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/intel/entities/rules/v1" || len(r.URL.Query()["ids"]) != 1 {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		w.Write([]byte(`{"resources":[{"id":5,"name":"Weekly","type":"yara-master","created_date":1583056800,"last_modified_date":""}]}`))
+	})
+	resp, err := c.GetRuleSets([]string{"5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := resp.Resources[0]; r.ID != 5 || r.CreatedDate.Unix() != 1583056800 || !r.LastModifiedDate.IsZero() {
+		t.Fatalf("unexpected rule set %+v", r)
+	}
+}
+
+func TestDownloadLatestRules(t *testing.T) {
+	modified := time.Date(2020, 3, 1, 10, 0, 0, 0, time.UTC)
+	c := newTestIntel(t, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/intel/entities/rules-latest-files/v1" || q.Get("type") != RulesYaraMaster {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		if q.Get("format") == RulesFormatGzip && r.Header.Get("Accept") != "application/gzip" ||
+			q.Get("format") == RulesFormatZip && r.Header.Get("Accept") != "application/zip" {
+			t.Errorf("unexpected accept %s for %s", r.Header.Get("Accept"), q.Get("format"))
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` || r.Header.Get("If-Modified-Since") == modified.Format(http.TimeFormat) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+		w.Write([]byte("archive"))
+	})
+	var buf bytes.Buffer
+	d, err := c.DownloadLatestRules(RulesYaraMaster, &buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.NotModified || d.ETag != `"v1"` || !d.LastModified.Equal(modified) || buf.String() != "archive" {
+		t.Fatalf("unexpected download %+v %q", d, buf.String())
+	}
+	// Both the ETag and the modification time of the previous download can skip an unchanged archive
+	for _, opts := range []*RulesDownloadOptions{
+		{ETag: d.ETag, Format: RulesFormatGzip},
+		{LastModified: d.LastModified.In(time.FixedZone("IST", 2*3600))},
+	} {
+		buf.Reset()
+		next, err := c.DownloadLatestRules(RulesYaraMaster, &buf, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !next.NotModified || buf.Len() != 0 || next.ETag != opts.ETag || !next.LastModified.Equal(opts.LastModified) {
+			t.Fatalf("expected the archive not to be modified, got %+v %q", next, buf.String())
+		}
+	}
+	if _, err = c.DownloadLatestRules(RulesYaraMaster, &buf, &RulesDownloadOptions{Format: "rar"}); err == nil {
+		t.Fatal("expected an error for an invalid format")
+	}
+	if _, err = c.DownloadLatestRules("", &buf, nil); err != ErrMissingParams {
+		t.Fatalf("expected ErrMissingParams, got %v", err)
+	}
+}
+
+func TestDownloadRuleSetNotModifiedUnconditional(t *testing.T) {
+	c := newTestIntel(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/intel/entities/rules-files/v1" || r.URL.Query().Get("id") != "5" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		w.WriteHeader(http.StatusNotModified)
+	})
+	// A 304 to a request without conditions is not a valid download
+	if _, err := c.DownloadRuleSet("5", ioutil.Discard, nil); err == nil {
+		t.Fatal("expected an error for an unconditional 304")
+	}
+}
+
+// rulesArchive returns an archive in the format with regular files of the given names, each holding its name
+func rulesArchive(t *testing.T, format string, names ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	switch format {
+	case RulesFormatZip:
+		zw := zip.NewWriter(&buf)
+		zw.Create("dir/")
+		for _, name := range names {
+			f, err := zw.Create(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.Write([]byte(name))
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+	case RulesFormatGzip:
+		gw := gzip.NewWriter(&buf)
+		tw := tar.NewWriter(gw)
+		tw.WriteHeader(&tar.Header{Name: "link", Linkname: "../../etc/passwd", Typeflag: tar.TypeSymlink})
+		for _, name := range names {
+			if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(name)), Typeflag: tar.TypeReg}); err != nil {
+				t.Fatal(err)
+			}
+			tw.Write([]byte(name))
+		}
+		if err := tw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		gw.Close()
+	}
+	return buf.Bytes()
+}
+
+func TestExtractRules(t *testing.T) {
+	for _, format := range []string{RulesFormatZip, RulesFormatGzip} {
+		var names []string
+		err := ExtractRules(bytes.NewReader(rulesArchive(t, format, "a.yar", "dir/b.yar")), format, func(name string, r io.Reader) error {
+			b, err := ioutil.ReadAll(r)
+			if err != nil || string(b) != name {
+				t.Errorf("unexpected content of %s %q - %v", name, b, err)
+			}
+			names = append(names, name)
+			return nil
+		})
+		// Directories and links are skipped
+		if err != nil || len(names) != 2 || names[1] != "dir/b.yar" {
+			t.Fatalf("unexpected %s files %v - %v", format, names, err)
+		}
+	}
+	if err := ExtractRules(bytes.NewReader(nil), "rar", nil); err == nil {
+		t.Fatal("expected an error for an invalid format")
+	}
+}
+
+func TestExtractRulesToDir(t *testing.T) {
+	names := []string{"a.yar", "dir/b.yar", "../escape.yar", "dir/../../escape2.yar", "/abs.yar", `..\win.yar`}
+	for _, format := range []string{RulesFormatZip, RulesFormatGzip} {
+		root := t.TempDir()
+		dir := filepath.Join(root, "rules")
+		files, err := ExtractRulesToDir(bytes.NewReader(rulesArchive(t, format, names...)), format, dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(files) != len(names) {
+			t.Fatalf("unexpected %s files %v", format, files)
+		}
+		// Every entry lands inside the directory
+		for _, f := range files {
+			if !strings.HasPrefix(f, dir+string(filepath.Separator)) {
+				t.Fatalf("%s escaped the directory", f)
+			}
+			if _, err = os.Stat(f); err != nil {
+				t.Fatal(err)
+			}
+		}
+		var extracted []string
+		filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() {
+				rel, _ := filepath.Rel(root, p)
+				extracted = append(extracted, filepath.ToSlash(rel))
+			}
+			return nil
+		})
+		sort.Strings(extracted)
+		expected := []string{"rules/abs.yar", "rules/dir/b.yar", "rules/escape.yar", "rules/escape2.yar", "rules/a.yar", "rules/win.yar"}
+		sort.Strings(expected)
+		if strings.Join(extracted, ",") != strings.Join(expected, ",") {
+			t.Fatalf("unexpected %s files %v", format, extracted)
+		}
+	}
+	if _, err := ExtractRulesToDir(bytes.NewReader(rulesArchive(t, RulesFormatGzip, "..")), RulesFormatGzip, t.TempDir()); err == nil {
+		t.Fatal("expected an error for an entry without a name")
+	}
+}
@@ -0,0 +1,365 @@
+package gocs
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"sort"
+	"time"
+)
+
+// Vulnerability statuses
+const (
+	VulnerabilityStatusOpen    = "open"
+	VulnerabilityStatusReopen  = "reopen"
+	VulnerabilityStatusClosed  = "closed"
+	VulnerabilityStatusExpired = "expired"
+)
+
+// Facets of SearchVulnerabilities
+const (
+	VulnerabilityFacetCVE         = "cve"
+	VulnerabilityFacetHostInfo    = "host_info"
+	VulnerabilityFacetRemediation = "remediation"
+)
+
+// maxVulnerabilitiesPerRequest is the number of IDs the entity APIs accept in a single request
+const maxVulnerabilitiesPerRequest = 400
+
+// VulnerabilityRequest queries Spotlight vulnerabilities. Pages are chained with the After token.
+type VulnerabilityRequest struct {
+	Filter string     // Required FQL filter, e.g. status:'open'+cve.severity:'CRITICAL'+aid:'...'
+	Sort   *SortField // e.g. updated_timestamp or cve.base_score
+	Limit  int        // Page size
+	After  string     // Token of the page to return, empty for the first page
+	Facets []string   // Details to include in SearchVulnerabilities - VulnerabilityFacet constants
+}
+
+func vulnerabilityRequestToParams(req *VulnerabilityRequest) url.Values {
+	params := url.Values{}
+	addString("filter", req.Filter, params)
+	addString("after", req.After, params)
+	addPipeSort("sort", req.Sort, params)
+	if req.Limit != 0 {
+		addInt("limit", req.Limit, params)
+	}
+	return params
+}
+
+// CVE describes the vulnerability behind a Spotlight finding
+type CVE struct {
+	ID                  string    `json:"id"`
+	Description         string    `json:"description"`
+	BaseScore           float64   `json:"base_score"`
+	Severity            string    `json:"severity"`     // CRITICAL, HIGH, MEDIUM, LOW, NONE or UNKNOWN
+	ExPRTRating         string    `json:"exprt_rating"` // CrowdStrike's Expert Prediction Rating
+	ExploitStatus       int       `json:"exploit_status"`
+	ExploitabilityScore float64   `json:"exploitability_score"`
+	ImpactScore         float64   `json:"impact_score"`
+	Vector              string    `json:"vector"`
+	RemediationLevel    string    `json:"remediation_level"`
+	PublishedDate       time.Time `json:"published_date"`
+	References          []string  `json:"references"`
+	Types               []string  `json:"types"`
+}
+
+// UnmarshalJSON decodes the dates from epoch seconds or RFC3339 strings
+func (c *CVE) UnmarshalJSON(b []byte) error {
+	type cve CVE
+	aux := &struct {
+		*cve
+		PublishedDate timestamp `json:"published_date"`
+	}{cve: (*cve)(c)}
+	if err := json.Unmarshal(b, aux); err != nil {
+		return err
+	}
+	c.PublishedDate = aux.PublishedDate.Time
+	return nil
+}
+
+// VulnerabilityHost is the host information of a Spotlight finding
+type VulnerabilityHost struct {
+	Hostname           string   `json:"hostname"`
+	LocalIP            string   `json:"local_ip"`
+	MachineDomain      string   `json:"machine_domain"`
+	OSVersion          string   `json:"os_version"`
+	OU                 string   `json:"ou"`
+	SiteName           string   `json:"site_name"`
+	SystemManufacturer string   `json:"system_manufacturer"`
+	Platform           string   `json:"platform"`
+	ProductTypeDesc    string   `json:"product_type_desc"`
+	Tags               []string `json:"tags"`
+}
+
+// VulnerableApp is an application affected by a Spotlight finding
+type VulnerableApp struct {
+	ProductNameVersion string `json:"product_name_version"`
+	SubStatus          string `json:"sub_status"`
+	Remediation        struct {
+		IDs []string `json:"ids"`
+	} `json:"remediation"`
+}
+
+// Remediation is the recommended fix for vulnerabilities
+type Remediation struct {
+	ID        string `json:"id"`
+	Title     string `json:"title"`
+	Action    string `json:"action"`
+	Reference string `json:"reference"`
+	Link      string `json:"link"`
+	VendorURL string `json:"vendor_url"`
+}
+
+// Vulnerability is a Spotlight finding of a CVE on a host
+type Vulnerability struct {
+	ID               string            `json:"id"`
+	CID              string            `json:"cid"`
+	AgentID          string            `json:"aid"`
+	Status           string            `json:"status"` // One of the VulnerabilityStatus constants
+	CreatedTimestamp time.Time         `json:"created_timestamp"`
+	UpdatedTimestamp time.Time         `json:"updated_timestamp"`
+	ClosedTimestamp  time.Time         `json:"closed_timestamp"`
+	CVE              CVE               `json:"cve"`       // Only the ID unless the cve facet was requested
+	HostInfo         VulnerabilityHost `json:"host_info"` // Only with the host_info facet
+	Apps             []VulnerableApp   `json:"apps"`
+	Remediation      struct {
+		IDs      []string      `json:"ids"`
+		Entities []Remediation `json:"entities"` // Only with the remediation facet
+	} `json:"remediation"`
+}
+
+// UnmarshalJSON decodes the dates from epoch seconds or RFC3339 strings
+func (v *Vulnerability) UnmarshalJSON(b []byte) error {
+	type vulnerability Vulnerability
+	aux := &struct {
+		*vulnerability
+		CreatedTimestamp timestamp `json:"created_timestamp"`
+		UpdatedTimestamp timestamp `json:"updated_timestamp"`
+		ClosedTimestamp  timestamp `json:"closed_timestamp"`
+	}{vulnerability: (*vulnerability)(v)}
+	if err := json.Unmarshal(b, aux); err != nil {
+		return err
+	}
+	v.CreatedTimestamp = aux.CreatedTimestamp.Time
+	v.UpdatedTimestamp = aux.UpdatedTimestamp.Time
+	v.ClosedTimestamp = aux.ClosedTimestamp.Time
+	return nil
+}
+
+// VulnerabilitiesResponse is returned from GetVulnerabilities and SearchVulnerabilities
+type VulnerabilitiesResponse struct {
+	Meta struct {
+		QueryTime  float64 `json:"query_time"`
+		Pagination struct {
+			Total int    `json:"total"`
+			Limit int    `json:"limit"`
+			After string `json:"after"`
+		} `json:"pagination"`
+		TraceID string `json:"trace_id"`
+	} `json:"meta"`
+	Resources []Vulnerability `json:"resources"`
+	Errors    []Error         `json:"errors"`
+	items     []string
+}
+
+// Err returns a *PartialError if the response carries per-item errors, nil otherwise
+func (r *VulnerabilitiesResponse) Err() error {
+	return newPartialError(r.items, r.Errors)
+}
+
+// RemediationsResponse is returned from GetRemediations
+type RemediationsResponse struct {
+	Meta struct {
+		QueryTime float64 `json:"query_time"`
+		TraceID   string  `json:"trace_id"`
+	} `json:"meta"`
+	Resources []Remediation `json:"resources"`
+	Errors    []Error       `json:"errors"`
+	items     []string
+}
+
+// Err returns a *PartialError if the response carries per-item errors, nil otherwise
+func (r *RemediationsResponse) Err() error {
+	return newPartialError(r.items, r.Errors)
+}
+
+// QueryVulnerabilities returns a page of vulnerability IDs matching the request
+func (h *Host) QueryVulnerabilities(req *VulnerabilityRequest) (resp *QueryResponse, err error) {
+	return h.QueryVulnerabilitiesContext(context.Background(), req)
+}
+
+// QueryVulnerabilitiesContext is like QueryVulnerabilities with a context for cancellation and deadlines
+func (h *Host) QueryVulnerabilitiesContext(ctx context.Context, req *VulnerabilityRequest) (resp *QueryResponse, err error) {
+	if req.Filter == "" {
+		return nil, ErrMissingParams
+	}
+	resp = &QueryResponse{}
+	err = h.do(ctx, "GET", "spotlight/queries/vulnerabilities/v1", vulnerabilityRequestToParams(req), nil, resp, h.authFunc())
+	if err == nil {
+		err = h.partial(resp.Err())
+	}
+	return
+}
+
+// QueryVulnerabilitiesAll returns a pager over all the vulnerability IDs matching the request starting at its
+// After token. The request limit is used as the page size. If max is positive, at most max IDs are returned.
+func (h *Host) QueryVulnerabilitiesAll(req *VulnerabilityRequest, max int) *IDPager {
+	return h.QueryVulnerabilitiesAllContext(context.Background(), req, max)
+}
+
+// QueryVulnerabilitiesAllContext is like QueryVulnerabilitiesAll with a context for cancellation and deadlines
+func (h *Host) QueryVulnerabilitiesAllContext(ctx context.Context, req *VulnerabilityRequest, max int) *IDPager {
+	r := *req
+	p := &IDPager{}
+	p.pager = newPager(ctx, 0, max, queryPage(&p.ids, func(ctx context.Context, _ int, after string) (*QueryResponse, error) {
+		if after != "" {
+			r.After = after
+		}
+		return h.QueryVulnerabilitiesContext(ctx, &r)
+	}))
+	return p
+}
+
+// GetVulnerabilities returns the vulnerabilities with the given IDs, including their CVE, host and remediation details.
+// Large ID lists are split into several requests and the results are combined.
+func (h *Host) GetVulnerabilities(ids []string) (resp *VulnerabilitiesResponse, err error) {
+	return h.GetVulnerabilitiesContext(context.Background(), ids)
+}
+
+// GetVulnerabilitiesContext is like GetVulnerabilities with a context for cancellation and deadlines
+func (h *Host) GetVulnerabilitiesContext(ctx context.Context, ids []string) (resp *VulnerabilitiesResponse, err error) {
+	if len(ids) == 0 {
+		return nil, ErrMissingParams
+	}
+	resp = &VulnerabilitiesResponse{items: ids}
+	for _, chunk := range chunks(ids, maxVulnerabilitiesPerRequest) {
+		params := url.Values{}
+		addStringArr("ids", chunk, params)
+		r := &VulnerabilitiesResponse{}
+		if err = h.do(ctx, "GET", "spotlight/entities/vulnerabilities/v2", params, nil, r, h.authFunc()); err != nil {
+			return
+		}
+		resp.Meta.QueryTime += r.Meta.QueryTime
+		resp.Meta.TraceID = r.Meta.TraceID
+		resp.Resources = append(resp.Resources, r.Resources...)
+		resp.Errors = append(resp.Errors, r.Errors...)
+	}
+	err = h.partial(resp.Err())
+	return
+}
+
+// SearchVulnerabilities returns a page of the vulnerabilities matching the request, with the details of the
+// requested facets, in a single call
+func (h *Host) SearchVulnerabilities(req *VulnerabilityRequest) (resp *VulnerabilitiesResponse, err error) {
+	return h.SearchVulnerabilitiesContext(context.Background(), req)
+}
+
+// SearchVulnerabilitiesContext is like SearchVulnerabilities with a context for cancellation and deadlines
+func (h *Host) SearchVulnerabilitiesContext(ctx context.Context, req *VulnerabilityRequest) (resp *VulnerabilitiesResponse, err error) {
+	if req.Filter == "" {
+		return nil, ErrMissingParams
+	}
+	params := vulnerabilityRequestToParams(req)
+	addStringArr("facet", req.Facets, params)
+	resp = &VulnerabilitiesResponse{}
+	err = h.do(ctx, "GET", "spotlight/combined/vulnerabilities/v1", params, nil, resp, h.authFunc())
+	if err == nil {
+		err = h.partial(resp.Err())
+	}
+	return
+}
+
+// SearchVulnerabilitiesAll returns a pager over all the vulnerabilities matching the request starting at its
+// After token. The request limit is used as the page size. If max is positive, at most max vulnerabilities are returned.
+func (h *Host) SearchVulnerabilitiesAll(req *VulnerabilityRequest, max int) *VulnerabilityPager {
+	return h.SearchVulnerabilitiesAllContext(context.Background(), req, max)
+}
+
+// SearchVulnerabilitiesAllContext is like SearchVulnerabilitiesAll with a context for cancellation and deadlines
+func (h *Host) SearchVulnerabilitiesAllContext(ctx context.Context, req *VulnerabilityRequest, max int) *VulnerabilityPager {
+	r := *req
+	p := &VulnerabilityPager{}
+	p.pager = newPager(ctx, 0, max, func(ctx context.Context, _ int, after string) (int, int, string, error) {
+		if after != "" {
+			r.After = after
+		}
+		resp, err := h.SearchVulnerabilitiesContext(ctx, &r)
+		if err != nil {
+			return 0, 0, "", err
+		}
+		p.vulns = resp.Resources
+		return len(resp.Resources), resp.Meta.Pagination.Total, resp.Meta.Pagination.After, nil
+	})
+	return p
+}
+
+// GetRemediations returns the remediations with the given IDs
+func (h *Host) GetRemediations(ids []string) (resp *RemediationsResponse, err error) {
+	return h.GetRemediationsContext(context.Background(), ids)
+}
+
+// GetRemediationsContext is like GetRemediations with a context for cancellation and deadlines
+func (h *Host) GetRemediationsContext(ctx context.Context, ids []string) (resp *RemediationsResponse, err error) {
+	if len(ids) == 0 {
+		return nil, ErrMissingParams
+	}
+	resp = &RemediationsResponse{items: ids}
+	for _, chunk := range chunks(ids, maxVulnerabilitiesPerRequest) {
+		params := url.Values{}
+		addStringArr("ids", chunk, params)
+		r := &RemediationsResponse{}
+		if err = h.do(ctx, "GET", "spotlight/entities/remediations/v2", params, nil, r, h.authFunc()); err != nil {
+			return
+		}
+		resp.Meta.QueryTime += r.Meta.QueryTime
+		resp.Meta.TraceID = r.Meta.TraceID
+		resp.Resources = append(resp.Resources, r.Resources...)
+		resp.Errors = append(resp.Errors, r.Errors...)
+	}
+	err = h.partial(resp.Err())
+	return
+}
+
+// VulnerabilityGroup is a set of vulnerabilities that share a CVE or a host
+type VulnerabilityGroup struct {
+	Key             string          // The CVE ID or the agent ID of the host
+	Vulnerabilities []Vulnerability // The vulnerabilities in the group
+	MaxBaseScore    float64         // The highest CVE base score in the group
+}
+
+// GroupVulnerabilitiesByCVE groups the vulnerabilities by CVE ID, highest scoring groups first
+func GroupVulnerabilitiesByCVE(vulns []Vulnerability) []VulnerabilityGroup {
+	return groupVulnerabilities(vulns, func(v *Vulnerability) string { return v.CVE.ID })
+}
+
+// GroupVulnerabilitiesByHost groups the vulnerabilities by the agent ID of the host, highest scoring groups first
+func GroupVulnerabilitiesByHost(vulns []Vulnerability) []VulnerabilityGroup {
+	return groupVulnerabilities(vulns, func(v *Vulnerability) string { return v.AgentID })
+}
+
+func groupVulnerabilities(vulns []Vulnerability, key func(v *Vulnerability) string) []VulnerabilityGroup {
+	index := make(map[string]int)
+	var groups []VulnerabilityGroup
+	for i := range vulns {
+		v := &vulns[i]
+		k := key(v)
+		n, ok := index[k]
+		if !ok {
+			n = len(groups)
+			index[k] = n
+			groups = append(groups, VulnerabilityGroup{Key: k})
+		}
+		g := &groups[n]
+		g.Vulnerabilities = append(g.Vulnerabilities, *v)
+		if v.CVE.BaseScore > g.MaxBaseScore {
+			g.MaxBaseScore = v.CVE.BaseScore
+		}
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		if groups[i].MaxBaseScore != groups[j].MaxBaseScore {
+			return groups[i].MaxBaseScore > groups[j].MaxBaseScore
+		}
+		return groups[i].Key < groups[j].Key
+	})
+	return groups
+}
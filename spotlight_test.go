@@ -0,0 +1,128 @@
+package gocs
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestVulnerabilityTimestamps(t *testing.T) {
+	var v Vulnerability
+	err := json.Unmarshal([]byte(`{"id":"v1","created_timestamp":"2020-03-01T10:00:00Z","updated_timestamp":1583056800,
+		"closed_timestamp":"","cve":{"id":"CVE-2020-0601","published_date":""}}`), &v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.ID != "v1" || v.CreatedTimestamp.Year() != 2020 || v.UpdatedTimestamp.Unix() != 1583056800 || !v.ClosedTimestamp.IsZero() {
+		t.Fatalf("unexpected vulnerability %+v", v)
+	}
+	if v.CVE.ID != "CVE-2020-0601" || !v.CVE.PublishedDate.IsZero() {
+		t.Fatalf("unexpected CVE %+v", v.CVE)
+	}
+}
+
+func TestQueryVulnerabilitiesAllAfter(t *testing.T) {
+	var afters []string
+	h := newTestHost(t, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/spotlight/queries/vulnerabilities/v1" || q.Get("filter") != "status:'open'" || q.Get("sort") != "created_timestamp|asc" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		after := q.Get("after")
+		afters = append(afters, after)
+		next := map[string]string{"": "t1", "t1": "t2", "t2": ""}[after]
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"meta":      map[string]interface{}{"pagination": map[string]interface{}{"total": 5, "after": next}},
+			"resources": []string{"v-" + after + "-a", "v-" + after + "-b"},
+		})
+	})
+	ids := collectIDs(t, h.QueryVulnerabilitiesAll(&VulnerabilityRequest{Filter: "status:'open'", Sort: &SortField{Name: "created_timestamp", Ascending: true}, Limit: 2}, 5))
+	if len(ids) != 5 || ids[2] != "v-t1-a" {
+		t.Fatalf("unexpected IDs %v", ids)
+	}
+	if len(afters) != 3 || afters[2] != "t2" {
+		t.Fatalf("unexpected tokens %v", afters)
+	}
+}
+
+func TestQueryVulnerabilitiesRequiresFilter(t *testing.T) {
+	h := newTestHost(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request")
+	})
+	if _, err := h.QueryVulnerabilities(&VulnerabilityRequest{}); err != ErrMissingParams {
+		t.Fatalf("expected ErrMissingParams, got %v", err)
+	}
+	if _, err := h.SearchVulnerabilities(&VulnerabilityRequest{}); err != ErrMissingParams {
+		t.Fatalf("expected ErrMissingParams, got %v", err)
+	}
+}
+
+func TestGetVulnerabilitiesChunks(t *testing.T) {
+	var sizes []int
+	h := newTestHost(t, func(w http.ResponseWriter, r *http.Request) {
+		ids := r.URL.Query()["ids"]
+		sizes = append(sizes, len(ids))
+		var vulns []map[string]interface{}
+		for _, id := range ids {
+			vulns = append(vulns, map[string]interface{}{"id": id})
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"resources": vulns})
+	})
+	ids := make([]string, 900)
+	for i := range ids {
+		ids[i] = fmt.Sprint(i)
+	}
+	resp, err := h.GetVulnerabilities(ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sizes) != 3 || sizes[0] != maxVulnerabilitiesPerRequest || sizes[2] != 100 || len(resp.Resources) != 900 {
+		t.Fatalf("unexpected chunks %v", sizes)
+	}
+}
+
+func TestSearchVulnerabilitiesAll(t *testing.T) {
+	pages := 0
+	h := newTestHost(t, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/spotlight/combined/vulnerabilities/v1" || len(q["facet"]) != 2 {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		pages++
+		next := ""
+		if pages == 1 {
+			next = "t1"
+		}
+		w.Write([]byte(fmt.Sprintf(`{"meta":{"pagination":{"total":2,"after":%q}},
+			"resources":[{"id":"v%d","aid":"dev1","cve":{"id":"CVE-%d","base_score":%d.5}}]}`, next, pages, pages, pages)))
+	})
+	p := h.SearchVulnerabilitiesAll(&VulnerabilityRequest{Filter: "aid:'dev1'", Facets: []string{VulnerabilityFacetCVE, VulnerabilityFacetHostInfo}}, 0)
+	var vulns []Vulnerability
+	for p.Next() {
+		vulns = append(vulns, *p.Vulnerability())
+	}
+	if err := p.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(vulns) != 2 || vulns[1].CVE.ID != "CVE-2" || pages != 2 {
+		t.Fatalf("unexpected vulnerabilities %+v", vulns)
+	}
+}
+
+func TestGroupVulnerabilities(t *testing.T) {
+	vulns := []Vulnerability{
+		{ID: "1", AgentID: "a", CVE: CVE{ID: "CVE-1", BaseScore: 5}},
+		{ID: "2", AgentID: "b", CVE: CVE{ID: "CVE-2", BaseScore: 9.8}},
+		{ID: "3", AgentID: "b", CVE: CVE{ID: "CVE-1", BaseScore: 5}},
+		{ID: "4", AgentID: "c", CVE: CVE{ID: "CVE-3", BaseScore: 5}},
+	}
+	byCVE := GroupVulnerabilitiesByCVE(vulns)
+	if len(byCVE) != 3 || byCVE[0].Key != "CVE-2" || byCVE[1].Key != "CVE-1" || len(byCVE[1].Vulnerabilities) != 2 || byCVE[2].Key != "CVE-3" {
+		t.Fatalf("unexpected groups %+v", byCVE)
+	}
+	byHost := GroupVulnerabilitiesByHost(vulns)
+	if len(byHost) != 3 || byHost[0].Key != "b" || byHost[0].MaxBaseScore != 9.8 || byHost[1].Key != "a" {
+		t.Fatalf("unexpected groups %+v", byHost)
+	}
+}
@@ -0,0 +1,483 @@
+package gocs
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Event Streams event types
+const (
+	EventDetectionSummary           = "DetectionSummaryEvent"
+	EventIncidentSummary            = "IncidentSummaryEvent"
+	EventAuthActivityAudit          = "AuthActivityAuditEvent"
+	EventUserActivityAudit          = "UserActivityAuditEvent"
+	EventRemoteResponseSessionStart = "RemoteResponseSessionStartEvent"
+	EventRemoteResponseSessionEnd   = "RemoteResponseSessionEndEvent"
+)
+
+const (
+	// DefaultStreamReconnectWait is the initial wait before reconnecting to a stream that failed
+	DefaultStreamReconnectWait = time.Second
+	// maxStreamReconnectWait bounds the wait between failed reconnects
+	maxStreamReconnectWait = 2 * time.Minute
+	// maxStreamLine is the largest event we accept from the feed
+	maxStreamLine = 16 << 20
+)
+
+// StreamInfo describes a partition of the event stream as returned from DiscoverStreams
+type StreamInfo struct {
+	DataFeedURL  string `json:"dataFeedURL"`
+	SessionToken struct {
+		Token      string    `json:"token"`
+		Expiration time.Time `json:"expiration"`
+	} `json:"sessionToken"`
+	RefreshActiveSessionURL      string `json:"refreshActiveSessionURL"`
+	RefreshActiveSessionInterval int    `json:"refreshActiveSessionInterval"` // Seconds
+}
+
+// UnmarshalJSON decodes the session expiration from epoch seconds or RFC3339 strings
+func (s *StreamInfo) UnmarshalJSON(b []byte) error {
+	type streamInfo StreamInfo
+	aux := &struct {
+		*streamInfo
+		SessionToken struct {
+			Token      string    `json:"token"`
+			Expiration timestamp `json:"expiration"`
+		} `json:"sessionToken"`
+	}{streamInfo: (*streamInfo)(s)}
+	if err := json.Unmarshal(b, aux); err != nil {
+		return err
+	}
+	s.SessionToken.Token, s.SessionToken.Expiration = aux.SessionToken.Token, aux.SessionToken.Expiration.Time
+	return nil
+}
+
+// Partition returns the partition number of the stream, -1 if the feed URL does not carry one
+func (s *StreamInfo) Partition() int {
+	u, err := url.Parse(s.DataFeedURL)
+	if err != nil {
+		return -1
+	}
+	p, err := strconv.Atoi(path.Base(u.Path))
+	if err != nil {
+		return -1
+	}
+	return p
+}
+
+// StreamsResponse is returned from DiscoverStreams
+type StreamsResponse struct {
+	Meta struct {
+		QueryTime  float64 `json:"query_time"`
+		TraceID    string  `json:"trace_id"`
+		Pagination struct {
+			Total int `json:"total"`
+		} `json:"pagination"`
+	} `json:"meta"`
+	Resources []StreamInfo `json:"resources"`
+	Errors    []Error      `json:"errors"`
+}
+
+// StreamEventMetadata is common to all the events in the stream
+type StreamEventMetadata struct {
+	CustomerID        string `json:"customerIDString"`
+	Offset            int64  `json:"offset"`
+	EventType         string `json:"eventType"`
+	EventCreationTime int64  `json:"eventCreationTime"` // Milliseconds since the epoch
+	Version           string `json:"version"`
+}
+
+// StreamEvent is a single event from the stream. Use Decode to get the typed event.
+type StreamEvent struct {
+	Metadata  StreamEventMetadata `json:"metadata"`
+	Event     json.RawMessage     `json:"event"`
+	Partition int                 `json:"-"` // The partition the event was read from
+}
+
+// AuditKeyValue is a detail of an audit event
+type AuditKeyValue struct {
+	Key         string `json:"Key"`
+	ValueString string `json:"ValueString"`
+}
+
+// DetectionSummaryEvent is sent when a detection is created
+type DetectionSummaryEvent struct {
+	DetectID                      string `json:"DetectId"`
+	DetectName                    string `json:"DetectName"`
+	DetectDescription             string `json:"DetectDescription"`
+	Severity                      int    `json:"Severity"`
+	SeverityName                  string `json:"SeverityName"`
+	SensorID                      string `json:"SensorId"`
+	ComputerName                  string `json:"ComputerName"`
+	MachineDomain                 string `json:"MachineDomain"`
+	UserName                      string `json:"UserName"`
+	LocalIP                       string `json:"LocalIP"`
+	MACAddress                    string `json:"MACAddress"`
+	ProcessID                     int64  `json:"ProcessId"`
+	ParentProcessID               int64  `json:"ParentProcessId"`
+	ProcessStartTime              int64  `json:"ProcessStartTime"`
+	ProcessEndTime                int64  `json:"ProcessEndTime"`
+	FileName                      string `json:"FileName"`
+	FilePath                      string `json:"FilePath"`
+	CommandLine                   string `json:"CommandLine"`
+	MD5                           string `json:"MD5String"`
+	SHA1                          string `json:"SHA1String"`
+	SHA256                        string `json:"SHA256String"`
+	ParentImageFileName           string `json:"ParentImageFileName"`
+	ParentCommandLine             string `json:"ParentCommandLine"`
+	GrandparentImageFileName      string `json:"GrandparentImageFileName"`
+	GrandparentCommandLine        string `json:"GrandparentCommandLine"`
+	Tactic                        string `json:"Tactic"`
+	Technique                     string `json:"Technique"`
+	Objective                     string `json:"Objective"`
+	PatternDispositionDescription string `json:"PatternDispositionDescription"`
+	PatternDispositionValue       int    `json:"PatternDispositionValue"`
+	FalconHostLink                string `json:"FalconHostLink"`
+}
+
+// IncidentSummaryEvent is sent when an incident is created or updated
+type IncidentSummaryEvent struct {
+	IncidentStartTime int64   `json:"IncidentStartTime"`
+	IncidentEndTime   int64   `json:"IncidentEndTime"`
+	State             string  `json:"State"`
+	FineScore         float64 `json:"FineScore"`
+	LateralMovement   int     `json:"LateralMovement"`
+	IncidentType      string  `json:"IncidentType"`
+	FalconHostLink    string  `json:"FalconHostLink"`
+}
+
+// AuthActivityAuditEvent is sent on authentication related activity such as logins and API key changes
+type AuthActivityAuditEvent struct {
+	UserID         string          `json:"UserId"`
+	UserIP         string          `json:"UserIp"`
+	OperationName  string          `json:"OperationName"`
+	ServiceName    string          `json:"ServiceName"`
+	Success        bool            `json:"Success"`
+	UTCTimestamp   int64           `json:"UTCTimestamp"`
+	AuditKeyValues []AuditKeyValue `json:"AuditKeyValues"`
+}
+
+// UserActivityAuditEvent is sent on user activity such as detection updates and policy changes
+type UserActivityAuditEvent struct {
+	UserID         string          `json:"UserId"`
+	UserIP         string          `json:"UserIp"`
+	OperationName  string          `json:"OperationName"`
+	ServiceName    string          `json:"ServiceName"`
+	Success        bool            `json:"Success"`
+	UTCTimestamp   int64           `json:"UTCTimestamp"`
+	AuditKeyValues []AuditKeyValue `json:"AuditKeyValues"`
+}
+
+// RemoteResponseSessionEvent is sent when an RTR session starts or ends
+type RemoteResponseSessionEvent struct {
+	SessionID      string `json:"SessionId"`
+	HostnameField  string `json:"HostnameField"`
+	UserName       string `json:"UserName"`
+	StartTimestamp int64  `json:"StartTimestamp"`
+	EndTimestamp   int64  `json:"EndTimestamp"`
+}
+
+// Decode returns the typed event - *DetectionSummaryEvent, *IncidentSummaryEvent, *AuthActivityAuditEvent,
+// *UserActivityAuditEvent or *RemoteResponseSessionEvent. Other event types are returned as map[string]interface{}.
+func (e *StreamEvent) Decode() (interface{}, error) {
+	var v interface{}
+	switch e.Metadata.EventType {
+	case EventDetectionSummary:
+		v = &DetectionSummaryEvent{}
+	case EventIncidentSummary:
+		v = &IncidentSummaryEvent{}
+	case EventAuthActivityAudit:
+		v = &AuthActivityAuditEvent{}
+	case EventUserActivityAudit:
+		v = &UserActivityAuditEvent{}
+	case EventRemoteResponseSessionStart, EventRemoteResponseSessionEnd:
+		v = &RemoteResponseSessionEvent{}
+	default:
+		m := make(map[string]interface{})
+		if err := json.Unmarshal(e.Event, &m); err != nil {
+			return nil, err
+		}
+		return m, nil
+	}
+	if err := json.Unmarshal(e.Event, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// StreamOptions control the consumption of the event stream
+type StreamOptions struct {
+	AppID         string        // Required - identifies the consumer. Each app ID gets its own session.
+	Offsets       map[int]int64 // Offset to start from per partition. Partitions not in the map start from the beginning.
+	EventTypes    []string      // Only pass these event types to the handler. Empty for all.
+	ReconnectWait time.Duration // Initial wait before reconnecting. Defaults to DefaultStreamReconnectWait.
+	// Checkpoints optionally persists the offset of every partition after each handled event, so a restarted
+	// consumer resumes after the last handled event. Offsets take precedence over saved checkpoints.
+	Checkpoints CheckpointStore
+}
+
+// StreamHandler handles a single event. Returning an error stops the stream.
+type StreamHandler func(ctx context.Context, e *StreamEvent) error
+
+// DiscoverStreams returns the stream partitions available for the app ID along with their session tokens
+func (h *Host) DiscoverStreams(appID string) (resp *StreamsResponse, err error) {
+	return h.DiscoverStreamsContext(context.Background(), appID)
+}
+
+// DiscoverStreamsContext is like DiscoverStreams with a context for cancellation and deadlines
+func (h *Host) DiscoverStreamsContext(ctx context.Context, appID string) (resp *StreamsResponse, err error) {
+	if appID == "" {
+		return nil, ErrMissingParams
+	}
+	resp = &StreamsResponse{}
+	params := url.Values{"appId": {appID}, "format": {"json"}}
+	err = h.do(ctx, "GET", "sensors/entities/datafeed/v2", params, nil, resp, h.authFunc())
+	if err == nil {
+		err = firstError(resp.Errors)
+	}
+	return
+}
+
+// RefreshStream extends the session of the stream partition. ConsumeStream does this automatically.
+func (h *Host) RefreshStream(appID string, s *StreamInfo) error {
+	return h.RefreshStreamContext(context.Background(), appID, s)
+}
+
+// RefreshStreamContext is like RefreshStream with a context for cancellation and deadlines
+func (h *Host) RefreshStreamContext(ctx context.Context, appID string, s *StreamInfo) error {
+	if appID == "" || s == nil || s.RefreshActiveSessionURL == "" {
+		return ErrMissingParams
+	}
+	b, err := jsonBody(struct {
+		ActionName string `json:"action_name"`
+		AppID      string `json:"appId"`
+	}{ActionName: "refresh_active_stream_session", AppID: appID})
+	if err != nil {
+		return err
+	}
+	resp := &StreamsResponse{}
+	if err = h.do(ctx, "POST", s.RefreshActiveSessionURL, nil, b, resp, h.authFunc()); err != nil {
+		return err
+	}
+	return firstError(resp.Errors)
+}
+
+// ConsumeStream reads the event stream and calls handler for every event, in order within each partition.
+// Calls to handler are serialized. Sessions are refreshed in the background and dropped connections are
+// resumed from the offset after the last handled event. ConsumeStream blocks until ctx is done, returning
+// ctx.Err(), or until handler returns an error, which is returned.
+//
+// The feed is a long-lived connection so the http.Client of the Host must not have a timeout.
+func (h *Host) ConsumeStream(ctx context.Context, opts *StreamOptions, handler StreamHandler) error {
+	if opts == nil || opts.AppID == "" || handler == nil {
+		return ErrMissingParams
+	}
+	c := newStreamConsumer(h, opts, handler)
+	wait := opts.ReconnectWait
+	if wait <= 0 {
+		wait = DefaultStreamReconnectWait
+	}
+	for failures := 0; ; {
+		progress, err := c.run(ctx)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if c.stopErr != nil {
+			return c.stopErr
+		}
+		if progress {
+			failures = 0
+		}
+		d := wait << uint(failures)
+		if d > maxStreamReconnectWait || d <= 0 {
+			d = maxStreamReconnectWait
+		} else {
+			failures++
+		}
+		h.errorf("Event stream disconnected (%v) - reconnecting in %v\n", err, d)
+		if err := sleepContext(ctx, d); err != nil {
+			return err
+		}
+	}
+}
+
+// streamConsumer is the state of a ConsumeStream call that survives reconnects
+type streamConsumer struct {
+	h       *Host
+	appID   string
+	types   map[string]bool
+	handler StreamHandler
+	store   CheckpointStore
+	mu      sync.Mutex // Serializes the handler and guards the fields below
+	offsets map[int]int64
+	stopErr error // The handler or checkpoint error that stops the stream
+}
+
+func newStreamConsumer(h *Host, opts *StreamOptions, handler StreamHandler) *streamConsumer {
+	c := &streamConsumer{h: h, appID: opts.AppID, handler: handler, store: opts.Checkpoints, offsets: make(map[int]int64)}
+	for p, o := range opts.Offsets {
+		c.offsets[p] = o
+	}
+	if len(opts.EventTypes) > 0 {
+		c.types = make(map[string]bool)
+		for _, t := range opts.EventTypes {
+			c.types[t] = true
+		}
+	}
+	return c
+}
+
+// run discovers the partitions and reads all of them until one fails.
+// Returns true if any event was read.
+func (c *streamConsumer) run(ctx context.Context) (progress bool, err error) {
+	resp, err := c.h.DiscoverStreamsContext(ctx, c.appID)
+	if err != nil {
+		return false, err
+	}
+	if len(resp.Resources) == 0 {
+		return false, &Error{Code: "stream_error", Message: fmt.Sprintf("No streams are available for app [%s]", c.appID)}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		wg   sync.WaitGroup
+		once sync.Once
+		read int32 // Set when any event was read
+	)
+	for i := range resp.Resources {
+		s := &resp.Resources[i]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			partitionErr := c.partition(ctx, s, &read)
+			once.Do(func() {
+				err = partitionErr
+				cancel()
+			})
+		}()
+	}
+	wg.Wait()
+	return atomic.LoadInt32(&read) != 0, err
+}
+
+// partition reads a single partition until it fails, refreshing its session in the background
+func (c *streamConsumer) partition(ctx context.Context, s *StreamInfo, read *int32) error {
+	p := s.Partition()
+	interval := time.Duration(s.RefreshActiveSessionInterval) * time.Second
+	if interval <= 0 {
+		interval = 30 * time.Minute
+	}
+	margin := tokenRefreshMargin
+	if margin > interval/2 {
+		margin = interval / 2
+	}
+	keep := startKeepAlive(interval-margin, func(ctx context.Context) error {
+		return c.h.RefreshStreamContext(ctx, c.appID, s)
+	}, c.h.errorf)
+	defer keep.close()
+
+	offset, ok, err := c.offset(ctx, p)
+	if err != nil {
+		return err
+	}
+	// The feed URL already carries the app ID in its query
+	feed, err := url.Parse(s.DataFeedURL)
+	if err != nil {
+		return err
+	}
+	if ok {
+		q := feed.Query()
+		q.Set("offset", strconv.FormatInt(offset, 10))
+		feed.RawQuery = q.Encode()
+	}
+	header := http.Header{"Authorization": {"Token " + s.SessionToken.Token}}
+	resp, err := c.h.send(ctx, "GET", feed.String(), nil, nil, header, noAuth)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	c.h.dumpResponseBody(resp, false)
+	c.h.tracef("Reading event stream partition %d from offset %d\n", p, offset)
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), maxStreamLine)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			// Keep-alive
+			continue
+		}
+		e := &StreamEvent{Partition: p}
+		if err := json.Unmarshal(line, e); err != nil {
+			return err
+		}
+		atomic.StoreInt32(read, 1)
+		if err := c.handle(ctx, e); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return &Error{Code: "stream_closed", Message: fmt.Sprintf("The event stream partition %d was closed", p)}
+}
+
+// handle passes the event to the handler and records the offset to resume from
+func (c *streamConsumer) handle(ctx context.Context, e *StreamEvent) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stopErr != nil {
+		return c.stopErr
+	}
+	if c.types == nil || c.types[e.Metadata.EventType] {
+		if err := c.handler(ctx, e); err != nil {
+			c.stopErr = err
+			return err
+		}
+	}
+	next := e.Metadata.Offset + 1
+	if c.store != nil {
+		if err := c.store.Save(ctx, c.checkpointKey(e.Partition), []byte(strconv.FormatInt(next, 10))); err != nil {
+			c.stopErr = err
+			return err
+		}
+	}
+	c.offsets[e.Partition] = next
+	return nil
+}
+
+// checkpointKey is the key of the partition offset in the checkpoint store
+func (c *streamConsumer) checkpointKey(partition int) string {
+	return fmt.Sprintf("stream/%s/%d", c.appID, partition)
+}
+
+// offset returns the offset to resume the partition from, loading it from the checkpoint store on first use.
+// Returns false if the partition should be read from the beginning.
+func (c *streamConsumer) offset(ctx context.Context, partition int) (int64, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if offset, ok := c.offsets[partition]; ok || c.store == nil {
+		return offset, ok, nil
+	}
+	b, err := c.store.Load(ctx, c.checkpointKey(partition))
+	if err != nil || b == nil {
+		return 0, false, err
+	}
+	offset, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil {
+		c.stopErr = &Error{Code: "bad_checkpoint", Message: fmt.Sprintf("Invalid checkpoint [%s] for stream partition %d", b, partition)}
+		c.h.errorf("%v\n", c.stopErr)
+		return 0, false, c.stopErr
+	}
+	c.offsets[partition] = offset
+	return offset, true, nil
+}
@@ -0,0 +1,189 @@
+package gocs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// streamEvent returns the feed line of an event
+func streamEvent(offset int64, eventType, event string) string {
+	return fmt.Sprintf(`{"metadata":{"offset":%d,"eventType":"%s"},"event":%s}`+"\n", offset, eventType, event)
+}
+
+// streamHandler serves a single partition. Each connection gets the next batch of lines and is then closed.
+// The offset of each connection is recorded in offsets, guarded by mu.
+func streamHandler(t *testing.T, batches [][]string, mu *sync.Mutex, offsets *[]string) http.HandlerFunc {
+	conn := 0
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sensors/entities/datafeed/v2":
+			if r.URL.Query().Get("appId") != "app" {
+				t.Errorf("unexpected discover %s", r.URL)
+			}
+			writeJSON(w, http.StatusOK, map[string]interface{}{"resources": []map[string]interface{}{{
+				"dataFeedURL":             "http://" + r.Host + "/sensors/entities/datafeed/v1/0?appId=app",
+				"sessionToken":            map[string]string{"token": "session"},
+				"refreshActiveSessionURL": "http://" + r.Host + "/sensors/entities/datafeed-actions/v1/0",
+			}}})
+		case "/sensors/entities/datafeed/v1/0":
+			if r.Header.Get("Authorization") != "Token session" || r.URL.Query().Get("appId") != "app" {
+				t.Errorf("unexpected feed request %s %v", r.URL, r.Header)
+			}
+			mu.Lock()
+			*offsets = append(*offsets, r.URL.Query().Get("offset"))
+			n := conn
+			conn++
+			mu.Unlock()
+			if n >= len(batches) {
+				<-r.Context().Done()
+				return
+			}
+			for _, line := range batches[n] {
+				w.Write([]byte(line))
+			}
+		default:
+			t.Errorf("unexpected request %s", r.URL)
+		}
+	}
+}
+
+func TestConsumeStreamResumes(t *testing.T) {
+	var mu sync.Mutex
+	var offsets []string
+	h := newTestHost(t, streamHandler(t, [][]string{
+		{streamEvent(0, EventAuthActivityAudit, `{}`), "\n", streamEvent(1, EventDetectionSummary, `{"DetectId":"ldt:1"}`)},
+		{streamEvent(2, EventDetectionSummary, `{"DetectId":"ldt:2"}`)},
+	}, &mu, &offsets))
+	var detects []string
+	done := &Error{Code: "done", Message: "done"}
+	err := h.ConsumeStream(context.Background(), &StreamOptions{AppID: "app", EventTypes: []string{EventDetectionSummary}, ReconnectWait: time.Millisecond},
+		func(ctx context.Context, e *StreamEvent) error {
+			v, err := e.Decode()
+			if err != nil {
+				return err
+			}
+			detects = append(detects, v.(*DetectionSummaryEvent).DetectID)
+			if len(detects) == 2 {
+				return done
+			}
+			return nil
+		})
+	if err != done {
+		t.Fatalf("expected the handler error, got %v", err)
+	}
+	// The filtered event still moves the offset
+	if len(detects) != 2 || detects[1] != "ldt:2" {
+		t.Fatalf("unexpected detections %v", detects)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(offsets) != 2 || offsets[0] != "" || offsets[1] != "2" {
+		t.Fatalf("unexpected offsets %v", offsets)
+	}
+}
+
+func TestConsumeStreamOffsets(t *testing.T) {
+	var mu sync.Mutex
+	var offsets []string
+	h := newTestHost(t, streamHandler(t, nil, &mu, &offsets))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := h.ConsumeStream(ctx, &StreamOptions{AppID: "app", Offsets: map[int]int64{0: 42, 1: 7}}, func(context.Context, *StreamEvent) error {
+		return nil
+	})
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected the context error, got %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(offsets) != 1 || offsets[0] != "42" {
+		t.Fatalf("unexpected offsets %v", offsets)
+	}
+}
+
+func TestConsumeStreamBadEvent(t *testing.T) {
+	var mu sync.Mutex
+	var offsets []string
+	h := newTestHost(t, streamHandler(t, [][]string{{"not json\n"}, {streamEvent(0, "Other", `{"a":1}`)}}, &mu, &offsets))
+	var events []interface{}
+	stop := &Error{Code: "stop", Message: "stop"}
+	err := h.ConsumeStream(context.Background(), &StreamOptions{AppID: "app", ReconnectWait: time.Millisecond}, func(ctx context.Context, e *StreamEvent) error {
+		v, err := e.Decode()
+		events = append(events, v)
+		if err != nil {
+			return err
+		}
+		return stop
+	})
+	// The malformed line drops the connection, which is then resumed
+	if err != stop || len(events) != 1 || events[0].(map[string]interface{})["a"] != float64(1) {
+		t.Fatalf("unexpected result %v %v", err, events)
+	}
+}
+
+func TestConsumeStreamMissingParams(t *testing.T) {
+	h := newTestHost(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request")
+	})
+	if err := h.ConsumeStream(context.Background(), &StreamOptions{}, func(context.Context, *StreamEvent) error { return nil }); err != ErrMissingParams {
+		t.Fatalf("expected ErrMissingParams, got %v", err)
+	}
+}
+
+func TestRefreshStream(t *testing.T) {
+	var body map[string]string
+	h := newTestHost(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/sensors/entities/datafeed-actions/v1/3" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		writeJSON(w, http.StatusOK, map[string]interface{}{})
+	})
+	s := &StreamInfo{DataFeedURL: "https://firehose/sensors/entities/datafeed/v1/3?appId=app", RefreshActiveSessionURL: "sensors/entities/datafeed-actions/v1/3"}
+	if err := h.RefreshStream("app", s); err != nil {
+		t.Fatal(err)
+	}
+	if body["action_name"] != "refresh_active_stream_session" || body["appId"] != "app" {
+		t.Fatalf("unexpected body %v", body)
+	}
+	if s.Partition() != 3 {
+		t.Fatalf("unexpected partition %d", s.Partition())
+	}
+	if p := (&StreamInfo{DataFeedURL: "https://firehose/feed"}).Partition(); p != -1 {
+		t.Fatalf("expected no partition, got %d", p)
+	}
+}
+
+func TestDecodeStreamEvents(t *testing.T) {
+	for eventType, expected := range map[string]interface{}{
+		EventIncidentSummary:            &IncidentSummaryEvent{},
+		EventUserActivityAudit:          &UserActivityAuditEvent{},
+		EventRemoteResponseSessionStart: &RemoteResponseSessionEvent{},
+		EventRemoteResponseSessionEnd:   &RemoteResponseSessionEvent{},
+	} {
+		e := &StreamEvent{Metadata: StreamEventMetadata{EventType: eventType}, Event: json.RawMessage(`{}`)}
+		v, err := e.Decode()
+		if err != nil || fmt.Sprintf("%T", v) != fmt.Sprintf("%T", expected) {
+			t.Fatalf("unexpected %s event %T - %v", eventType, v, err)
+		}
+	}
+	e := &StreamEvent{Metadata: StreamEventMetadata{EventType: EventDetectionSummary}, Event: json.RawMessage(`{"Severity":"high"}`)}
+	if _, err := e.Decode(); err == nil {
+		t.Fatal("expected a decoding error")
+	}
+}
+
+func TestStreamInfoTimestamps(t *testing.T) {
+	var s StreamInfo
+	if err := json.Unmarshal([]byte(`{"dataFeedURL":"https://firehose/feed/1","sessionToken":{"token":"tok","expiration":"2020-03-01T10:30:00.123Z"}}`), &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.SessionToken.Token != "tok" || s.SessionToken.Expiration.Minute() != 30 || s.Partition() != 1 {
+		t.Fatalf("unexpected stream %+v", s)
+	}
+}